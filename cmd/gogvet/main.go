@@ -0,0 +1,14 @@
+// gogvet checks the gog annotations, that gog managed types are created with the generated code, and that immutable types are not changed.
+// It can be run by itself, eg: gogvet ./..., or by go vet, eg: go vet -vettool=$(which gogvet) ./...
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/multichecker"
+
+	"github.com/quintans/gog/gogvet"
+	_ "github.com/quintans/gog/plugins"
+)
+
+func main() {
+	multichecker.Main(gogvet.Analyzer, gogvet.ConstructionAnalyzer, gogvet.ImmutabilityAnalyzer)
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/quintans/gog/generator/argscan"
+)
+
+// FileName is the name of the configuration file, looked up from the working directory up to the module root
+const FileName = "gog.conf"
+
+const (
+	presetsSection = "presets"
+	scanSection    = "scan"
+	excludeKey     = "exclude"
+)
+
+// Config is the content of the configuration file, that has the format
+//
+//	# comment
+//	[presets]
+//	entity = [allArgsConstructor, builder{pointer: true}]
+//	[scan]
+//	exclude = mocks, *_mock.go
+type Config struct {
+	// Presets maps the preset name to its raw definition
+	Presets map[string]string
+	// Exclude are the globs of the files and dirs left out when scanning dirs
+	Exclude []string
+}
+
+// Find looks for the configuration file in dir and in its parents, stopping at the module root.
+func Find(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		path := filepath.Join(dir, FileName)
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return "", false
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+func Load(path string) (Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Config{}, err
+	}
+	defer file.Close()
+
+	cfg := Config{
+		Presets: map[string]string{},
+	}
+
+	section := ""
+	lineNumber := 0
+	// a value continues in the next lines while it has unclosed brackets
+	pending := ""
+	pendingKey := ""
+	pendingLine := 0
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+
+		if pendingKey != "" {
+			pending += " " + line
+			if !argscan.Open(pending) {
+				if err := cfg.set(section, pendingKey, pending); err != nil {
+					return Config{}, fmt.Errorf("%s:%d: %w", path, pendingLine, err)
+				}
+				pendingKey = ""
+			}
+			continue
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section != presetsSection && section != scanSection {
+				return Config{}, fmt.Errorf("%s:%d: unknown section %q", path, lineNumber, section)
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return Config{}, fmt.Errorf("%s:%d: expected key = value", path, lineNumber)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		if argscan.Open(value) {
+			pending, pendingKey, pendingLine = value, key, lineNumber
+			continue
+		}
+		if err := cfg.set(section, key, value); err != nil {
+			return Config{}, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Config{}, err
+	}
+	if pendingKey != "" {
+		return Config{}, fmt.Errorf("%s:%d: unclosed bracket in the value of %q", path, pendingLine, pendingKey)
+	}
+
+	return cfg, nil
+}
+
+func (c *Config) set(section, key, value string) error {
+	switch section {
+	case presetsSection:
+		if _, ok := c.Presets[key]; ok {
+			return fmt.Errorf("duplicate preset %q", key)
+		}
+		c.Presets[key] = value
+		return nil
+	case scanSection:
+		if key != excludeKey {
+			return fmt.Errorf("unknown key %q in section %q", key, scanSection)
+		}
+		for _, glob := range strings.Split(value, ",") {
+			if glob = strings.TrimSpace(glob); glob != "" {
+				c.Exclude = append(c.Exclude, glob)
+			}
+		}
+		return nil
+	case "":
+		return fmt.Errorf("%q is outside of a section", key)
+	}
+	return fmt.Errorf("unknown section %q", section)
+}
@@ -0,0 +1,119 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		want        map[string]string
+		wantExclude []string
+		wantErr     string
+	}{
+		{
+			name: "presets",
+			content: `# domain presets
+[presets]
+entity = [record, builder{pointer: true}]
+aggregate = [
+  entity,
+  getters
+]
+`,
+			want: map[string]string{
+				"entity":    "[record, builder{pointer: true}]",
+				"aggregate": "[ entity, getters ]",
+			},
+		},
+		{
+			name:        "scan",
+			content:     "[scan]\nexclude = mocks, *_mock.go\n",
+			want:        map[string]string{},
+			wantExclude: []string{"mocks", "*_mock.go"},
+		},
+		{
+			name:    "unknown_scan_key",
+			content: "[scan]\ninclude = mocks\n",
+			wantErr: `gog.conf:2: unknown key "include" in section "scan"`,
+		},
+		{
+			name:    "outside_section",
+			content: "entity = [record]\n",
+			wantErr: `gog.conf:1: "entity" is outside of a section`,
+		},
+		{
+			name:    "unknown_section",
+			content: "[other]\n",
+			wantErr: `gog.conf:1: unknown section "other"`,
+		},
+		{
+			name:    "duplicate",
+			content: "[presets]\na = [record]\na = [builder]\n",
+			wantErr: `gog.conf:3: duplicate preset "a"`,
+		},
+		{
+			name:    "unclosed",
+			content: "[presets]\na = [record\n",
+			wantErr: `gog.conf:2: unclosed bracket in the value of "a"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), FileName)
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := Load(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasSuffix(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cfg.Presets) != len(tt.want) {
+				t.Fatalf("got %v, want %v", cfg.Presets, tt.want)
+			}
+			for k, v := range tt.want {
+				if cfg.Presets[k] != v {
+					t.Errorf("preset %s: got %q, want %q", k, cfg.Presets[k], v)
+				}
+			}
+			if strings.Join(cfg.Exclude, ",") != strings.Join(tt.wantExclude, ",") {
+				t.Errorf("got exclude %q, want %q", cfg.Exclude, tt.wantExclude)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := Find(sub); ok {
+		t.Fatal("found a configuration file where there is none")
+	}
+
+	want := filepath.Join(root, FileName)
+	if err := os.WriteFile(want, []byte("[presets]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, ok := Find(sub)
+	if !ok || got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
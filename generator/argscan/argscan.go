@@ -0,0 +1,49 @@
+// Package argscan finds the brackets and the strings of the tag arguments, without parsing them.
+// It is shared by the tags, the presets and the configuration file, so that they agree on where the arguments end.
+// It has no dependencies, so that the config package can use it.
+package argscan
+
+// scan calls fn with the index and the depth of every byte of s that is outside of a string.
+// The depth is the one before the byte, so a bracket is at the depth of what surrounds it.
+// A backslash escapes the next byte inside a string. It returns the depth at the end of s.
+func scan(s string, fn func(i, depth int)) int {
+	depth := 0
+	inString := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case inString && c == '\\':
+			i++
+		case c == '"':
+			inString = !inString
+		case inString:
+		default:
+			fn(i, depth)
+			switch c {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			}
+		}
+	}
+	return depth
+}
+
+// Open returns true if s has brackets left open, so that the arguments continue in the next line
+func Open(s string) bool {
+	return scan(s, func(int, int) {}) > 0
+}
+
+// Split splits s by the sep bytes that are not inside brackets or strings
+func Split(s string, sep byte) []string {
+	var items []string
+	start := 0
+	scan(s, func(i, depth int) {
+		if depth == 0 && s[i] == sep {
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	})
+	return append(items, s[start:])
+}
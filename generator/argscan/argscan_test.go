@@ -0,0 +1,37 @@
+package argscan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	tests := map[string]bool{
+		`pointer=true`:          false,
+		`{pointer: true`:        true,
+		`roles=[a, {b: 1}]`:     false,
+		`roles=[a, {b: 1}`:      true,
+		`name="[not a bracket"`: false,
+		`name="\"[" roles=[`:    true,
+		`}`:                     false,
+	}
+	for s, want := range tests {
+		if got := Open(s); got != want {
+			t.Errorf("%s: got %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := map[string]string{
+		`record, builder`:                      `record| builder`,
+		`getters {pointer: true}, builder`:     `getters {pointer: true}| builder`,
+		`a roles=[x, y], b name="c, \"d\", e"`: `a roles=[x, y]| b name="c, \"d\", e"`,
+		``:                                     ``,
+	}
+	for s, want := range tests {
+		if got := strings.Join(Split(s, ','), "|"); got != want {
+			t.Errorf("%s: got %q, want %q", s, got, want)
+		}
+	}
+}
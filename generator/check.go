@@ -0,0 +1,230 @@
+package generator
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// CheckTags checks the gog tags of the file without generating any code.
+// It reports the tags that are not a plugin nor a preset, and the plugin options that are invalid.
+// Field and method tags, like `gog:@required`, are left for the plugins to check.
+func (p *Parser) CheckTags() []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, group := range p.parsedFile.Comments {
+		for _, tag := range extractTagsFromDoc(group) {
+			if tag.Name == defaultsTag && group != p.parsedFile.Doc {
+				diagnostics = append(diagnostics, p.misplacedDefaults(tag))
+				continue
+			}
+			if d, ok := p.checkTag(tag); !ok {
+				diagnostics = append(diagnostics, d)
+			}
+		}
+	}
+	return diagnostics
+}
+
+// Check checks the file without generating any code, for gogvet.
+// Besides the issues reported by CheckTags, it reports the field and method tags that no plugin of the type reads,
+// and the issues found by the checks of the plugins (see WithCheck).
+func (p *Parser) Check() []Diagnostic {
+	diagnostics := p.CheckTags()
+	if err := p.ResolveTags(); err != nil {
+		var d Diagnostic
+		if !errors.As(err, &d) {
+			d = Diagnostic{Message: err.Error()}
+		}
+		return append(diagnostics, d)
+	}
+
+	for _, mapper := range p.Mappers {
+		fieldTags := map[string]bool{}
+		methodTags := map[string]bool{}
+		var plugins []registration
+		for _, tag := range mapper.GetTags() {
+			reg, ok := p.generators[tag.Name]
+			if !ok || !Contains(reg.plugin.Accepts(), mapper.Type()) {
+				continue
+			}
+			plugins = append(plugins, reg)
+			for _, t := range reg.info.FieldTags {
+				fieldTags[t] = true
+			}
+			for _, t := range reg.info.MethodTags {
+				methodTags[t] = true
+			}
+		}
+
+		for _, field := range mapper.GetFields() {
+			diagnostics = append(diagnostics, p.checkUnread(mapper, field.Tags, fieldTags)...)
+		}
+		for _, method := range mapper.GetMethods() {
+			diagnostics = append(diagnostics, p.checkUnread(mapper, method.Tags, methodTags)...)
+		}
+		for _, reg := range plugins {
+			if reg.info.Check == nil {
+				continue
+			}
+			for _, issue := range reg.info.Check(mapper) {
+				diagnostics = append(diagnostics, p.diagnostic(issue.Pos, reg.info.Name, "%s", issue.Message))
+			}
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i].Pos, diagnostics[j].Pos
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+	return diagnostics
+}
+
+// ConstructedTypes returns the types that must be created with the code generated by one of their plugins,
+// mapped to the name of that plugin (see WithConstruction)
+func (p *Parser) ConstructedTypes() (map[string]string, error) {
+	return p.typesOf(func(info PluginInfo) bool {
+		return info.Construction
+	})
+}
+
+// ImmutableTypes returns the types whose values must not change after being created,
+// mapped to the name of the plugin that makes them immutable (see WithImmutable)
+func (p *Parser) ImmutableTypes() (map[string]string, error) {
+	return p.typesOf(func(info PluginInfo) bool {
+		return info.Immutable
+	})
+}
+
+// typesOf returns the types with a plugin matching the predicate, mapped to the name of the plugin
+func (p *Parser) typesOf(match func(info PluginInfo) bool) (map[string]string, error) {
+	if err := p.ResolveTags(); err != nil {
+		return nil, err
+	}
+	types := map[string]string{}
+	for _, mapper := range p.Mappers {
+		for _, tag := range mapper.GetTags() {
+			reg, ok := p.generators[tag.Name]
+			if ok && match(reg.info) && Contains(reg.plugin.Accepts(), mapper.Type()) {
+				types[mapper.GetName()] = reg.info.Name
+				break
+			}
+		}
+	}
+	return types, nil
+}
+
+// checkUnread reports the field or method tags that none of the plugins of the mapper reads
+func (p *Parser) checkUnread(mapper Mapper, tags Tags, read map[string]bool) []Diagnostic {
+	var diagnostics []Diagnostic
+	for _, tag := range tags {
+		if strings.HasPrefix(tag.Name, "@") && !read[tag.Name] {
+			diagnostics = append(diagnostics, p.diagnostic(tag.Pos, "", "tag %s is not read by any plugin of %s", tag.Name, mapper.GetName()))
+		}
+	}
+	return diagnostics
+}
+
+func (p *Parser) checkTag(tag Tag) (Diagnostic, bool) {
+	switch {
+	case strings.HasPrefix(tag.Name, "@"):
+		return Diagnostic{}, true
+	case strings.HasPrefix(tag.Name, optOutPrefix):
+		return p.checkTagName(tag, strings.TrimPrefix(tag.Name, optOutPrefix))
+	case tag.Name == defaultsTag:
+		name, args, _ := strings.Cut(strings.TrimSpace(tag.Args), " ")
+		if name == "" {
+			return p.diagnostic(tag.Pos, "", "missing the plugin name in %s", defaultsTag), false
+		}
+		if d, ok := p.checkTagName(tag, name); !ok {
+			return d, false
+		}
+		if reg, ok := p.generators[name]; ok && reg.info.Options != nil {
+			if err := reg.info.Options.Validate(strings.TrimSpace(args)); err != nil {
+				return p.diagnostic(tag.Pos, name, "%s", err), false
+			}
+		}
+		return Diagnostic{}, true
+	}
+
+	if d, ok := p.checkTagName(tag, tag.Name); !ok {
+		return d, false
+	}
+	reg, ok := p.generators[tag.Name]
+	if !ok {
+		// a preset
+		return Diagnostic{}, true
+	}
+	if _, err := tag.ParseArgs(); err != nil {
+		return p.diagnostic(tag.ErrorPos(err), tag.Name, "%s", err), false
+	}
+	if reg.info.Options != nil {
+		if err := reg.info.Options.Validate(tag.Args); err != nil {
+			return p.diagnostic(tag.ErrorPos(err), tag.Name, "%s", err), false
+		}
+	}
+	return Diagnostic{}, true
+}
+
+// checkTagName checks that the name is a plugin or a preset, suggesting the closest one if it is not
+func (p *Parser) checkTagName(tag Tag, name string) (Diagnostic, bool) {
+	if _, ok := p.generators[name]; ok {
+		return Diagnostic{}, true
+	}
+	if _, ok := p.presets[name]; ok {
+		return Diagnostic{}, true
+	}
+	if suggestion, ok := p.closestTagName(name); ok {
+		return p.diagnostic(tag.Pos, "", "unknown tag %q, did you mean %q?", name, suggestion), false
+	}
+	return p.diagnostic(tag.Pos, "", "unknown tag %q", name), false
+}
+
+// closestTagName returns the plugin or preset name closest to name, if any is close enough to be a typo
+func (p *Parser) closestTagName(name string) (string, bool) {
+	names := []string{}
+	for n := range p.generators {
+		names = append(names, n)
+	}
+	for n := range p.presets {
+		names = append(names, n)
+	}
+
+	best, bestDist := "", len(name)/3+1
+	for _, n := range names {
+		if d := editDistance(strings.ToLower(name), strings.ToLower(n)); d < bestDist || (d == bestDist && best != "" && n < best) {
+			best, bestDist = n, d
+		}
+	}
+	return best, best != ""
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,111 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestCheckTags(t *testing.T) {
+	registerFuncPlugins(t)
+	MustRegister(&funcPlugin{name: "getters"}, WithOptions(struct {
+		Pointer bool
+	}{}))
+
+	src := `// gog:record
+// gog:defaults getters pointer=1
+// gog:defaults unknown
+package p
+
+// gog:recrd
+// gog:entity
+// gog:getters pointer=yes
+// gog:getters {pointer: true
+// gog:-builder
+type Foo struct {
+	// gog:@required
+	name string
+}
+
+// gog:whatever
+type Bar struct{}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, d := range NewParser(fset, f).CheckTags() {
+		got = append(got, d.Error())
+	}
+	want := []string{
+		`src.go:2:1: getters: option "pointer" must be of type bool, got 1`,
+		`src.go:3:1: unknown tag "unknown"`,
+		`src.go:6:1: unknown tag "recrd", did you mean "record"?`,
+		`src.go:8:24: getters: option "pointer" must be of type bool, got yes`,
+		`src.go:9:30: getters: missing closing brace`,
+		`src.go:16:1: unknown tag "whatever"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"allArgsContructor", "allArgsConstructor", 1},
+		{"record", "record", 0},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestConstructedTypes(t *testing.T) {
+	registerFuncPlugins(t)
+	Unregister(&funcPlugin{name: "builder"})
+	MustRegister(&funcPlugin{name: "builder"}, WithConstruction())
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", filterSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := InspectGoFile(fset, nil, f).ConstructedTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Foo": "builder", "Baz": "builder"}
+	if len(got) != len(want) || got["Foo"] != want["Foo"] || got["Baz"] != want["Baz"] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestImmutableTypes(t *testing.T) {
+	registerFuncPlugins(t)
+	Unregister(&funcPlugin{name: "record"})
+	MustRegister(&funcPlugin{name: "record"}, WithImmutable())
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", filterSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := InspectGoFile(fset, nil, f).ImmutableTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Foo": "record", "Bar": "record", "Baz": "record"}
+	if len(got) != len(want) || got["Foo"] != want["Foo"] || got["Bar"] != want["Bar"] || got["Baz"] != want["Baz"] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
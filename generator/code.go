@@ -0,0 +1,442 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Code builds Go declarations with a typed API.
+// Plugins embed it, instead of Scribler, and emit declarations with Emit.
+//
+//	c.Emit(&generator.Func{
+//		Recv:    &generator.Param{Name: "f", Type: "Foo"},
+//		Name:    "Name",
+//		Results: []generator.Param{{Type: "string"}},
+//		Body:    []generator.Stmt{generator.Return(generator.Sel(generator.Id("f"), "name"))},
+//	})
+type Code struct {
+	Scribler
+}
+
+// Qual returns the qualified identifier name of the package with the import path, eg: Qual("errors", "New").
+// The package is imported, with an alias if its name conflicts with another package or identifier.
+func (c *Code) Qual(importPath, name string) string {
+	return qualifier(importPath) + "." + name
+}
+
+// Append writes the code of other
+func (c *Code) Append(other *Code) {
+	c.Body.Write(other.Flush())
+}
+
+// Emit writes the declarations
+func (c *Code) Emit(decls ...Decl) {
+	for _, d := range decls {
+		c.BPrint("\n")
+		d.render(&c.Scribler)
+	}
+}
+
+// ImportPathToName returns the package name assumed for an import path, ignoring version suffixes, eg: gopkg.in/yaml.v3 -> yaml
+func ImportPathToName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil {
+			dir := path.Dir(importPath)
+			if dir != "." {
+				base = path.Base(dir)
+			}
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexAny(base, ".-"); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+// Decl is a top level declaration
+type Decl interface {
+	render(s *Scribler)
+}
+
+// Param is a parameter, a result or a struct field. The name is empty for unnamed results and embedded fields.
+type Param struct {
+	Name string
+	Type string
+}
+
+func (p Param) String() string {
+	if p.Name == "" {
+		return p.Type
+	}
+	return p.Name + " " + p.Type
+}
+
+func joinParams(params []Param, multiline bool) string {
+	strs := make([]string, len(params))
+	for k, p := range params {
+		strs[k] = p.String()
+	}
+	if multiline && len(params) > 0 {
+		return "\n" + strings.Join(strs, ",\n") + ",\n"
+	}
+	return strings.Join(strs, ", ")
+}
+
+func results(params []Param) string {
+	if len(params) == 0 {
+		return ""
+	}
+	if len(params) == 1 && params[0].Name == "" {
+		return " " + params[0].Type
+	}
+	return " (" + joinParams(params, false) + ")"
+}
+
+// Func is a function or, with a receiver, a method declaration.
+// A variadic parameter has the type starting with `...`
+type Func struct {
+	Recv    *Param
+	Name    string
+	Params  []Param
+	Results []Param
+	// Multiline writes a parameter per line
+	Multiline bool
+	Body      []Stmt
+}
+
+func (f *Func) render(s *Scribler) {
+	s.BPrint("func ")
+	if f.Recv != nil {
+		s.BPrint("(", f.Recv.String(), ") ")
+	}
+	s.BPrint(f.Name, "(", joinParams(f.Params, f.Multiline), ")", results(f.Results), " {\n")
+	renderStmts(s, f.Body)
+	s.BPrint("}\n")
+}
+
+// StructDecl is the declaration of a struct type
+type StructDecl struct {
+	Name   string
+	Fields []Param
+}
+
+func (d *StructDecl) render(s *Scribler) {
+	s.BPrint("type ", d.Name, " struct {\n")
+	for _, f := range d.Fields {
+		s.BPrint(f.String(), "\n")
+	}
+	s.BPrint("}\n")
+}
+
+// InterfaceDecl is the declaration of an interface type
+type InterfaceDecl struct {
+	Name    string
+	Methods []Func
+}
+
+func (d *InterfaceDecl) render(s *Scribler) {
+	s.BPrint("type ", d.Name, " interface {\n")
+	for _, m := range d.Methods {
+		s.BPrint(m.Name, "(", joinParams(m.Params, false), ")", results(m.Results), "\n")
+	}
+	s.BPrint("}\n")
+}
+
+// Stmt is a statement
+type Stmt interface {
+	render(s *Scribler)
+}
+
+func renderStmts(s *Scribler, stmts []Stmt) {
+	for _, st := range stmts {
+		st.render(s)
+	}
+}
+
+// BStmts writes the statements into the body, for the code written with the print methods of Scribler
+func (s *Scribler) BStmts(stmts ...Stmt) {
+	renderStmts(s, stmts)
+}
+
+type stmtFunc func(s *Scribler)
+
+func (f stmtFunc) render(s *Scribler) {
+	f(s)
+}
+
+// Return is the return statement
+func Return(values ...Expr) Stmt {
+	return stmtFunc(func(s *Scribler) {
+		if len(values) == 0 {
+			s.BPrint("return\n")
+			return
+		}
+		s.BPrint("return ", joinExprs(values, ", "), "\n")
+	})
+}
+
+// If is the if statement
+func If(cond Expr, then ...Stmt) Stmt {
+	return IfInit(nil, cond, then...)
+}
+
+// IfInit is the if statement with an init statement, eg: if err := f(); err != nil {}
+func IfInit(init Stmt, cond Expr, then ...Stmt) Stmt {
+	return stmtFunc(func(s *Scribler) {
+		s.BPrint("if ")
+		if init != nil {
+			simple := &Scribler{}
+			init.render(simple)
+			s.BPrint(strings.TrimSuffix(simple.String(), "\n"), "; ")
+		}
+		s.BPrint(cond.String(), " {\n")
+		renderStmts(s, then)
+		s.BPrint("}\n")
+	})
+}
+
+// Define is the short variable declaration, eg: a, b := f()
+func Define(names string, value Expr) Stmt {
+	return stmtFunc(func(s *Scribler) {
+		s.BPrint(names, " := ", value.String(), "\n")
+	})
+}
+
+// Var is the variable declaration
+func Var(name, typ string) Stmt {
+	return stmtFunc(func(s *Scribler) {
+		s.BPrint("var ", name, " ", typ, "\n")
+	})
+}
+
+// Assign is the assignment, eg: a = b
+func Assign(target, value Expr) Stmt {
+	return stmtFunc(func(s *Scribler) {
+		s.BPrint(target.String(), " = ", value.String(), "\n")
+	})
+}
+
+// Do is the expression statement, eg: a function call
+func Do(x Expr) Stmt {
+	return stmtFunc(func(s *Scribler) {
+		s.BPrint(x.String(), "\n")
+	})
+}
+
+// Range is the for range statement
+func Range(key, value string, x Expr, body ...Stmt) Stmt {
+	return stmtFunc(func(s *Scribler) {
+		s.BPrint("for ", key)
+		if value != "" {
+			s.BPrint(", ", value)
+		}
+		s.BPrint(" := range ", x.String(), " {\n")
+		renderStmts(s, body)
+		s.BPrint("}\n")
+	})
+}
+
+// Blank is an empty line
+func Blank() Stmt {
+	return stmtFunc(func(s *Scribler) {
+		s.BPrint("\n")
+	})
+}
+
+// Comment is a line comment
+func Comment(text string) Stmt {
+	return stmtFunc(func(s *Scribler) {
+		s.BPrint("// ", text, "\n")
+	})
+}
+
+// Expr is an expression. It is composed with the functions below, like Call or Binary,
+// that put the operands in parenthesis when the precedence of the operators requires it.
+type Expr struct {
+	src string
+	// prec is the precedence of the outermost operator, token.HighestPrec for the primary expressions
+	prec int
+}
+
+func (x Expr) String() string {
+	return x.src
+}
+
+// operand returns the expression as an operand of an operator with the precedence prec
+func (x Expr) operand(prec int) string {
+	if x.prec < prec {
+		return "(" + x.src + ")"
+	}
+	return x.src
+}
+
+func primary(src string) Expr {
+	return Expr{src: src, prec: token.HighestPrec}
+}
+
+func joinExprs(xs []Expr, sep string) string {
+	strs := make([]string, len(xs))
+	for k, x := range xs {
+		strs[k] = x.src
+	}
+	return strings.Join(strs, sep)
+}
+
+// Id is the identifier expression, or the qualified identifier returned by Code.Qual, eg: errors.New
+func Id(name string) Expr {
+	for _, part := range strings.SplitN(name, ".", 2) {
+		if !token.IsIdentifier(part) {
+			panic(fmt.Sprintf("invalid identifier %q", name))
+		}
+	}
+	return primary(name)
+}
+
+// Raw is the expression written in Go, for the ones not composed by this API, like the zero value of a kind,
+// the default value of a field or a type passed as an argument
+func Raw(src string) Expr {
+	x, err := parser.ParseExpr(src)
+	if err != nil {
+		panic(fmt.Sprintf("invalid expression %q: %v", src, err))
+	}
+	switch n := x.(type) {
+	case *ast.BinaryExpr:
+		return Expr{src: src, prec: n.Op.Precedence()}
+	case *ast.UnaryExpr, *ast.StarExpr:
+		return Expr{src: src, prec: token.UnaryPrec}
+	}
+	return primary(src)
+}
+
+// Sel is the selector expression, eg: x.a.b
+func Sel(x Expr, names ...string) Expr {
+	return primary(x.operand(token.HighestPrec) + "." + strings.Join(names, "."))
+}
+
+// Index is the index expression, eg: x[i]
+func Index(x, index Expr) Expr {
+	return primary(x.operand(token.HighestPrec) + "[" + index.src + "]")
+}
+
+// Slice is the slice expression, eg: x[low:high:max]. The zero Expr omits the index.
+func Slice(x, low, high, max Expr) Expr {
+	src := x.operand(token.HighestPrec) + "[" + low.src + ":" + high.src
+	if max.src != "" {
+		src += ":" + max.src
+	}
+	return primary(src + "]")
+}
+
+// Unary is the unary expression, eg: !x or &x
+func Unary(op token.Token, x Expr) Expr {
+	return Expr{src: op.String() + x.operand(token.UnaryPrec), prec: token.UnaryPrec}
+}
+
+// Binary is the binary expression, eg: x == y
+func Binary(x Expr, op token.Token, y Expr) Expr {
+	prec := op.Precedence()
+	return Expr{src: x.operand(prec) + " " + op.String() + " " + y.operand(prec+1), prec: prec}
+}
+
+// OrLines is the conditional or of the expressions, with an expression per line
+func OrLines(xs ...Expr) Expr {
+	prec := token.LOR.Precedence()
+	strs := make([]string, len(xs))
+	for k, x := range xs {
+		strs[k] = x.operand(prec)
+	}
+	return Expr{src: strings.Join(strs, " ||\n"), prec: prec}
+}
+
+// Call is the function call expression
+func Call(fn Expr, args ...Expr) Expr {
+	return primary(fn.operand(token.HighestPrec) + "(" + joinExprs(args, ", ") + ")")
+}
+
+// CallSpread is the function call expression passing the last argument to the variadic parameter, eg: append(a, b...)
+func CallSpread(fn Expr, args ...Expr) Expr {
+	return primary(fn.operand(token.HighestPrec) + "(" + joinExprs(args, ", ") + "...)")
+}
+
+// CallLines is the function call expression with an argument per line
+func CallLines(fn Expr, args ...Expr) Expr {
+	if len(args) == 0 {
+		return Call(fn)
+	}
+	return primary(fn.operand(token.HighestPrec) + "(\n" + joinExprs(args, ",\n") + ",\n)")
+}
+
+// KeyValue is an element of a composite literal
+type KeyValue struct {
+	Key   string
+	Value Expr
+}
+
+// Lit is the composite literal expression, with an element per line, eg: Foo{a: 1}
+func Lit(typ string, elems ...KeyValue) Expr {
+	if len(elems) == 0 {
+		return primary(typ + "{}")
+	}
+	s := &Scribler{}
+	s.BPrint(typ, "{\n")
+	for _, e := range elems {
+		s.BPrint(e.Key, ": ", e.Value.String(), ",\n")
+	}
+	s.BPrint("}")
+	return primary(s.String())
+}
+
+// FuncLit is the function literal expression
+func FuncLit(params, res []Param, body ...Stmt) Expr {
+	s := &Scribler{}
+	s.BPrint("func(", joinParams(params, false), ")", results(res), " {\n")
+	renderStmts(s, body)
+	s.BPrint("}")
+	return primary(s.String())
+}
+
+// Quote is the string literal of s
+func Quote(s string) Expr {
+	return primary(strconv.Quote(s))
+}
+
+// Scope avoids collisions between the identifiers introduced by the generated code
+type Scope struct {
+	names map[string]bool
+}
+
+// NewScope creates a scope where the names are already in use
+func NewScope(names ...string) *Scope {
+	s := &Scope{names: map[string]bool{}}
+	s.Reserve(names...)
+	return s
+}
+
+func (s *Scope) Reserve(names ...string) {
+	for _, n := range names {
+		s.names[n] = true
+	}
+}
+
+// InUse returns true if the name is already in use
+func (s *Scope) InUse(name string) bool {
+	return s.names[name]
+}
+
+// Name returns the preferred name or, if already in use, the preferred name with a numeric suffix.
+// The returned name becomes in use.
+func (s *Scope) Name(preferred string) string {
+	name := preferred
+	for k := 1; s.names[name] || token.Lookup(name).IsKeyword(); k++ {
+		name = fmt.Sprintf("%s%d", preferred, k)
+	}
+	s.names[name] = true
+	return name
+}
@@ -0,0 +1,194 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestCodeEmit(t *testing.T) {
+	c := &Code{}
+	c.Emit(&Func{
+		Recv:    &Param{Name: "f", Type: "*Foo"},
+		Name:    "Check",
+		Params:  []Param{{Name: "names", Type: "...string"}},
+		Results: []Param{{Type: "error"}},
+		Body: []Stmt{
+			Range("_", "n", Id("names"),
+				If(Binary(Id("n"), token.EQL, Quote("")), Return(Call(Id(c.Qual("errors", "New")), Quote("empty name")))),
+			),
+			Return(Id("nil")),
+		},
+	})
+
+	want := `
+func (f *Foo) Check(names ...string) error {
+for _, n := range names {
+if n == "" {
+return _gogpkg_errors.New("empty name")
+}
+}
+return nil
+}
+`
+	if got := string(c.Flush()); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestExpr(t *testing.T) {
+	x, y := Id("x"), Id("y")
+	tests := map[string]Expr{
+		"!(x && y)":           Unary(token.NOT, Binary(x, token.LAND, y)),
+		"(x + y) * x":         Binary(Binary(x, token.ADD, y), token.MUL, x),
+		"x - (x - y)":         Binary(x, token.SUB, Binary(x, token.SUB, y)),
+		"x*y + x":             Raw("x*y + x"),
+		"(*x).name":           Sel(Raw("*x"), "name"),
+		"&Foo{}":              Unary(token.AND, Lit("Foo")),
+		"append(x, y...)":     CallSpread(Id("append"), x, y),
+		"x[:len(x):len(x)]":   Slice(x, Expr{}, Call(Id("len"), x), Call(Id("len"), x)),
+		"x == nil ||\nx && y": OrLines(Binary(x, token.EQL, Id("nil")), Binary(x, token.LAND, y)),
+	}
+	for want, x := range tests {
+		if got := x.String(); got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+
+	for _, invalid := range []func(){
+		func() { Id("b.name.x") },
+		func() { Id("a b") },
+		func() { Raw("return {") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			invalid()
+		}()
+	}
+}
+
+func TestImportPathToName(t *testing.T) {
+	tests := map[string]string{
+		"errors":                      "errors",
+		"net/http":                    "http",
+		"gopkg.in/yaml.v3":            "yaml",
+		"github.com/jackc/pgx/v5":     "pgx",
+		"github.com/mattn/go-sqlite3": "sqlite3",
+		"github.com/google/go-cmp":    "cmp",
+	}
+	for path, want := range tests {
+		if got := ImportPathToName(path); got != want {
+			t.Errorf("%s: got %s, want %s", path, got, want)
+		}
+	}
+}
+
+func TestScope(t *testing.T) {
+	s := NewScope("b", "err")
+	got := []string{s.Name("b"), s.Name("b"), s.Name("name"), s.Name("err"), s.Name("type")}
+	want := []string{"b1", "b2", "name", "err1", "type1"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if !s.InUse("b1") || s.InUse("other") {
+		t.Error("got the wrong names in use")
+	}
+}
+
+type brokenPlugin struct {
+	Code
+}
+
+func (*brokenPlugin) Name() string {
+	return "broken"
+}
+
+func (*brokenPlugin) Accepts() []MapperType {
+	return []MapperType{StructMapper}
+}
+
+func (*brokenPlugin) Imports(Mapper) map[string]string {
+	return map[string]string{}
+}
+
+func (b *brokenPlugin) GenerateBody(mapper Mapper) error {
+	// the Code API does not write invalid code
+	b.BPrint("\nfunc New", mapper.GetName(), "() {\nreturn {\n}\n")
+	return nil
+}
+
+func TestInvalidGeneratedCode(t *testing.T) {
+	saved := generators
+	t.Cleanup(func() {
+		generators = saved
+	})
+	UnregisterAll()
+	MustRegister(&brokenPlugin{})
+
+	src := `package p
+
+// gog:broken
+type Foo struct{}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = InspectGoFile(fset, nil, f).GenerateCode("src_gog.go")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"src.go:3:1: broken: invalid Go generated: line 3:", "> 3: return {"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got error %q, want it to contain %q", err, want)
+		}
+	}
+}
+
+type panicPlugin struct {
+	brokenPlugin
+}
+
+func (*panicPlugin) Name() string {
+	return "panicky"
+}
+
+func (*panicPlugin) GenerateBody(mapper Mapper) error {
+	var kind Kinder
+	_ = kind.String()
+	return nil
+}
+
+func TestPanickingPlugin(t *testing.T) {
+	saved := generators
+	t.Cleanup(func() {
+		generators = saved
+	})
+	UnregisterAll()
+	MustRegister(&panicPlugin{})
+
+	src := `package p
+
+// gog:panicky
+type Foo struct{}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = InspectGoFile(fset, nil, f).GenerateCode("src_gog.go")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	want := "src.go:3:1: panicky: plugin panicked: runtime error: invalid memory address or nil pointer dereference"
+	if err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+}
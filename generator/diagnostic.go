@@ -0,0 +1,85 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/scanner"
+	"go/token"
+)
+
+// Diagnostic is an error positioned in the source file
+type Diagnostic struct {
+	Pos     token.Position
+	Plugin  string
+	Message string
+}
+
+func (d Diagnostic) Error() string {
+	s := &Scribler{}
+	if d.Pos.IsValid() {
+		s.BPrint(d.Pos.String(), ": ")
+	}
+	if d.Plugin != "" {
+		s.BPrint(d.Plugin, ": ")
+	}
+	s.BPrint(d.Message)
+	return s.String()
+}
+
+func (p *Parser) diagnostic(pos token.Pos, plugin string, format string, args ...interface{}) Diagnostic {
+	var position token.Position
+	if p.fset != nil && pos.IsValid() {
+		position = p.fset.Position(pos)
+	}
+	return Diagnostic{
+		Pos:     position,
+		Plugin:  plugin,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// warn records the warning, for the report, and prints it unless the run is quiet
+func (p *Parser) warn(pos token.Pos, plugin string, format string, args ...interface{}) {
+	d := p.diagnostic(pos, plugin, format, args...)
+	p.warnings = append(p.warnings, d)
+	logger.Printf("warning: %s", d)
+}
+
+// MarshalJSON writes the diagnostic as {"file", "line", "column", "plugin", "message"}
+func (d Diagnostic) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		File    string `json:"file,omitempty"`
+		Line    int    `json:"line,omitempty"`
+		Column  int    `json:"column,omitempty"`
+		Plugin  string `json:"plugin,omitempty"`
+		Message string `json:"message"`
+	}{
+		File:    d.Pos.Filename,
+		Line:    d.Pos.Line,
+		Column:  d.Pos.Column,
+		Plugin:  d.Plugin,
+		Message: d.Message,
+	})
+}
+
+// diagnosticsOf converts the error into diagnostics
+func diagnosticsOf(err error) []Diagnostic {
+	var diagnostic Diagnostic
+	var diagnostics Diagnostics
+	var syntaxErrs scanner.ErrorList
+	switch {
+	case errors.As(err, &diagnostics):
+		return diagnostics
+	case errors.As(err, &diagnostic):
+		return []Diagnostic{diagnostic}
+	case errors.As(err, &syntaxErrs):
+		var ds []Diagnostic
+		for _, e := range syntaxErrs {
+			ds = append(ds, Diagnostic{Pos: e.Pos, Message: e.Msg})
+		}
+		return ds
+	default:
+		return []Diagnostic{{Message: err.Error()}}
+	}
+}
@@ -0,0 +1,235 @@
+package generator
+
+import (
+	"go/token"
+	"strings"
+
+	"github.com/quintans/gog/config"
+)
+
+// DumpVersion is the version of the model written by gog dump.
+// It changes when a field is removed or changes its meaning. New fields may be added without changing it.
+const DumpVersion = 1
+
+// Dump is the parsed model of go files, as seen by the plugins, meant to be read as JSON by external tools
+type Dump struct {
+	Version int        `json:"version"`
+	Gog     string     `json:"gog"`
+	Files   []FileDump `json:"files"`
+}
+
+// FileDump is the model of a go file
+type FileDump struct {
+	File    string `json:"file"`
+	Package string `json:"package"`
+	// PackageTags are the tags of the package doc, of this file and of the other files of the package
+	PackageTags []TagDump    `json:"packageTags"`
+	Mappers     []MapperDump `json:"mappers"`
+}
+
+// MapperDump is a struct or an interface
+type MapperDump struct {
+	Kind MapperType `json:"kind"`
+	Name string     `json:"name"`
+	Line int        `json:"line"`
+	// Tags are the tags used by the plugins, after inheriting the package tags,
+	// expanding the presets and applying the package defaults
+	Tags    []TagDump    `json:"tags"`
+	Fields  []FieldDump  `json:"fields"`
+	Methods []MethodDump `json:"methods"`
+}
+
+// TagDump is a gog tag
+type TagDump struct {
+	Name string `json:"name"`
+	// Args are the raw arguments, as read by the plugin
+	Args string `json:"args"`
+	// File is set when the tag is declared in another file, like the package tags of doc.go
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	// Preset is the preset the tag was expanded from, if any
+	Preset string `json:"preset,omitempty"`
+	// Inherited is true if the tag comes from the package doc
+	Inherited bool `json:"inherited,omitempty"`
+}
+
+// FieldDump is a struct field, or an argument or result of a method
+type FieldDump struct {
+	// Name is empty for embedded fields and unnamed arguments
+	Name string    `json:"name"`
+	Type *KindDump `json:"type"`
+	Tags []TagDump `json:"tags"`
+	Line int       `json:"line,omitempty"`
+}
+
+// MethodDump is a method of a struct or interface
+type MethodDump struct {
+	Name    string      `json:"name"`
+	Args    []FieldDump `json:"args"`
+	Results []FieldDump `json:"results"`
+	Tags    []TagDump   `json:"tags"`
+	Line    int         `json:"line,omitempty"`
+}
+
+// kinds of KindDump
+const (
+	BasicKind     = "basic"
+	PointerKind   = "pointer"
+	ArrayKind     = "array"
+	MapKind       = "map"
+	InterfaceKind = "interface"
+	FuncKind      = "func"
+	UnknownKind   = "unknown"
+)
+
+// KindDump is the type of a field
+type KindDump struct {
+	// Kind is one of basic, pointer, array, map, interface, func or unknown, for the types that gog does not parse
+	Kind string `json:"kind"`
+	// Expr is the type as written in Go, eg: map[string]*time.Time
+	Expr string `json:"expr"`
+	// Package and Name are set for basic and named interface types, eg: time and Time
+	Package string `json:"package,omitempty"`
+	Name    string `json:"name,omitempty"`
+	// Elem is the type pointed, or the element of an array, or the value of a map
+	Elem *KindDump `json:"elem,omitempty"`
+	Key  *KindDump `json:"key,omitempty"`
+	// Args and Results are set for func types
+	Args    []FieldDump `json:"args,omitempty"`
+	Results []FieldDump `json:"results,omitempty"`
+}
+
+// NewDump creates an empty dump of the current version
+func NewDump() Dump {
+	return Dump{Version: DumpVersion, Gog: config.Version, Files: []FileDump{}}
+}
+
+// DumpFile parses the go file and returns its model
+func DumpFile(workDir, fullFileName string) (FileDump, error) {
+	p, err := parseSource(relativeDir(workDir, fullFileName), fullFileName, nil)
+	if err != nil {
+		return FileDump{}, err
+	}
+	return p.Dump()
+}
+
+// Dump returns the model of the file, with the tags resolved as they are read by the plugins
+func (p *Parser) Dump() (FileDump, error) {
+	if err := p.ResolveTags(); err != nil {
+		return FileDump{}, err
+	}
+
+	d := FileDump{
+		File:        p.fileName(),
+		Package:     p.parsedFile.Name.Name,
+		PackageTags: p.tagsDump(p.packageTags),
+		Mappers:     []MapperDump{},
+	}
+	for _, mapper := range p.Mappers {
+		m := MapperDump{
+			Kind:    mapper.Type(),
+			Name:    mapper.GetName(),
+			Line:    p.typeDecls[mapper.GetName()].line,
+			Tags:    p.tagsDump(mapper.GetTags()),
+			Fields:  p.fieldsDump(mapper.GetFields()),
+			Methods: []MethodDump{},
+		}
+		for _, method := range mapper.GetMethods() {
+			m.Methods = append(m.Methods, p.methodDump(method))
+		}
+		d.Mappers = append(d.Mappers, m)
+	}
+	return d, nil
+}
+
+func (p *Parser) tagsDump(tags Tags) []TagDump {
+	dump := []TagDump{}
+	for _, tag := range tags {
+		t := TagDump{Name: tag.Name, Args: tag.Args, Preset: tag.Preset, Inherited: tag.Inherited}
+		if pos := p.position(tag.Pos); pos.IsValid() {
+			t.Line = pos.Line
+			if pos.Filename != p.fileName() {
+				t.File = pos.Filename
+			}
+		}
+		dump = append(dump, t)
+	}
+	return dump
+}
+
+func (p *Parser) fieldsDump(fields []Field) []FieldDump {
+	dump := []FieldDump{}
+	for _, field := range fields {
+		dump = append(dump, FieldDump{
+			Name: field.Name,
+			Type: p.kindDump(field.Kind),
+			Tags: p.tagsDump(field.Tags),
+			Line: p.position(field.Pos).Line,
+		})
+	}
+	return dump
+}
+
+func (p *Parser) methodDump(method Method) MethodDump {
+	return MethodDump{
+		Name:    method.FuncName,
+		Args:    p.fieldsDump(method.Args),
+		Results: p.fieldsDump(method.Results),
+		Tags:    p.tagsDump(method.Tags),
+		Line:    p.position(method.Pos).Line,
+	}
+}
+
+func (p *Parser) kindDump(kind Kinder) *KindDump {
+	switch k := kind.(type) {
+	case Basic:
+		return &KindDump{Kind: BasicKind, Expr: k.String(), Package: k.Pck, Name: k.Type}
+	case Pointer:
+		elem := p.kindDump(k.Kinder)
+		return &KindDump{Kind: PointerKind, Expr: "*" + elem.Expr, Elem: elem}
+	case Array:
+		elem := p.kindDump(k.Kinder)
+		return &KindDump{Kind: ArrayKind, Expr: "[]" + elem.Expr, Elem: elem}
+	case Variadic:
+		elem := p.kindDump(k.Kinder)
+		return &KindDump{Kind: ArrayKind, Expr: "..." + elem.Expr, Elem: elem}
+	case Map:
+		key, elem := p.kindDump(k.Key), p.kindDump(k.Val)
+		return &KindDump{Kind: MapKind, Expr: "map[" + key.Expr + "]" + elem.Expr, Key: key, Elem: elem}
+	case *InterfaceVar:
+		return &KindDump{Kind: InterfaceKind, Expr: k.String(), Package: k.Pck, Name: k.Type}
+	case *Method:
+		args, results := p.fieldsDump(k.Args), p.fieldsDump(k.Results)
+		return &KindDump{Kind: FuncKind, Expr: funcExpr(args, results), Args: args, Results: results}
+	case TypeExpr:
+		return &KindDump{Kind: UnknownKind, Expr: k.Source}
+	}
+	return &KindDump{Kind: UnknownKind}
+}
+
+// funcExpr writes the func type, without the names of the arguments and results, eg: func(string, int) (int, error)
+func funcExpr(args, results []FieldDump) string {
+	exprs := func(fields []FieldDump) string {
+		list := make([]string, len(fields))
+		for k, f := range fields {
+			list[k] = f.Type.Expr
+		}
+		return strings.Join(list, ", ")
+	}
+	expr := "func(" + exprs(args) + ")"
+	switch len(results) {
+	case 0:
+		return expr
+	case 1:
+		return expr + " " + exprs(results)
+	default:
+		return expr + " (" + exprs(results) + ")"
+	}
+}
+
+func (p *Parser) position(pos token.Pos) token.Position {
+	if p.fset == nil || !pos.IsValid() {
+		return token.Position{}
+	}
+	return p.fset.Position(pos)
+}
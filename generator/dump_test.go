@@ -0,0 +1,121 @@
+package generator
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/quintans/gog/config"
+)
+
+func TestDump(t *testing.T) {
+	registerFuncPlugins(t)
+
+	src := `// gog:record
+package p
+
+type Foo struct {
+	// gog:@required
+	name  string
+	tags  map[string][]*time.Time
+	apply func(int) (int, error)
+	ch    chan int
+}
+
+// gog:-record
+// gog:builder prefix=With
+type Repo interface {
+	Get(ctx context.Context, id string) (*Foo, error)
+}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := InspectGoFile(fset, nil, f).Dump()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	basic := func(pck, name string) *KindDump {
+		expr := name
+		if pck != "" {
+			expr = pck + "." + name
+		}
+		return &KindDump{Kind: BasicKind, Expr: expr, Package: pck, Name: name}
+	}
+	timeType := basic("time", "Time")
+	want := FileDump{
+		File:        "src.go",
+		Package:     "p",
+		PackageTags: []TagDump{{Name: "record", Line: 1}},
+		Mappers: []MapperDump{
+			{
+				Kind: StructMapper, Name: "Foo", Line: 4,
+				Tags: []TagDump{{Name: "record", Line: 1, Inherited: true}},
+				Fields: []FieldDump{
+					{Name: "name", Type: basic("", "string"), Tags: []TagDump{{Name: "@required", Line: 5}}, Line: 6},
+					{
+						Name: "tags",
+						Type: &KindDump{
+							Kind: MapKind, Expr: "map[string][]*time.Time",
+							Key: basic("", "string"),
+							Elem: &KindDump{
+								Kind: ArrayKind, Expr: "[]*time.Time",
+								Elem: &KindDump{Kind: PointerKind, Expr: "*time.Time", Elem: timeType},
+							},
+						},
+						Tags: []TagDump{}, Line: 7,
+					},
+					{
+						Name: "apply",
+						Type: &KindDump{
+							Kind: FuncKind, Expr: "func(int) (int, error)",
+							Args: []FieldDump{{Type: basic("", "int"), Tags: []TagDump{}, Line: 8}},
+							Results: []FieldDump{
+								{Type: basic("", "int"), Tags: []TagDump{}, Line: 8},
+								{Type: basic("", "error"), Tags: []TagDump{}, Line: 8},
+							},
+						},
+						Tags: []TagDump{}, Line: 8,
+					},
+					{Name: "ch", Type: &KindDump{Kind: UnknownKind, Expr: "chan int"}, Tags: []TagDump{}, Line: 9},
+				},
+				Methods: []MethodDump{},
+			},
+			{
+				Kind: InterfaceMapper, Name: "Repo", Line: 14,
+				Tags:   []TagDump{{Name: "builder", Args: "prefix=With", Line: 13}},
+				Fields: []FieldDump{},
+				Methods: []MethodDump{{
+					Name: "Get",
+					Args: []FieldDump{
+						{Name: "ctx", Type: basic("context", "Context"), Tags: []TagDump{}, Line: 15},
+						{Name: "id", Type: basic("", "string"), Tags: []TagDump{}, Line: 15},
+					},
+					Results: []FieldDump{
+						{Type: &KindDump{Kind: PointerKind, Expr: "*Foo", Elem: basic("", "Foo")}, Tags: []TagDump{}, Line: 15},
+						{Type: basic("", "error"), Tags: []TagDump{}, Line: 15},
+					},
+					Tags: []TagDump{},
+					Line: 15,
+				}},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("dump mismatch (-want +got):\n%s", diff)
+	}
+
+	data, err := json.Marshal(NewDump())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"version":1,"gog":"`+config.Version+`","files":[]}` {
+		t.Errorf("got %s", data)
+	}
+}
@@ -0,0 +1,206 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"path"
+	"strings"
+)
+
+// Filter restricts the types and the plugins used in the generation
+type Filter struct {
+	// Types are the names of the types to generate. If empty, all the types are generated,
+	// except the ones with their own gog directive (see OwnedTypes).
+	Types []string
+	// Plugins are the plugins to run. If empty, all the plugins run.
+	Plugins []string
+	// Skip are the plugins not to run
+	Skip []string
+}
+
+// allows returns true if the plugin of the tag can run.
+// A plugin can also be selected by the name of the preset it comes from.
+func (f Filter) allows(tag Tag) bool {
+	match := func(names []string) bool {
+		return Contains(names, tag.Name) || (tag.Preset != "" && Contains(names, tag.Preset))
+	}
+	if len(f.Plugins) > 0 && !match(f.Plugins) {
+		return false
+	}
+	return !match(f.Skip)
+}
+
+// typeDecl is where a type is declared in the source file
+type typeDecl struct {
+	// docLine is the first line of the doc comment, or the line of the declaration if there is no doc
+	docLine int
+	line    int
+	// owned is true if the type is generated by its own gog directive, into its own file
+	owned bool
+}
+
+// SetFilter restricts the types and the plugins used in the generation
+func (p *Parser) SetFilter(filter Filter) error {
+	for _, name := range filter.Types {
+		if _, ok := p.findMapper(name); !ok {
+			return fmt.Errorf("type %s is not declared in %s", name, p.fileName())
+		}
+	}
+	for _, name := range append(append([]string{}, filter.Plugins...), filter.Skip...) {
+		_, plugin := p.generators[name]
+		_, preset := p.presets[name]
+		if !plugin && !preset {
+			return fmt.Errorf("unknown plugin %q", name)
+		}
+	}
+	p.filter = filter
+	return nil
+}
+
+func (p *Parser) findMapper(name string) (Mapper, bool) {
+	for _, m := range p.Mappers {
+		if m.GetName() == name {
+			return m, true
+		}
+	}
+	return nil, false
+}
+
+func (p *Parser) fileName() string {
+	if p.fset == nil {
+		return p.parsedFile.Name.Name
+	}
+	return p.fset.Position(p.parsedFile.Package).Filename
+}
+
+// selected returns true if the mapper is generated
+func (p *Parser) selected(mapper Mapper) bool {
+	if len(p.filter.Types) > 0 {
+		return Contains(p.filter.Types, mapper.GetName())
+	}
+	return !p.typeDecls[mapper.GetName()].owned
+}
+
+// TypeAt returns the type whose declaration, including its doc comment, spans the line.
+// It is used to find the type of a go:generate directive, from $GOLINE.
+func (p *Parser) TypeAt(line int) (string, bool) {
+	for _, m := range p.Mappers {
+		decl, ok := p.typeDecls[m.GetName()]
+		if ok && decl.docLine <= line && line <= decl.line {
+			return m.GetName(), true
+		}
+	}
+	return "", false
+}
+
+// OwnedTypes returns the types generated by their own gog directive, placed in the doc comment of the type with -line,
+// or listing the type with -type. They are generated into their own file, and not with the rest of the file.
+func (p *Parser) OwnedTypes() []string {
+	var types []string
+	for _, m := range p.Mappers {
+		if p.Owned(m.GetName()) {
+			types = append(types, m.GetName())
+		}
+	}
+	return types
+}
+
+// Owned returns true if the type is generated by its own gog directive
+func (p *Parser) Owned(name string) bool {
+	return p.typeDecls[name].owned
+}
+
+// addTypeDecl records where the type is declared
+func (p *Parser) addTypeDecl(decl *ast.GenDecl, tspec *ast.TypeSpec) {
+	if p.fset == nil {
+		return
+	}
+	doc := decl.Doc
+	if doc == nil {
+		doc = tspec.Doc
+	}
+	line := p.fset.Position(tspec.Pos()).Line
+	td := typeDecl{docLine: line, line: line}
+	if doc != nil {
+		td.docLine = p.fset.Position(doc.Pos()).Line
+	}
+	p.typeDecls[tspec.Name.Name] = td
+}
+
+// findOwnedTypes marks the types generated by their own gog directive, the ones listed with -type
+// or, with -line, the type whose doc comment has the directive.
+// A directive without them generates the whole file, as before the types could have their own file.
+func (p *Parser) findOwnedTypes() {
+	if p.fset == nil {
+		return
+	}
+	for _, group := range p.parsedFile.Comments {
+		for _, c := range group.List {
+			args, ok := gogDirectiveArgs(c.Text)
+			if !ok {
+				continue
+			}
+			types := typeFlag(args)
+			if len(types) == 0 && lineFlag(args) {
+				if t, ok := p.TypeAt(p.fset.Position(c.Pos()).Line); ok {
+					types = []string{t}
+				}
+			}
+			for _, t := range types {
+				if td, ok := p.typeDecls[t]; ok {
+					td.owned = true
+					p.typeDecls[t] = td
+				}
+			}
+		}
+	}
+}
+
+// gogDirectiveArgs returns the arguments of a go:generate directive running gog,
+// eg: `//go:generate gog -type Foo` or `//go:generate go run github.com/quintans/gog@latest -type Foo`
+func gogDirectiveArgs(comment string) ([]string, bool) {
+	const prefix = "//go:generate "
+	if !strings.HasPrefix(comment, prefix) {
+		return nil, false
+	}
+	fields := strings.Fields(strings.TrimPrefix(comment, prefix))
+	for k, field := range fields {
+		cmd, _, _ := strings.Cut(field, "@")
+		if path.Base(cmd) == "gog" {
+			return fields[k+1:], true
+		}
+	}
+	return nil, false
+}
+
+// lineFlag returns true if the arguments have the -line flag
+func lineFlag(args []string) bool {
+	for _, arg := range args {
+		name, value, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") && name == "line" {
+			return value == "" || value == "true"
+		}
+	}
+	return false
+}
+
+// typeFlag returns the types listed in the -type flag of the arguments
+func typeFlag(args []string) []string {
+	for k, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "type" {
+			continue
+		}
+		if !hasValue && k+1 < len(args) {
+			value = args[k+1]
+		}
+		var types []string
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types = append(types, t)
+			}
+		}
+		return types
+	}
+	return nil
+}
@@ -0,0 +1,304 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// funcPlugin generates an empty function named after the type and the plugin
+type funcPlugin struct {
+	Code
+	name string
+}
+
+func (f *funcPlugin) Name() string {
+	return f.name
+}
+
+func (*funcPlugin) Accepts() []MapperType {
+	return []MapperType{StructMapper}
+}
+
+func (*funcPlugin) Imports(Mapper) map[string]string {
+	return map[string]string{}
+}
+
+func (f *funcPlugin) GenerateBody(mapper Mapper) error {
+	f.Emit(&Func{Name: mapper.GetName() + strings.Title(f.name)})
+	return nil
+}
+
+const filterSrc = `package p
+
+// gog:record
+// gog:builder
+type Foo struct{}
+
+// Bar has its own directive
+//
+//go:generate gog -line
+// gog:record
+type Bar struct{}
+
+// gog:entity
+type Baz struct{}
+`
+
+func registerFuncPlugins(t *testing.T) {
+	t.Helper()
+	savedGenerators, savedPresets := generators, presets
+	t.Cleanup(func() {
+		generators, presets = savedGenerators, savedPresets
+	})
+	UnregisterAll()
+	presets = map[string]Tags{}
+	MustRegister(&funcPlugin{name: "record"})
+	MustRegister(&funcPlugin{name: "builder"})
+	if err := RegisterPreset("entity", "[record, builder]"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	registerFuncPlugins(t)
+
+	tests := []struct {
+		name    string
+		filter  Filter
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "types with a directive are excluded",
+			want: []string{"FooRecord", "FooBuilder", "BazRecord", "BazBuilder"},
+		},
+		{
+			name:   "types",
+			filter: Filter{Types: []string{"Bar", "Baz"}},
+			want:   []string{"BarRecord", "BazRecord", "BazBuilder"},
+		},
+		{
+			name:   "plugins",
+			filter: Filter{Plugins: []string{"builder"}},
+			want:   []string{"FooBuilder", "BazBuilder"},
+		},
+		{
+			name:   "skip",
+			filter: Filter{Skip: []string{"builder"}},
+			want:   []string{"FooRecord", "BazRecord"},
+		},
+		{
+			name:   "preset",
+			filter: Filter{Plugins: []string{"entity"}},
+			want:   []string{"BazRecord", "BazBuilder"},
+		},
+		{
+			name:    "unknown type",
+			filter:  Filter{Types: []string{"Qux"}},
+			wantErr: "type Qux is not declared in src.go",
+		},
+		{
+			name:    "unknown plugin",
+			filter:  Filter{Skip: []string{"getters"}},
+			wantErr: `unknown plugin "getters"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "src.go", filterSrc, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := InspectGoFile(fset, nil, f)
+			err = p.SetFilter(tt.filter)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, err := p.GenerateCode("src_gen.go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := generatedFuncs(code)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTypeAt(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", filterSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := InspectGoFile(fset, nil, f)
+
+	for line, want := range map[int]string{1: "", 3: "Foo", 5: "Foo", 9: "Bar", 6: "", 14: "Baz"} {
+		got, _ := p.TypeAt(line)
+		if got != want {
+			t.Errorf("line %d: got %q, want %q", line, got, want)
+		}
+	}
+	if got := p.OwnedTypes(); strings.Join(got, ",") != "Bar" {
+		t.Errorf("got owned types %v, want [Bar]", got)
+	}
+}
+
+func TestGogDirectiveArgs(t *testing.T) {
+	tests := map[string]string{
+		"//go:generate gog":                                        "",
+		"//go:generate gog -type Foo":                              "-type,Foo",
+		"//go:generate go run github.com/quintans/gog@latest -q":   "-q",
+		"//go:generate go run golang.org/x/tools/cmd/stringer":     "-",
+		"// go:generate gog":                                       "-",
+		"//go:generate mockgen -destination gog_mock.go . Service": "-",
+	}
+	for comment, want := range tests {
+		args, ok := gogDirectiveArgs(comment)
+		got := strings.Join(args, ",")
+		if !ok {
+			got = "-"
+		}
+		if got != want {
+			t.Errorf("%s: got %q, want %q", comment, got, want)
+		}
+	}
+}
+
+func TestTypeFlag(t *testing.T) {
+	tests := map[string]string{
+		"-type Foo":          "Foo",
+		"-type=Foo,Bar":      "Foo,Bar",
+		"--type Foo, -q":     "Foo",
+		"-plugins record -q": "",
+	}
+	for args, want := range tests {
+		if got := strings.Join(typeFlag(strings.Fields(args)), ","); got != want {
+			t.Errorf("%s: got %q, want %q", args, got, want)
+		}
+	}
+}
+
+func TestLineFlag(t *testing.T) {
+	tests := map[string]bool{
+		"-line":         true,
+		"--line -q":     true,
+		"-line=false":   false,
+		"-type Foo":     false,
+		"-plugins line": false,
+	}
+	for args, want := range tests {
+		if got := lineFlag(strings.Fields(args)); got != want {
+			t.Errorf("%s: got %v, want %v", args, got, want)
+		}
+	}
+}
+
+func TestOwnedByTypeFlag(t *testing.T) {
+	src := "package p\n\n//go:generate gog -type Baz\n\n" + strings.TrimPrefix(filterSrc, "package p\n")
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := InspectGoFile(fset, nil, f)
+	if got := p.OwnedTypes(); strings.Join(got, ",") != "Bar,Baz" {
+		t.Errorf("got owned types %v, want [Bar Baz]", got)
+	}
+}
+
+func TestScanTypeFiles(t *testing.T) {
+	registerFuncPlugins(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.go")
+	if err := os.WriteFile(src, []byte(filterSrc), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the directive of Bar regenerates only Bar
+	ScanAndGenerateFile(dir, src, WithLine(9))
+	assertGenerated(t, dir, map[string]string{"src_bar_gen.go": "BarRecord"})
+
+	ScanAndGenerateFile(dir, src)
+	assertGenerated(t, dir, map[string]string{
+		"src_gen.go":     "FooRecord,FooBuilder,BazRecord,BazBuilder",
+		"src_bar_gen.go": "BarRecord",
+	})
+
+	// a type listed with -type, without its own directive, is generated with the rest of the file
+	ScanAndGenerateFile(dir, src, WithTypes("Foo"))
+	assertGenerated(t, dir, map[string]string{
+		"src_gen.go":     "FooRecord,FooBuilder,BazRecord,BazBuilder",
+		"src_bar_gen.go": "BarRecord",
+	})
+}
+
+// TestScanBareDirective checks that a directive in the doc of a type, without -type nor -line,
+// still generates the whole file into a single file
+func TestScanBareDirective(t *testing.T) {
+	registerFuncPlugins(t)
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.go")
+	bare := strings.Replace(filterSrc, "//go:generate gog -line", "//go:generate gog", 1)
+	if err := os.WriteFile(src, []byte(bare), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ScanAndGenerateFile(dir, src)
+	assertGenerated(t, dir, map[string]string{"src_gen.go": "FooRecord,FooBuilder,BarRecord,BazRecord,BazBuilder"})
+}
+
+func assertGenerated(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[string]string{}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), "_gen.go") {
+			continue
+		}
+		code, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[entry.Name()] = strings.Join(generatedFuncs(code), ",")
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got files %v, want %v", got, want)
+	}
+	for name, funcs := range want {
+		if got[name] != funcs {
+			t.Errorf("%s: got %s, want %s", name, got[name], funcs)
+		}
+	}
+}
+
+// generatedFuncs returns the names of the functions generated by funcPlugin
+func generatedFuncs(code []byte) []string {
+	funcs := []string{}
+	for _, line := range strings.Split(string(code), "\n") {
+		if strings.HasPrefix(line, "func ") {
+			name, _, _ := strings.Cut(strings.TrimPrefix(line, "func "), "(")
+			funcs = append(funcs, name)
+		}
+	}
+	return funcs
+}
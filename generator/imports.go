@@ -0,0 +1,372 @@
+package generator
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// qualifierPrefix starts the identifier that Code.Qual writes in place of a package name.
+// The identifier encodes the import path, and is replaced by the package name once all the imports are known.
+const qualifierPrefix = "_gogpkg_"
+
+// qualifier returns the identifier that stands for the package with the import path
+func qualifier(importPath string) string {
+	var b strings.Builder
+	b.WriteString(qualifierPrefix)
+	for i := 0; i < len(importPath); i++ {
+		c := importPath[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "_%02x", c)
+	}
+	return b.String()
+}
+
+// qualifierPath returns the import path encoded in the identifier written by qualifier
+func qualifierPath(ident string) (string, bool) {
+	if !strings.HasPrefix(ident, qualifierPrefix) {
+		return "", false
+	}
+	enc := ident[len(qualifierPrefix):]
+	var b strings.Builder
+	for i := 0; i < len(enc); i++ {
+		if enc[i] != '_' {
+			b.WriteByte(enc[i])
+			continue
+		}
+		if i+2 >= len(enc) {
+			return "", false
+		}
+		c, err := strconv.ParseUint(enc[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", false
+		}
+		b.WriteByte(byte(c))
+		i += 2
+	}
+	return b.String(), true
+}
+
+// Import is an import of the generated file
+type Import struct {
+	// Name is the explicit package name, empty if the package name is implied by the path
+	Name string
+	Path string
+}
+
+func (i Import) String() string {
+	if i.Name == "" {
+		return strconv.Quote(i.Path)
+	}
+	return i.Name + " " + strconv.Quote(i.Path)
+}
+
+// importResolver assigns the names of the packages referenced by the generated code.
+type importResolver struct {
+	// known maps the package names, as written in the source file and in the plugin imports, to the import path
+	known map[string]string
+	// taken are the identifiers that cannot be used as package names
+	taken map[string]bool
+	// names maps the import path to the package name
+	names map[string]string
+	// paths maps the package name to the import path
+	paths map[string]string
+	// declared maps the import path to the name declared by the package, when it is known without looking it up
+	declared map[string]string
+	// dir is the directory the packages are imported from
+	dir string
+}
+
+func newImportResolver(imports map[string]string, taken map[string]bool, dir string) *importResolver {
+	r := &importResolver{
+		taken:    taken,
+		names:    map[string]string{},
+		paths:    map[string]string{},
+		declared: map[string]string{},
+		dir:      dir,
+	}
+	known := map[string]string{}
+	for quoted, name := range imports {
+		importPath, err := strconv.Unquote(quoted)
+		if err != nil {
+			importPath = quoted
+		}
+		if name == "" {
+			name = r.packageName(importPath)
+		}
+		if name == "_" || name == "." {
+			continue
+		}
+		known[name] = importPath
+	}
+	r.known = known
+	return r
+}
+
+// resolvedNames caches the names declared by the packages, by the directory they are imported from and the import path
+var resolvedNames sync.Map
+
+// resolvedName returns the name declared by the package with the import path, as imported from the dir.
+// It is false if the package cannot be found.
+func resolvedName(dir, importPath string) (string, bool) {
+	key := dir + "\x00" + importPath
+	if name, ok := resolvedNames.Load(key); ok {
+		return name.(string), name != ""
+	}
+	name := ""
+	cfg := &packages.Config{
+		Mode: packages.NeedName,
+		Dir:  dir,
+		Env:  lookupEnv(),
+	}
+	if pkgs, err := packages.Load(cfg, importPath); err == nil && len(pkgs) == 1 && len(pkgs[0].Errors) == 0 {
+		name = pkgs[0].Name
+	}
+	resolvedNames.Store(key, name)
+	return name, name != ""
+}
+
+// lookupEnv is the environment of the go command that looks up the packages.
+// The lookup must not change go.mod nor download modules, so -mod flags are dropped and the module proxy is off.
+func lookupEnv() []string {
+	var flags []string
+	for _, flag := range strings.Fields(os.Getenv("GOFLAGS")) {
+		if !strings.HasPrefix(flag, "-mod=") {
+			flags = append(flags, flag)
+		}
+	}
+	return append(os.Environ(), "GOFLAGS="+strings.Join(flags, " "), "GOPROXY=off")
+}
+
+// declaredName returns the name declared by the package with the import path.
+// It is false if the package cannot be found.
+func (r *importResolver) declaredName(importPath string) (string, bool) {
+	if name, ok := r.declared[importPath]; ok {
+		return name, true
+	}
+	return resolvedName(r.dir, importPath)
+}
+
+// packageName returns the name declared by the package with the import path,
+// or the name guessed from the import path if the package cannot be found
+func (r *importResolver) packageName(importPath string) string {
+	if name, ok := r.declaredName(importPath); ok {
+		return name
+	}
+	return ImportPathToName(importPath)
+}
+
+// resolveImports replaces the package identifiers written by Code.Qual by non conflicting package names,
+// and returns the code with the imports that are used.
+func (p *Parser) resolveImports(code []byte) ([]byte, []Import, error) {
+	const header = "package p\n"
+	src := append([]byte(header), code...)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, nil, invalidCodeError(code, err, strings.Count(header, "\n"))
+	}
+
+	// packages are referenced by identifiers, that are not declared in the file, on the left of a selector
+	var qualified, sourced []*ast.Ident
+	taken := map[string]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if x, ok := n.X.(*ast.Ident); ok && x.Obj == nil {
+				if _, ok := qualifierPath(x.Name); ok {
+					qualified = append(qualified, x)
+				} else {
+					sourced = append(sourced, x)
+				}
+			}
+		case *ast.Ident:
+			// declared identifiers would shadow a package with the same name
+			if n.Obj != nil {
+				taken[n.Name] = true
+			}
+		}
+		return true
+	})
+	if p.parsedFile != nil {
+		for _, decl := range p.parsedFile.Decls {
+			for _, name := range declNames(decl) {
+				taken[name] = true
+			}
+		}
+	}
+
+	r := newImportResolver(p.Imports, taken, p.sourceDir())
+	for _, x := range sourced {
+		if importPath, ok := r.known[x.Name]; ok {
+			r.assign(importPath, x.Name)
+		}
+	}
+
+	// in out of package mode, the declarations of the source package are qualified with its package name
+	var refs []*ast.Ident
+	if p.outOfPackage() {
+		refs = p.sourceRefs(file)
+		r.declared[p.sourcePath] = p.parsedFile.Name.Name
+		if _, ok := r.names[p.sourcePath]; !ok && len(refs) > 0 {
+			r.assign(p.sourcePath, r.freeName(p.parsedFile.Name.Name))
+		}
+	}
+
+	qualPaths := []string{}
+	for _, x := range qualified {
+		importPath, _ := qualifierPath(x.Name)
+		if _, ok := r.names[importPath]; !ok && !Contains(qualPaths, importPath) {
+			qualPaths = append(qualPaths, importPath)
+		}
+	}
+	sort.Strings(qualPaths)
+	for _, importPath := range qualPaths {
+		r.assign(importPath, r.freeName(r.packageName(importPath)))
+	}
+
+	edits := make([]edit, 0, len(qualified)+len(refs))
+	for _, x := range qualified {
+		importPath, _ := qualifierPath(x.Name)
+		offset := fset.Position(x.Pos()).Offset
+		edits = append(edits, edit{offset: offset, end: offset + len(x.Name), text: r.names[importPath]})
+	}
+	for _, ident := range refs {
+		offset := fset.Position(ident.Pos()).Offset
+		edits = append(edits, edit{offset: offset, end: offset, text: r.names[p.sourcePath] + "."})
+	}
+	src = applyEdits(src, edits)
+
+	return src[len(header):], r.imports(), nil
+}
+
+// edit replaces the source between the offsets by the text
+type edit struct {
+	offset int
+	end    int
+	text   string
+}
+
+// applyEdits applies the edits, from the end so that the offsets of the remaining edits are kept
+func applyEdits(src []byte, edits []edit) []byte {
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].offset > edits[j].offset
+	})
+	for _, e := range edits {
+		src = append(src[:e.offset:e.offset], append([]byte(e.text), src[e.end:]...)...)
+	}
+	return src
+}
+
+// assign names the package with the import path
+func (r *importResolver) assign(importPath, name string) {
+	r.names[importPath] = name
+	r.paths[name] = importPath
+}
+
+// freeName returns the name, or the name with a numeric suffix, that is not used by another package or identifier
+func (r *importResolver) freeName(name string) string {
+	candidate := name
+	for k := 2; r.inUse(candidate); k++ {
+		candidate = name + strconv.Itoa(k)
+	}
+	return candidate
+}
+
+func (r *importResolver) inUse(name string) bool {
+	if _, ok := r.paths[name]; ok {
+		return true
+	}
+	return r.taken[name] || token.Lookup(name).IsKeyword()
+}
+
+// imports returns the imports sorted by path, the standard library first.
+// The package name is explicit when it differs from the name declared by the package, or when the package cannot be found.
+func (r *importResolver) imports() []Import {
+	imps := make([]Import, 0, len(r.names))
+	for importPath, name := range r.names {
+		imp := Import{Path: importPath}
+		if declared, ok := r.declaredName(importPath); !ok || name != declared {
+			imp.Name = name
+		}
+		imps = append(imps, imp)
+	}
+	sort.Slice(imps, func(i, j int) bool {
+		si, sj := isStdLib(imps[i].Path), isStdLib(imps[j].Path)
+		if si != sj {
+			return si
+		}
+		return imps[i].Path < imps[j].Path
+	})
+	return imps
+}
+
+func isStdLib(importPath string) bool {
+	first, _, _ := strings.Cut(importPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+// writeImports writes the import declaration, with the standard library in a separate group
+func writeImports(s *Scribler, imps []Import) {
+	switch len(imps) {
+	case 0:
+		return
+	case 1:
+		s.HPrintf("import %s\n", imps[0])
+		return
+	}
+	s.HPrint("import (\n")
+	for k, imp := range imps {
+		if k > 0 && isStdLib(imps[k-1].Path) && !isStdLib(imp.Path) {
+			s.HPrint("\n")
+		}
+		s.HPrintf("%s\n", imp)
+	}
+	s.HPrint(")\n")
+}
+
+// declNames returns the names declared at the top level by the declaration
+func declNames(decl ast.Decl) []string {
+	var names []string
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		if d.Recv == nil {
+			names = append(names, d.Name.Name)
+		}
+	case *ast.GenDecl:
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *ast.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *ast.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, n.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// sourceDir returns the directory of the source file, where its imports are resolved from
+func (p *Parser) sourceDir() string {
+	dir := "."
+	if p.parsedFile != nil {
+		if name := p.fset.Position(p.parsedFile.Pos()).Filename; name != "" {
+			dir = filepath.Dir(name)
+		}
+	}
+	return absPath(dir)
+}
@@ -0,0 +1,268 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/quintans/gog/config"
+)
+
+func TestQualifier(t *testing.T) {
+	for _, path := range []string{"errors", "net/http", "gopkg.in/yaml.v3", "github.com/mattn/go-sqlite3", "example.com/a_b"} {
+		got, ok := qualifierPath(qualifier(path))
+		if !ok || got != path {
+			t.Errorf("%s: got %s, %t", path, got, ok)
+		}
+	}
+	if _, ok := qualifierPath("errors"); ok {
+		t.Error("errors is not a qualifier")
+	}
+}
+
+type qualPlugin struct {
+	Code
+	body func(c *Code, mapper Mapper) Decl
+}
+
+func (*qualPlugin) Name() string {
+	return "qual"
+}
+
+func (*qualPlugin) Accepts() []MapperType {
+	return []MapperType{StructMapper}
+}
+
+func (*qualPlugin) Imports(Mapper) map[string]string {
+	return map[string]string{}
+}
+
+func (q *qualPlugin) GenerateBody(mapper Mapper) error {
+	q.Emit(q.body(&q.Code, mapper))
+	return nil
+}
+
+func TestResolveImports(t *testing.T) {
+	saved := generators
+	t.Cleanup(func() {
+		generators = saved
+	})
+
+	tests := []struct {
+		name string
+		in   string
+		body func(c *Code, mapper Mapper) Decl
+		want string
+	}{
+		{
+			name: "unused source imports are removed",
+			in: `package p
+
+import (
+	"strings"
+	"time"
+)
+
+// gog:qual
+type Foo struct {
+	when time.Time
+}
+`,
+			body: func(c *Code, mapper Mapper) Decl {
+				return &Func{
+					Name:    "When",
+					Results: []Param{{Type: mapper.GetFields()[0].Kind.String()}},
+					Body:    []Stmt{Return(Call(Id(c.Qual("time", "Now"))))},
+				}
+			},
+			want: `import "time"
+
+// Generated by gog:qual
+
+func When() time.Time {
+	return time.Now()
+}
+`,
+		},
+		{
+			name: "conflict with a source import",
+			in: `package p
+
+import "example.com/foo/errors"
+
+// gog:qual
+type Foo struct {
+	code errors.Code
+}
+`,
+			body: func(c *Code, mapper Mapper) Decl {
+				return &Func{
+					Name:    "Check",
+					Params:  []Param{{Name: "code", Type: mapper.GetFields()[0].Kind.String()}},
+					Results: []Param{{Type: "error"}},
+					Body:    []Stmt{Return(Call(Id(c.Qual("errors", "New")), Quote("invalid")))},
+				}
+			},
+			want: `import (
+	errors2 "errors"
+
+	errors "example.com/foo/errors"
+)
+
+// Generated by gog:qual
+
+func Check(code errors.Code) error {
+	return errors2.New("invalid")
+}
+`,
+		},
+		{
+			name: "conflict with a declared identifier",
+			in: `package p
+
+// gog:qual
+type Foo struct{}
+`,
+			body: func(c *Code, mapper Mapper) Decl {
+				return &Func{
+					Name:    "Join",
+					Params:  []Param{{Name: "strings", Type: "[]string"}},
+					Results: []Param{{Type: "string"}},
+					Body:    []Stmt{Return(Call(Id(c.Qual("strings", "Join")), Id("strings"), Quote(",")))},
+				}
+			},
+			want: `import strings2 "strings"
+
+// Generated by gog:qual
+
+func Join(strings []string) string {
+	return strings2.Join(strings, ",")
+}
+`,
+		},
+		{
+			name: "packages with the same name",
+			in: `package p
+
+// gog:qual
+type Foo struct{}
+`,
+			body: func(c *Code, mapper Mapper) Decl {
+				return &Func{
+					Name: "Wrap",
+					Body: []Stmt{
+						Do(Call(Id(c.Qual("github.com/pkg/errors", "Wrap")), Call(Id(c.Qual("errors", "New")), Quote("a")), Quote("b"))),
+						Do(Call(Id(c.Qual("gopkg.in/yaml.v3", "Marshal")), Id("nil"))),
+					},
+				}
+			},
+			want: `import (
+	"errors"
+
+	errors2 "github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Generated by gog:qual
+
+func Wrap() {
+	errors2.Wrap(errors.New("a"), "b")
+	yaml.Marshal(nil)
+}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			UnregisterAll()
+			MustRegister(&qualPlugin{body: tt.body})
+
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "src.go", tt.in, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := InspectGoFile(fset, nil, f).GenerateCode("src_gog.go")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := generatedHeader + "\n// Version: " + config.Version + "\npackage p\n\n" + tt.want
+			if got := string(code); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestResolveImportsPackageName(t *testing.T) {
+	saved := generators
+	t.Cleanup(func() {
+		generators = saved
+	})
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"go.mod":       "module example.com/m\n\ngo 1.21\n",
+		"lib/lib.go":   "package other\n\nfunc Do() {}\n",
+		"src/src.go":   "package src\n",
+		"go-util/u.go": "package util\n\nfunc Do() {}\n",
+	} {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(name)), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	src := `package src
+
+import "example.com/m/lib"
+
+// gog:qual
+type Foo struct{}
+
+var _ = other.Do
+`
+
+	UnregisterAll()
+	MustRegister(&qualPlugin{body: func(c *Code, mapper Mapper) Decl {
+		return &Func{
+			Name: "Do",
+			Body: []Stmt{
+				Do(Call(Id(c.Qual("example.com/m/lib", "Do")))),
+				Do(Call(Id(c.Qual("example.com/m/go-util", "Do")))),
+				Do(Call(Id(c.Qual("example.com/unknown/pkg", "Do")))),
+			},
+		}
+	}})
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, filepath.Join(dir, "src", "src.go"), src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := InspectGoFile(fset, nil, f).GenerateCode(filepath.Join(dir, "src", "src_gog.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := generatedHeader + "\n// Version: " + config.Version + "\npackage src\n\n" + `import (
+	"example.com/m/go-util"
+	"example.com/m/lib"
+	pkg "example.com/unknown/pkg"
+)
+
+// Generated by gog:qual
+
+func Do() {
+	other.Do()
+	util.Do()
+	pkg.Do()
+}
+`
+	if got := string(code); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
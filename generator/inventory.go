@@ -0,0 +1,221 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// TypeInventory is a tagged type and what gog generates for it
+type TypeInventory struct {
+	Name string     `json:"name"`
+	Kind MapperType `json:"kind"`
+	File string     `json:"file"`
+	Line int        `json:"line"`
+	// Tags are the gog tags written in the doc of the type
+	Tags []string `json:"tags"`
+	// Plugins are the plugins that run for the type, after inheriting the package tags,
+	// expanding the presets and applying the package defaults
+	Plugins []PluginUse `json:"plugins"`
+}
+
+// PluginUse is a plugin that runs for a type
+type PluginUse struct {
+	Name string `json:"name"`
+	// Preset is the preset the plugin comes from, if any
+	Preset string `json:"preset,omitempty"`
+	// Inherited is true if the plugin comes from the package doc
+	Inherited bool                   `json:"inherited,omitempty"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	// Symbols are the declarations generated by the plugin, eg: NewFoo or Foo.Name for a method
+	Symbols []string `json:"symbols,omitempty"`
+	// Error is why the plugin cannot generate the type, eg: it is not registered
+	Error string `json:"error,omitempty"`
+}
+
+// Failed returns true if any plugin of the type cannot generate it
+func (t TypeInventory) Failed() bool {
+	for _, p := range t.Plugins {
+		if p.Error != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Inventory returns the tagged types of the file, with the plugins that run for them and what each one generates.
+// The code is generated only to find out the declared symbols, and nothing is written.
+func (p *Parser) Inventory() ([]TypeInventory, error) {
+	written := make([]Tags, len(p.Mappers))
+	for k, mapper := range p.Mappers {
+		written[k] = mapper.GetTags()
+	}
+	if err := p.ResolveTags(); err != nil {
+		return nil, err
+	}
+
+	inventory := []TypeInventory{}
+	for k, mapper := range p.Mappers {
+		tags := mapper.GetTags()
+		if len(tags) == 0 && len(written[k]) == 0 {
+			continue
+		}
+		t := TypeInventory{
+			Name:    mapper.GetName(),
+			Kind:    mapper.Type(),
+			Tags:    []string{},
+			Plugins: []PluginUse{},
+		}
+		if decl, ok := p.typeDecls[t.Name]; ok {
+			t.File, t.Line = p.fileName(), decl.line
+		}
+		for _, tag := range written[k] {
+			t.Tags = append(t.Tags, strings.TrimSpace(tag.Name+" "+tag.Args))
+		}
+		for _, tag := range tags {
+			t.Plugins = append(t.Plugins, p.pluginUse(mapper, tag))
+		}
+		inventory = append(inventory, t)
+	}
+	return inventory, nil
+}
+
+func (p *Parser) pluginUse(mapper Mapper, tag Tag) PluginUse {
+	use := PluginUse{Name: tag.Name, Preset: tag.Preset, Inherited: tag.Inherited}
+	reg, ok := p.generators[tag.Name]
+	if !ok {
+		use.Error = fmt.Sprintf("unknown plugin %q", tag.Name)
+		if suggestion, ok := p.closestTagName(tag.Name); ok {
+			use.Error += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+		return use
+	}
+	if !Contains(reg.plugin.Accepts(), mapper.Type()) {
+		use.Error = fmt.Sprintf("plugin %s can't handle %s", tag.Name, mapper.Type())
+		return use
+	}
+
+	args, err := tag.ParseArgs()
+	if err != nil {
+		use.Error = err.Error()
+		return use
+	}
+	if len(args) > 0 {
+		use.Options = args.toMap(reg.info.Options)
+	}
+	if reg.info.Options != nil {
+		if err := reg.info.Options.Validate(tag.Args); err != nil {
+			use.Error = err.Error()
+			return use
+		}
+	}
+	if reg.info.Check != nil {
+		if issues := reg.info.Check(mapper); len(issues) > 0 {
+			use.Error = issues[0].Message
+			return use
+		}
+	}
+
+	err = generateBody(reg.plugin, mapper)
+	code := reg.plugin.Flush()
+	if err != nil {
+		use.Error = err.Error()
+		return use
+	}
+	symbols, err := declaredSymbols(code)
+	if err != nil {
+		use.Error = err.Error()
+		return use
+	}
+	use.Symbols = symbols
+	return use
+}
+
+// declaredSymbols returns the top level declarations of the generated code.
+// Methods are qualified by the receiver type, eg: Foo.Name
+func declaredSymbols(code []byte) ([]string, error) {
+	const header = "package p\n"
+	file, err := parser.ParseFile(token.NewFileSet(), "", append([]byte(header), code...), 0)
+	if err != nil {
+		return nil, invalidCodeError(code, err, strings.Count(header, "\n"))
+	}
+
+	var symbols []string
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			name := d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) == 1 {
+				name = receiverName(d.Recv.List[0].Type) + "." + name
+			}
+			symbols = append(symbols, name)
+		case *ast.GenDecl:
+			for _, name := range declNames(d) {
+				// eg: var _ Interface = (*Foo)(nil)
+				if name != "_" {
+					symbols = append(symbols, name)
+				}
+			}
+		}
+	}
+	return symbols, nil
+}
+
+// receiverName is the name of the receiver type, without the pointer and the type parameters
+func receiverName(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// ListFile returns the inventory of the tagged types of the go file
+func ListFile(workDir, fullFileName string) ([]TypeInventory, error) {
+	p, err := parseSource(relativeDir(workDir, fullFileName), fullFileName, nil)
+	if err != nil {
+		return nil, err
+	}
+	return p.Inventory()
+}
+
+// String describes the type in a line, followed by a line for each plugin
+func (t TypeInventory) String() string {
+	s := &Scribler{}
+	s.BPrintf("%s:%d: %s %s", t.File, t.Line, t.Kind, t.Name)
+	if len(t.Tags) > 0 {
+		s.BPrintf(" [%s]", strings.Join(t.Tags, "; "))
+	}
+	s.BPrintln()
+	for _, use := range t.Plugins {
+		s.BPrintf("\t%s", use.Name)
+		if use.Preset != "" {
+			s.BPrintf(" (from preset %s)", use.Preset)
+		}
+		if use.Inherited {
+			s.BPrint(" (from package)")
+		}
+		if len(use.Options) > 0 {
+			options, _ := json.Marshal(use.Options)
+			s.BPrintf(" %s", options)
+		}
+		if use.Error != "" {
+			s.BPrintf(": error: %s\n", use.Error)
+			continue
+		}
+		s.BPrintf(": %s\n", strings.Join(use.Symbols, ", "))
+	}
+	return s.String()
+}
@@ -0,0 +1,91 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestInventory(t *testing.T) {
+	registerFuncPlugins(t)
+
+	src := `package p
+
+// gog:entity
+type Foo struct{}
+
+// gog:record
+// gog:recrd
+type Bar struct{}
+
+type Baz struct{}
+
+// gog:builder
+type Qux interface{}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := InspectGoFile(fset, nil, f).Inventory()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []TypeInventory{
+		{
+			Name: "Foo", Kind: StructMapper, File: "src.go", Line: 4,
+			Tags: []string{"entity"},
+			Plugins: []PluginUse{
+				{Name: "record", Preset: "entity", Symbols: []string{"FooRecord"}},
+				{Name: "builder", Preset: "entity", Symbols: []string{"FooBuilder"}},
+			},
+		},
+		{
+			Name: "Bar", Kind: StructMapper, File: "src.go", Line: 8,
+			Tags: []string{"record", "recrd"},
+			Plugins: []PluginUse{
+				{Name: "record", Symbols: []string{"BarRecord"}},
+				{Name: "recrd", Error: `unknown plugin "recrd", did you mean "record"?`},
+			},
+		},
+		{
+			Name: "Qux", Kind: InterfaceMapper, File: "src.go", Line: 13,
+			Tags: []string{"builder"},
+			Plugins: []PluginUse{
+				{Name: "builder", Error: "plugin builder can't handle interface"},
+			},
+		},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("inventory mismatch (-want +got):\n%s", diff)
+	}
+	if got[0].Failed() || !got[1].Failed() {
+		t.Errorf("got failed %v and %v, want false and true", got[0].Failed(), got[1].Failed())
+	}
+}
+
+func TestDeclaredSymbols(t *testing.T) {
+	code := `
+type FooBuilder struct{}
+
+func NewFooBuilder() *FooBuilder { return nil }
+
+func (b *FooBuilder) Build() Foo { return Foo{} }
+
+func (f Foo[T]) Name() string { return "" }
+
+var _ = 1
+`
+	got, err := declaredSymbols([]byte(code))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"FooBuilder", "NewFooBuilder", "FooBuilder.Build", "Foo.Name"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("symbols mismatch (-want +got):\n%s", diff)
+	}
+}
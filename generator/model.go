@@ -2,7 +2,9 @@ package generator
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go/token"
 	"strings"
 )
 
@@ -18,6 +20,7 @@ const (
 type Mapper interface {
 	Type() MapperType
 	GetTags() Tags
+	SetTags(Tags)
 	GetName() string
 	GetFields() []Field
 	GetMethods() []Method
@@ -44,6 +47,10 @@ func (s *Struct) GetTags() Tags {
 	return s.Tags
 }
 
+func (s *Struct) SetTags(tags Tags) {
+	s.Tags = tags
+}
+
 func (s *Struct) GetName() string {
 	return s.Name
 }
@@ -93,6 +100,10 @@ func (s *Interface) GetTags() Tags {
 	return s.Tags
 }
 
+func (s *Interface) SetTags(tags Tags) {
+	s.Tags = tags
+}
+
 func (s *Interface) GetName() string {
 	return s.Name
 }
@@ -131,6 +142,8 @@ type Method struct {
 	FuncName string
 	Args     []Field
 	Results  []Field
+	// Pos is the position of the method name
+	Pos token.Pos
 }
 
 func (m *Method) IsExported() bool {
@@ -272,6 +285,10 @@ type Field struct {
 	Tags
 	Name string
 	Kind Kinder
+	// Pos is the position of the field
+	Pos token.Pos
+	// Mapper is the struct or interface, declared in the same file, that is the type of the field or that the field points to
+	Mapper Mapper
 }
 
 func (f Field) String() string {
@@ -435,6 +452,45 @@ func (m Map) Zero() string {
 	return zeroNil
 }
 
+// Variadic is the type of the last parameter of a variadic func, eg: ...string
+type Variadic struct {
+	Kinder
+}
+
+func (v Variadic) String() string {
+	return "..." + v.Kinder.String()
+}
+
+func (Variadic) ZeroCondition(field string) string {
+	return fmt.Sprintf("len(%s) == 0", field)
+}
+
+func (Variadic) Zero() string {
+	return zeroNil
+}
+
+// TypeExpr is a type without a kind of its own, like chan int, [2]int, struct{...} or List[int], as written in the source.
+// Its zero value is *new(T), since it is not known if it is a struct, a number or a pointer.
+type TypeExpr struct {
+	Source string
+}
+
+func (e TypeExpr) Name() string {
+	return e.Source
+}
+
+func (e TypeExpr) String() string {
+	return e.Source
+}
+
+func (e TypeExpr) ZeroCondition(field string) string {
+	return fmt.Sprintf("%s == %s", field, e.Zero())
+}
+
+func (e TypeExpr) Zero() string {
+	return fmt.Sprintf("*new(%s)", e.Source)
+}
+
 type InterfaceVar struct {
 	Tags
 	Pck     string
@@ -468,14 +524,66 @@ func (b *InterfaceVar) Zero() string {
 
 type Tag struct {
 	Name string
+	// Args are the raw arguments. Arguments spanning several comment lines are joined by a new line.
 	Args string
+	// Pos is the position of the tag comment
+	Pos token.Pos
+	// Preset is the name of the preset the tag was expanded from, if any
+	Preset string
+	// Inherited is true if the tag comes from the package doc
+	Inherited bool
+	// argLines has the position of each comment line of the arguments
+	argLines []argLine
+}
+
+type argLine struct {
+	offset int
+	pos    token.Pos
+}
+
+// ParseArgs parses the tag arguments. See ParseTagArgs
+func (t Tag) ParseArgs() (TagArgs, error) {
+	return ParseTagArgs(t.Args)
 }
 
+// Unmarshal reads the tag arguments into v, like json.Unmarshal would do
 func (t Tag) Unmarshal(v interface{}) error {
-	if t.Args == "" {
+	args, err := t.ParseArgs()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
 		return nil
 	}
-	return json.Unmarshal([]byte(t.Args), v)
+	// the schema is used to read unquoted values into string fields
+	schema, _ := SchemaOf(v)
+	data, err := args.JSON(schema)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ArgPos returns the position in the source of the offset in the arguments
+func (t Tag) ArgPos(offset int) token.Pos {
+	pos := t.Pos
+	for _, line := range t.argLines {
+		if line.offset > offset {
+			break
+		}
+		pos = line.pos + token.Pos(offset-line.offset)
+	}
+	return pos
+}
+
+// ErrorPos returns the position of the error. For an *ArgError it is the position of the offending argument
+// otherwise it is the position of the tag.
+func (t Tag) ErrorPos(err error) token.Pos {
+	var argErr *ArgError
+	if errors.As(err, &argErr) {
+		return t.ArgPos(argErr.Offset)
+	}
+	return t.Pos
 }
 
 type Tags []Tag
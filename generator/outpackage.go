@@ -0,0 +1,225 @@
+package generator
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SetOutPackage makes the generated code belong to another package, with the name,
+// that imports the source package with the import path.
+// Only the plugins registered WithOutOfPackage can generate into another package.
+func (p *Parser) SetOutPackage(name, sourcePath string) {
+	p.outPackage = name
+	p.sourcePath = sourcePath
+}
+
+func (p *Parser) outOfPackage() bool {
+	return p.outPackage != ""
+}
+
+func (p *Parser) packageName() string {
+	if p.outOfPackage() {
+		return p.outPackage
+	}
+	return p.parsedFile.Name.Name
+}
+
+// AddPackageNames adds the names declared at the top level by other files of the source package.
+// In out of package mode, the references to these names are qualified with the source package.
+func (p *Parser) AddPackageNames(names ...string) {
+	for _, name := range names {
+		p.packageNames[name] = true
+	}
+}
+
+// ReadPackageNames reads the names declared at the top level by the other go files in the same directory of gofile
+func ReadPackageNames(fset *token.FileSet, gofile string) ([]string, error) {
+	dir := filepath.Dir(gofile)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isGoSource(name) || name == filepath.Base(gofile) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range f.Decls {
+			names = append(names, declNames(decl)...)
+		}
+	}
+	return names, nil
+}
+
+// sourceRefs returns the identifiers of the generated code that refer to the declarations of the source package
+func (p *Parser) sourceRefs(file *ast.File) []*ast.Ident {
+	// identifiers that are not references, but are not declared either
+	skip := map[*ast.Ident]bool{}
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			skip[n.Sel] = true
+		case *ast.FuncDecl:
+			skip[n.Name] = true
+		case *ast.CompositeLit:
+			if _, ok := n.Type.(*ast.MapType); ok {
+				break
+			}
+			for _, elt := range n.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok {
+						skip[key] = true
+					}
+				}
+			}
+		case *ast.BranchStmt:
+			skip[n.Label] = true
+		case *ast.LabeledStmt:
+			skip[n.Label] = true
+		}
+		return true
+	})
+
+	var refs []*ast.Ident
+	ast.Inspect(file, func(n ast.Node) bool {
+		if ident, ok := n.(*ast.Ident); ok && ident.Obj == nil && !skip[ident] && p.packageNames[ident.Name] {
+			refs = append(refs, ident)
+		}
+		return true
+	})
+	return refs
+}
+
+// checkOutOfPackage checks that the code generated by a plugin only uses the exported API of the source package
+func (p *Parser) checkOutOfPackage(code []byte) error {
+	const header = "package p\n"
+	src := append([]byte(header), code...)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, 0)
+	if err != nil {
+		return invalidCodeError(code, err, strings.Count(header, "\n"))
+	}
+
+	pkg := p.parsedFile.Name.Name
+	// the unexported methods of the source types cannot be called from another package either
+	methods := map[string]bool{}
+	for _, decl := range p.parsedFile.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Recv != nil && !token.IsExported(fn.Name.Name) {
+			methods[fn.Name.Name] = true
+		}
+	}
+	refs := map[*ast.Ident]bool{}
+	for _, ident := range p.sourceRefs(file) {
+		refs[ident] = true
+	}
+	fail := func(node ast.Node, format string, args ...interface{}) error {
+		line := fset.Position(node.Pos()).Line - strings.Count(header, "\n")
+		return fmt.Errorf("cannot generate into package %s: %s\n%s", p.outPackage, fmt.Sprintf(format, args...), codeContext(string(code), line))
+	}
+
+	var outErr error
+	ast.Inspect(file, func(n ast.Node) bool {
+		if outErr != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Recv == nil || len(n.Recv.List) == 0 {
+				break
+			}
+			typ := n.Recv.List[0].Type
+			if star, ok := typ.(*ast.StarExpr); ok {
+				typ = star.X
+			}
+			if ident, ok := typ.(*ast.Ident); ok && refs[ident] {
+				outErr = fail(n, "methods cannot be declared on %s outside of package %s", ident.Name, pkg)
+			}
+		case *ast.SelectorExpr:
+			if methods[n.Sel.Name] {
+				outErr = fail(n.Sel, "method %s is not exported by package %s", n.Sel.Name, pkg)
+			}
+		case *ast.CompositeLit:
+			ident, ok := n.Type.(*ast.Ident)
+			if !ok || !refs[ident] {
+				break
+			}
+			for _, elt := range n.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok && !token.IsExported(key.Name) {
+						outErr = fail(key, "field %s.%s is not exported by package %s", ident.Name, key.Name, pkg)
+						return false
+					}
+				}
+			}
+		case *ast.Ident:
+			if refs[n] && !token.IsExported(n.Name) {
+				outErr = fail(n, "%s is not exported by package %s", n.Name, pkg)
+			}
+		}
+		return outErr == nil
+	})
+	return outErr
+}
+
+// ImportPath returns the import path of the package in dir, from the module path declared in go.mod
+func ImportPath(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for root := dir; ; {
+		module, err := modulePath(filepath.Join(root, "go.mod"))
+		if err == nil {
+			rel, err := filepath.Rel(root, dir)
+			if err != nil {
+				return "", err
+			}
+			return path.Join(module, filepath.ToSlash(rel)), nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return "", fmt.Errorf("no go.mod found for %s", dir)
+		}
+		root = parent
+	}
+}
+
+func modulePath(gomod string) (string, error) {
+	file, err := os.Open(gomod)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "module") {
+			module := strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "module")), `"`)
+			if module != "" {
+				return module, nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("%s: missing module declaration", gomod)
+}
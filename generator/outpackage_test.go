@@ -0,0 +1,174 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/quintans/gog/config"
+)
+
+func TestOutOfPackage(t *testing.T) {
+	saved := generators
+	t.Cleanup(func() {
+		generators = saved
+	})
+
+	src := `package foo
+
+import "time"
+
+// gog:qual
+type Foo struct {
+	Bar
+	When time.Time
+	name string
+}
+
+func (f Foo) validate() error {
+	return nil
+}
+`
+	decorator := func(c *Code, mapper Mapper) Decl {
+		return &StructDecl{
+			Name:   mapper.GetName() + "Decorator",
+			Fields: []Param{{Name: "Next", Type: "*" + mapper.GetName()}, {Name: "bar", Type: "Bar"}},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		outOfPackage bool
+		body         func(c *Code, mapper Mapper) Decl
+		want         string
+		wantErr      string
+	}{
+		{
+			name:         "qualified references",
+			outOfPackage: true,
+			body:         decorator,
+			want: `import "example.com/mod/foo"
+
+// Generated by gog:qual
+
+type FooDecorator struct {
+	Next *foo.Foo
+	bar  foo.Bar
+}
+`,
+		},
+		{
+			name:    "plugin needs unexported access",
+			body:    decorator,
+			wantErr: "src.go:5:1: qual: cannot generate into package out: the plugin needs access to the unexported API of package foo",
+		},
+		{
+			name:         "unexported field",
+			outOfPackage: true,
+			body: func(c *Code, mapper Mapper) Decl {
+				return &Func{
+					Name:    "New" + mapper.GetName(),
+					Results: []Param{{Type: mapper.GetName()}},
+					Body:    []Stmt{Return(Lit(mapper.GetName(), KeyValue{Key: "name", Value: Quote("a")}))},
+				}
+			},
+			wantErr: "field Foo.name is not exported by package foo",
+		},
+		{
+			name:         "method of a source type",
+			outOfPackage: true,
+			body: func(c *Code, mapper Mapper) Decl {
+				return &Func{
+					Recv:    &Param{Name: "f", Type: mapper.GetName()},
+					Name:    "Name",
+					Results: []Param{{Type: "string"}},
+					Body:    []Stmt{Return(Quote("foo"))},
+				}
+			},
+			wantErr: "methods cannot be declared on Foo outside of package foo",
+		},
+		{
+			name:         "unexported declaration",
+			outOfPackage: true,
+			body: func(c *Code, mapper Mapper) Decl {
+				return &Func{
+					Name:    "Default",
+					Results: []Param{{Type: "string"}},
+					Body:    []Stmt{Return(Id("defaultName"))},
+				}
+			},
+			wantErr: "defaultName is not exported by package foo",
+		},
+		{
+			name:         "unexported method",
+			outOfPackage: true,
+			body: func(c *Code, mapper Mapper) Decl {
+				return &Func{
+					Name:    "Validate",
+					Params:  []Param{{Name: "f", Type: mapper.GetName()}},
+					Results: []Param{{Type: "error"}},
+					Body:    []Stmt{Return(Call(Sel(Id("f"), "validate")))},
+				}
+			},
+			wantErr: "method validate is not exported by package foo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			UnregisterAll()
+			var opts []RegisterOption
+			if tt.outOfPackage {
+				opts = append(opts, WithOutOfPackage())
+			}
+			MustRegister(&qualPlugin{body: tt.body}, opts...)
+
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := InspectGoFile(fset, nil, f)
+			p.SetOutPackage("out", "example.com/mod/foo")
+			p.AddPackageNames("Bar", "defaultName")
+			code, err := p.GenerateCode("out/src_gen.go")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got error %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := generatedHeader + "\n// Version: " + config.Version + "\npackage out\n\n" + tt.want
+			if got := string(code); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestImportPath(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "go.mod"), []byte("module example.com/mod\n\ngo 1.19\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for dir, want := range map[string]string{root: "example.com/mod", dir: "example.com/mod/a/b"} {
+		got, err := ImportPath(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package generator
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// defaultsTag sets, in the package doc, the default options of a plugin, eg: `// gog:defaults getters pointer=true`
+	defaultsTag = "defaults"
+	// optOutPrefix removes, in a type doc, a tag inherited from the package doc, eg: `// gog:-record`
+	optOutPrefix = "-"
+)
+
+// tag priorities, when the same tag is present more than once
+const (
+	explicitPriority = iota
+	presetPriority
+	inheritedPriority
+)
+
+// AddPackageTags adds the tags of the package doc of other files of the package, like doc.go.
+// Package tags are inherited by all the types of the package.
+func (p *Parser) AddPackageTags(tags Tags) {
+	p.packageTags = append(p.packageTags, tags...)
+}
+
+// ReadPackageTags reads the tags in the package doc of the other go files in the same directory of gofile
+func ReadPackageTags(fset *token.FileSet, gofile string) (Tags, error) {
+	return readPackageTags(fset, filepath.Dir(gofile), filepath.Base(gofile))
+}
+
+// readPackageTags reads the tags in the package doc of the go files in the dir, except the file skip and the files generated by gog
+func readPackageTags(fset *token.FileSet, dir, skip string) (Tags, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := Tags{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !isGoSource(name) || name == skip || isGenerated(filepath.Join(dir, name)) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, extractTagsFromDoc(f.Doc)...)
+	}
+	return tags, nil
+}
+
+// PackageDocTags returns the tags in the package doc of the file
+func PackageDocTags(file *ast.File) Tags {
+	return extractTagsFromDoc(file.Doc)
+}
+
+func isGoSource(name string) bool {
+	return strings.HasSuffix(name, goFilesExt) && !strings.HasSuffix(name, goTestFilesExt)
+}
+
+// packageDefaults splits the package tags into the ones inherited by the types and the default options of the plugins
+func (p *Parser) packageDefaults() (inherited Tags, defaults map[string]Tag, err error) {
+	defaults = map[string]Tag{}
+	for _, tag := range p.packageTags {
+		if tag.Name != defaultsTag {
+			tag.Inherited = true
+			inherited = append(inherited, tag)
+			continue
+		}
+
+		name, args, _ := strings.Cut(strings.TrimSpace(tag.Args), " ")
+		if name == "" {
+			return nil, nil, p.diagnostic(tag.Pos, "", "missing the plugin name in %s", defaultsTag)
+		}
+		if _, ok := defaults[name]; ok {
+			return nil, nil, p.diagnostic(tag.Pos, "", "duplicate %s for %s", defaultsTag, name)
+		}
+		defaults[name] = Tag{
+			Name: name,
+			Args: strings.TrimSpace(args),
+			Pos:  tag.Pos,
+		}
+	}
+	return inherited, defaults, nil
+}
+
+// misplacedDefaults is the error of a defaults tag outside of the package doc, where it would have no effect
+func (p *Parser) misplacedDefaults(tag Tag) Diagnostic {
+	return p.diagnostic(tag.Pos, "", "%s is only allowed in the package doc", defaultsTag)
+}
+
+// inheritTags adds the package tags that are not declared in the type
+func inheritTags(tags, inherited Tags) Tags {
+	result := make(Tags, 0, len(tags)+len(inherited))
+	result = append(result, tags...)
+	for _, tag := range inherited {
+		if !tags.HasTag(tag.Name) {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// optOut removes the tags that the type opted out, with `// gog:-<tag>`
+func optOut(tags Tags) Tags {
+	var out []string
+	for _, tag := range tags {
+		if strings.HasPrefix(tag.Name, optOutPrefix) {
+			out = append(out, strings.TrimPrefix(tag.Name, optOutPrefix))
+		}
+	}
+
+	result := make(Tags, 0, len(tags))
+	for _, tag := range tags {
+		if strings.HasPrefix(tag.Name, optOutPrefix) || Contains(out, tag.Name) || (tag.Preset != "" && Contains(out, tag.Preset)) {
+			continue
+		}
+		result = append(result, tag)
+	}
+	return result
+}
+
+// dedupTags keeps only one tag with the same name.
+// Tags written explicitly take precedence over the ones coming from a preset, and these over the ones inherited from the package.
+func dedupTags(tags Tags) Tags {
+	best := map[string]int{}
+	for k, tag := range tags {
+		if b, ok := best[tag.Name]; !ok || tagPriority(tag) < tagPriority(tags[b]) {
+			best[tag.Name] = k
+		}
+	}
+
+	result := make(Tags, 0, len(best))
+	for k, tag := range tags {
+		if best[tag.Name] == k {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+func tagPriority(tag Tag) int {
+	switch {
+	case tag.Inherited:
+		return inheritedPriority
+	case tag.Preset != "":
+		return presetPriority
+	default:
+		return explicitPriority
+	}
+}
+
+// acceptedTags removes the inherited tags of plugins that cannot handle the mapper
+func (p *Parser) acceptedTags(mapper Mapper, tags Tags) Tags {
+	result := make(Tags, 0, len(tags))
+	for _, tag := range tags {
+		if tag.Inherited {
+			reg, ok := p.generators[tag.Name]
+			accepted := ok && Contains(reg.plugin.Accepts(), mapper.Type())
+			// unknown plugins are only inherited by structs
+			if !accepted && (ok || mapper.Type() != StructMapper) {
+				continue
+			}
+		}
+		result = append(result, tag)
+	}
+	return result
+}
+
+// applyDefaults merges the package default options with the options of the tag.
+// The options of the tag take precedence over the defaults of its plugin,
+// and these over the defaults of the plugins it embeds, for the options the plugin also has.
+func (p *Parser) applyDefaults(tags Tags, defaults map[string]Tag) (Tags, error) {
+	for k, tag := range tags {
+		reg, registered := p.generators[tag.Name]
+		if !registered || len(reg.info.Embeds) == 0 {
+			// only the defaults of the plugin apply
+			def, ok := defaults[tag.Name]
+			if !ok || def.Args == "" {
+				continue
+			}
+			if tag.Args == "" {
+				tags[k].Args = def.Args
+				tags[k].argLines = nil
+				continue
+			}
+		}
+
+		tagArgs, err := tag.ParseArgs()
+		if err != nil {
+			return nil, p.diagnostic(tag.ErrorPos(err), tagLabel(tag), "%s", err)
+		}
+		defaulted := false
+		sources := []string{tag.Name}
+		if registered {
+			sources = append(sources, reg.info.Embeds...)
+		}
+		for _, name := range sources {
+			def, ok := defaults[name]
+			if !ok || def.Args == "" {
+				continue
+			}
+			defArgs, err := def.ParseArgs()
+			if err != nil {
+				return nil, p.diagnostic(def.Pos, def.Name, "%s", err)
+			}
+			for _, arg := range defArgs {
+				if _, ok := tagArgs.Find(arg.Key); ok {
+					continue
+				}
+				if name != tag.Name {
+					// an option of the embedded plugin that the plugin does not have
+					if _, ok := reg.info.Options.Find(arg.Key); !ok {
+						continue
+					}
+				}
+				tagArgs = append(tagArgs, arg)
+				defaulted = true
+			}
+		}
+		if !defaulted {
+			continue
+		}
+
+		var schema OptionSchema
+		if registered {
+			schema = reg.info.Options
+		}
+		merged, err := tagArgs.JSON(schema)
+		if err != nil {
+			return nil, p.diagnostic(tag.Pos, tagLabel(tag), "%s", err)
+		}
+		tags[k].Args = string(merged)
+		tags[k].argLines = nil
+	}
+	return tags, nil
+}
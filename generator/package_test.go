@@ -0,0 +1,117 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPackageTags(t *testing.T) {
+	saved := generators
+	t.Cleanup(func() {
+		generators = saved
+	})
+	UnregisterAll()
+	if err := Register(&fakePlugin{name: "record"}, WithOptions(struct{ Pointer bool }{}), WithEmbeds("getters")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Register(&fakePlugin{name: "getters"}, WithOptions(struct {
+		Pointer bool
+		Prefix  string
+	}{})); err != nil {
+		t.Fatal(err)
+	}
+
+	src := `// Package p does things
+//
+// gog:record
+// gog:defaults getters pointer=true
+package p
+
+type Foo struct{}
+
+// gog:-record
+// gog:getters prefix=Get
+type Bar struct{}
+
+// gog:record {"pointer": true}
+type Baz struct{}
+
+type Qux interface{}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := InspectGoFile(fset, nil, f)
+	if err := p.ResolveTags(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		// the defaults of getters apply to the getters that record generates
+		"Foo": `record {"pointer":true}`,
+		"Bar": `getters {"pointer":true,"prefix":"Get"}`,
+		"Baz": `record {"pointer": true}`,
+		"Qux": "",
+	}
+	for _, m := range p.Mappers {
+		got := []string{}
+		for _, tag := range m.GetTags() {
+			got = append(got, strings.TrimSpace(tag.Name+" "+tag.Args))
+		}
+		if strings.Join(got, "|") != want[m.GetName()] {
+			t.Errorf("%s: got %v, want %s", m.GetName(), got, want[m.GetName()])
+		}
+	}
+}
+
+func TestMisplacedDefaults(t *testing.T) {
+	registerFuncPlugins(t)
+
+	src := `package p
+
+// gog:defaults record pointer=true
+// gog:record
+type Foo struct{}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := InspectGoFile(fset, nil, f)
+	want := "src.go:3:1: defaults is only allowed in the package doc"
+	if diagnostics := p.CheckTags(); len(diagnostics) != 1 || diagnostics[0].Error() != want {
+		t.Errorf("got diagnostics %v, want %q", diagnostics, want)
+	}
+	if err := p.ResolveTags(); err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestReadPackageTags(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"doc.go":      "// gog:record\npackage p\n",
+		"foo.go":      "package p\n\ntype Foo struct{}\n",
+		"doc_test.go": "// gog:builder\npackage p\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tags, err := ReadPackageTags(token.NewFileSet(), filepath.Join(dir, "foo.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 1 || tags[0].Name != "record" {
+		t.Errorf("got %+v, want the record tag", tags)
+	}
+}
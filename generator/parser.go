@@ -3,18 +3,23 @@ package generator
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"go/ast"
+	"go/format"
 	"go/parser"
+	"go/scanner"
 	"go/token"
+	"go/types"
 	"log"
 	"os"
 	"path/filepath"
-	"strconv"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/quintans/gog/config"
-	"golang.org/x/tools/imports"
+	"github.com/quintans/gog/generator/argscan"
 )
 
 const (
@@ -24,6 +29,8 @@ const (
 	gogPrefix = "// gog:"
 
 	printErrorOffset = 3
+
+	generatedHeader = "// Code generated by gog; DO NOT EDIT."
 )
 
 var genSuffix = "gen"
@@ -32,42 +39,66 @@ func SetGenSuffix(s string) {
 	genSuffix = s
 }
 
-var generators = map[string]Plugin{}
-
-func UnregisterAll() {
-	generators = map[string]Plugin{}
+type ScanOptions struct {
+	dirOut     string
+	packageOut string
+	types      []string
+	line       int
+	plugins    []string
+	skip       []string
+	excludes   []string
+	verify     bool
+	report     *Report
+	// single is true when scanning a single file
+	single bool
 }
 
-func Unregister(gen Plugin) {
-	name := gen.Name()
-	delete(generators, name)
-	log.Printf("Unregistered generator: %s\n", name)
+type ScanOption func(*ScanOptions)
+
+// WithDirOut writes the generated files into another directory.
+// The generated code belongs to the package of that directory and imports the source package,
+// so only the plugins registered WithOutOfPackage can be used.
+func WithDirOut(dirOut string) ScanOption {
+	return func(so *ScanOptions) {
+		so.dirOut = dirOut
+	}
 }
 
-func Register(gen Plugin) {
-	name := gen.Name()
-	// TODO: don't allow if 'name' already exists
-	generators[name] = gen
-	log.Printf("Registered generator: %s\n", name)
+// WithTypes only generates the types.
+// A type with its own gog directive is generated into its own file, eg: foo_bar_gen.go for the type Bar of foo.go
+func WithTypes(types ...string) ScanOption {
+	return func(so *ScanOptions) {
+		so.types = types
+	}
 }
 
-type Plugin interface {
-	Accepts() []MapperType
-	Imports(Mapper) map[string]string
-	GenerateBody(Mapper) error
-	Name() string
-	Flush() []byte
+// WithLine only generates the type declared at the line, including its doc comment, if any.
+// It is the line of a go:generate directive with -line, from $GOLINE, so that a directive in the doc of a type only generates that type.
+func WithLine(line int) ScanOption {
+	return func(so *ScanOptions) {
+		so.line = line
+	}
 }
 
-type ScanOptions struct {
-	dirOut string
+// WithPlugins only runs the plugins, or presets
+func WithPlugins(plugins ...string) ScanOption {
+	return func(so *ScanOptions) {
+		so.plugins = plugins
+	}
 }
 
-type ScanOption func(*ScanOptions)
+// WithSkip does not run the plugins, or presets
+func WithSkip(plugins ...string) ScanOption {
+	return func(so *ScanOptions) {
+		so.skip = plugins
+	}
+}
 
-func WithDirOut(dirOut string) ScanOption {
+// WithPackageOut sets the package name of the generated code written into another directory.
+// By default it is the name of the directory.
+func WithPackageOut(name string) ScanOption {
 	return func(so *ScanOptions) {
-		so.dirOut = dirOut
+		so.packageOut = name
 	}
 }
 
@@ -76,55 +107,72 @@ func ScanCurrentDir(options ...ScanOption) {
 }
 
 func ScanDir(dir string, options ...ScanOption) {
-	files, err := os.ReadDir(dir)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	wd, err := os.Getwd()
-	if err != nil {
-		log.Println(err)
-	}
-
-	for _, file := range files {
-		parseAndGenerateIfTagged(wd, file.Name(), dir, options...)
-	}
+	scanDir(dir, false, options)
 }
 
 func ScanCurrentDirAndSubDirs(options ...ScanOption) {
 	ScanDirAndSubDirs(".", options...)
 }
 
+// ScanDirAndSubDirs generates the files of the dir and of its sub dirs, leaving out the dirs that go list leaves out and the nested modules
 func ScanDirAndSubDirs(dir string, options ...ScanOption) {
-	wd, err := os.Getwd()
+	absDir, err := filepath.Abs(dir)
 	if err != nil {
-		log.Println(err)
+		log.Fatal(err)
 	}
+	scanDir(absDir, true, options)
+}
 
-	absDir, err := filepath.Abs(dir)
+func scanDir(dir string, recursive bool, options []ScanOption) {
+	wd, err := os.Getwd()
 	if err != nil {
-		log.Fatal(err)
+		log.Println(err)
 	}
 
-	err = filepath.Walk(absDir, func(path string, file os.FileInfo, err error) error {
-		parseAndGenerateIfTagged(wd, path, absDir, options...)
-		return nil
+	err = walkGoFiles(dir, recursive, newScanOptions(options).excludes, func(path string) {
+		parseAndGenerateIfTagged(wd, path, dir, options...)
 	})
 	if err != nil {
-		panic(err)
+		log.Fatal(err)
 	}
 }
 
 func parseAndGenerateIfTagged(workDir, fullFileName, dirIn string, options ...ScanOption) {
-	if strings.HasSuffix(fullFileName, goFilesExt) && !strings.HasSuffix(fullFileName, goTestFilesExt) && isTagged(fullFileName) {
+	tagged, err := isTagged(fullFileName)
+	if err != nil {
+		// an unreadable file does not stop the run
+		log.Printf("warning: skipping %s: %v", fullFileName, err)
+		return
+	}
+	if tagged || isPackageTagged(filepath.Dir(fullFileName)) {
 		parseGoFileAndGenerateFile(workDir, fullFileName, dirIn, options...)
+		return
+	}
+	if report := newScanOptions(options).report; report != nil {
+		f := newFileReport(fullFileName)
+		f.Skipped = true
+		report.add(f)
 	}
 }
 
-func isTagged(gofile string) bool {
+// taggedPackages caches if the package in a directory has tags in its package doc
+var taggedPackages = map[string]bool{}
+
+func isPackageTagged(dir string) bool {
+	tagged, ok := taggedPackages[dir]
+	if ok {
+		return tagged
+	}
+	tags, err := readPackageTags(token.NewFileSet(), dir, "")
+	tagged = err == nil && len(tags) > 0
+	taggedPackages[dir] = tagged
+	return tagged
+}
+
+func isTagged(gofile string) (bool, error) {
 	file, err := os.Open(gofile)
 	if err != nil {
-		log.Fatal(err)
+		return false, err
 	}
 	defer file.Close()
 
@@ -133,19 +181,39 @@ func isTagged(gofile string) bool {
 		line := scanner.Text()
 		// for now we are just handling tagged structs
 		if strings.HasPrefix(line, gogPrefix) {
-			return true
+			return true, nil
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		log.Panic(err)
-	}
+	return false, scanner.Err()
+}
 
-	return false
+// TaggedFiles returns the go files in the dir, and in its sub dirs if recursive, that have gog tags, in their own doc or in the package doc.
+// The sub dirs are walked like ScanDirAndSubDirs does, and WithExcludes is the only option used.
+func TaggedFiles(dir string, recursive bool, options ...ScanOption) ([]string, error) {
+	var files []string
+	err := walkGoFiles(dir, recursive, newScanOptions(options).excludes, func(path string) {
+		tagged, err := isTagged(path)
+		if err != nil {
+			log.Printf("warning: skipping %s: %v", path, err)
+			return
+		}
+		if tagged || isPackageTagged(filepath.Dir(path)) {
+			files = append(files, path)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
 }
 
-func ScanAndGenerateFile(workDir, fullFileName string) {
-	parseGoFileAndGenerateFile(workDir, fullFileName, "")
+func ScanAndGenerateFile(workDir, fullFileName string, options ...ScanOption) {
+	single := func(so *ScanOptions) {
+		so.single = true
+	}
+	parseGoFileAndGenerateFile(workDir, fullFileName, filepath.Dir(fullFileName), append(options, single)...)
 }
 
 func parseGoFileAndGenerateFile(workDir, fullFileName, dirIn string, options ...ScanOption) {
@@ -153,40 +221,265 @@ func parseGoFileAndGenerateFile(workDir, fullFileName, dirIn string, options ...
 		log.Fatalf("invalid file: %s", fullFileName)
 	}
 
-	relativePath := strings.Replace(fullFileName, workDir, "", 1)
-	relativePathToRoot := strings.Split(relativePath, string(os.PathSeparator))
+	opts := newScanOptions(options)
+	report := newFileReport(fullFileName)
+	err := generateFile(workDir, fullFileName, dirIn, opts, &report)
+	if opts.report == nil {
+		die(err, "generating %s", fullFileName)
+		return
+	}
+	// with a report, the errors are reported and the other files are still generated
+	if err != nil {
+		report.Diagnostics = append(report.Diagnostics, diagnosticsOf(err)...)
+	}
+	opts.report.add(report)
+}
 
-	name := fullFileName[:len(fullFileName)-len(goFilesExt)]
-	fileName := fmt.Sprintf("%s_%s.go", name, genSuffix)
+// generateFile generates and writes the files of the go file, recording in the report what was done
+func generateFile(workDir, fullFileName, dirIn string, opts ScanOptions, report *FileReport) error {
+	relativePathToRoot := relativeDir(workDir, fullFileName)
+	p, err := parseSource(relativePathToRoot, fullFileName, nil)
+	if err != nil {
+		return fmt.Errorf("parsing package: %w", err)
+	}
+	for _, m := range p.Mappers {
+		report.Mappers = append(report.Mappers, m.GetName())
+	}
 
-	// drop file name
-	relativePathToRoot = relativePathToRoot[:len(relativePathToRoot)-1]
+	var files []File
+	var stale []string
+	for k, out := range outFiles(p, fullFileName, opts) {
+		if k > 0 {
+			// a parser generates a single file
+			p, err = parseSource(relativePathToRoot, fullFileName, nil)
+			if err != nil {
+				return fmt.Errorf("parsing package: %w", err)
+			}
+		}
+		fileName, err := p.prepare(fullFileName, dirIn, out, opts)
+		if err != nil {
+			return err
+		}
+		code, err := p.GenerateCode(fileName)
+		report.Plugins = append(report.Plugins, p.runs...)
+		report.Warnings = append(report.Warnings, p.warnings...)
+		if err != nil {
+			report.Outputs = append(report.Outputs, Output{File: fileName, Status: Skipped})
+			return err
+		}
+		if p.generated == 0 {
+			// eg: a doc.go with only package tags
+			stale = append(stale, fileName)
+			continue
+		}
+		files = append(files, File{Name: fileName, Content: string(code)})
+	}
 
-	p := parseGoFile(relativePathToRoot, fullFileName)
+	if opts.verify {
+		if err := Verify(fullFileName, nil, files, stale); err != nil {
+			for _, f := range files {
+				report.Outputs = append(report.Outputs, Output{File: f.Name, Status: Skipped})
+			}
+			return err
+		}
+	}
+	for _, name := range stale {
+		status, err := removeStaleGenerated(name)
+		if err != nil {
+			return fmt.Errorf("removing stale output: %w", err)
+		}
+		report.Outputs = append(report.Outputs, Output{File: name, Status: status})
+	}
+	for _, f := range files {
+		status, err := writeGoFile(f)
+		if err != nil {
+			return fmt.Errorf("writing output: %w", err)
+		}
+		report.Outputs = append(report.Outputs, Output{File: f.Name, Status: status})
+	}
+	return nil
+}
 
+func newScanOptions(options []ScanOption) ScanOptions {
 	opts := ScanOptions{}
 	for _, opt := range options {
 		opt(&opts)
 	}
+	return opts
+}
+
+// relativeDir returns the directory of the go file, relative to the working dir, split into its elements
+func relativeDir(workDir, fullFileName string) []string {
+	relativePath := strings.Replace(fullFileName, workDir, "", 1)
+	relativePathToRoot := strings.Split(relativePath, string(os.PathSeparator))
+	// drop file name
+	return relativePathToRoot[:len(relativePathToRoot)-1]
+}
+
+// outFile is a file to generate, with the types and plugins selected by the filter
+type outFile struct {
+	name   string
+	filter Filter
+}
+
+// outFiles returns the files to generate for the go file.
+// The types with their own directive are generated into their own file, the others into the file of the source file.
+func outFiles(p *Parser, fullFileName string, opts ScanOptions) []outFile {
+	types := opts.types
+	if !opts.single && len(types) > 0 {
+		// when scanning dirs, the types are looked up in every file
+		types = []string{}
+		for _, t := range opts.types {
+			if _, ok := p.findMapper(t); ok {
+				types = append(types, t)
+			}
+		}
+		if len(types) == 0 {
+			return nil
+		}
+	}
+	if len(types) == 0 && opts.line > 0 {
+		if t, ok := p.TypeAt(opts.line); ok {
+			types = []string{t}
+		}
+	}
+
+	name := fullFileName[:len(fullFileName)-len(goFilesExt)]
+	mainFile := fmt.Sprintf("%s_%s.go", name, genSuffix)
+	genMain := len(types) == 0
+	var owned []string
+	if genMain {
+		owned = p.OwnedTypes()
+	}
+	for _, t := range types {
+		if p.Owned(t) {
+			owned = append(owned, t)
+		} else {
+			genMain = true
+		}
+	}
+	if _, err := os.Stat(mainFile); err == nil {
+		// the main file may still have the code of a type that now has its own file
+		genMain = true
+	}
+
+	var files []outFile
+	if genMain {
+		files = append(files, outFile{name: mainFile, filter: Filter{Plugins: opts.plugins, Skip: opts.skip}})
+	}
+	for _, t := range owned {
+		files = append(files, outFile{
+			name:   fmt.Sprintf("%s_%s_%s.go", name, strings.ToLower(t), genSuffix),
+			filter: Filter{Types: []string{t}, Plugins: opts.plugins, Skip: opts.skip},
+		})
+	}
+	return files
+}
+
+// prepare sets the filter and the output package of the parser, returning the name of the file to generate
+func (p *Parser) prepare(fullFileName, dirIn string, out outFile, opts ScanOptions) (string, error) {
+	if err := p.SetFilter(out.filter); err != nil {
+		return "", err
+	}
 
+	fileName := out.name
 	if opts.dirOut != "" && opts.dirOut != dirIn {
-		fileName = strings.Replace(fileName, dirIn, opts.dirOut, 1)
+		var err error
+		fileName, err = outFileName(fileName, dirIn, opts.dirOut)
+		if err != nil {
+			return "", fmt.Errorf("generating into %s: %w", opts.dirOut, err)
+		}
+		err = setOutPackage(p, fullFileName, fileName, opts)
+		if err != nil {
+			return "", fmt.Errorf("generating into %s: %w", filepath.Dir(fileName), err)
+		}
 	}
-	p.generateGoFile(fileName)
+	return fileName, nil
 }
 
-func parseGoFile(relativePathToRoot []string, gofile string) *Parser {
-	log.Println("Parsing", gofile)
+// outFileName moves the file name from dirIn to the same relative location in dirOut
+func outFileName(fileName, dirIn, dirOut string) (string, error) {
+	absIn, err := filepath.Abs(dirIn)
+	if err != nil {
+		return "", err
+	}
+	absFile, err := filepath.Abs(fileName)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(absIn, absFile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dirOut, rel), nil
+}
+
+// setOutPackage prepares the parser to generate the code of gofile into the package of the directory of outFile
+func setOutPackage(p *Parser, gofile, outFile string, opts ScanOptions) error {
+	srcDir, err := filepath.Abs(filepath.Dir(gofile))
+	if err != nil {
+		return err
+	}
+	outDir, err := filepath.Abs(filepath.Dir(outFile))
+	if err != nil {
+		return err
+	}
+	if srcDir == outDir {
+		return nil
+	}
+
+	name := opts.packageOut
+	if name == "" {
+		name = filepath.Base(outDir)
+	}
+	if !isIdentifier(name) {
+		return fmt.Errorf("invalid package name %q, set one with WithPackageOut", name)
+	}
+	sourcePath, err := ImportPath(srcDir)
+	if err != nil {
+		return err
+	}
+	names, err := ReadPackageNames(token.NewFileSet(), gofile)
+	if err != nil {
+		return err
+	}
+	p.SetOutPackage(name, sourcePath)
+	p.AddPackageNames(names...)
+	return nil
+}
+
+// parseSource parses the go file, reading it from src if not nil.
+// The package tags are read from the other files of the package.
+func parseSource(relativePathToRoot []string, gofile string, src []byte) (*Parser, error) {
+	logger.Println("Parsing", gofile)
 
 	fs := token.NewFileSet()
-	parsedFile, err := parser.ParseFile(fs, gofile, nil, parser.ParseComments)
-	die(err, "parsing package: %s", gofile)
+	var source interface{}
+	if src != nil {
+		source = src
+	}
+	parsedFile, err := parser.ParseFile(fs, gofile, source, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	p := InspectGoFile(fs, relativePathToRoot, parsedFile)
+
+	tags, err := ReadPackageTags(fs, gofile)
+	if err != nil {
+		return nil, fmt.Errorf("reading package tags: %w", err)
+	}
+	p.AddPackageTags(tags)
 
-	return InspectGoFile(relativePathToRoot, parsedFile)
+	return p, nil
 }
 
-func InspectGoFile(relativePathToRoot []string, parsedFile *ast.File) *Parser {
-	g := NewParser(parsedFile)
+func InspectGoFile(fset *token.FileSet, relativePathToRoot []string, parsedFile *ast.File) *Parser {
+	g := NewParser(fset, parsedFile)
+	g.packageTags = extractTagsFromDoc(parsedFile.Doc)
+	for _, decl := range parsedFile.Decls {
+		g.AddPackageNames(declNames(decl)...)
+	}
 
 	ast.Inspect(parsedFile, g.genImp)
 	ast.Inspect(parsedFile, func(n ast.Node) bool {
@@ -194,6 +487,8 @@ func InspectGoFile(relativePathToRoot []string, parsedFile *ast.File) *Parser {
 	})
 
 	ast.Inspect(parsedFile, g.funcDecl)
+	g.linkFieldMappers()
+	g.findOwnedTypes()
 
 	return g
 }
@@ -207,78 +502,161 @@ func die(err error, msg string, args ...interface{}) {
 type Parser struct {
 	Scribler
 
-	Imports    map[string]string
-	Mappers    []Mapper
-	generators map[string]Plugin
-	parsedFile *ast.File
+	Imports     map[string]string
+	Mappers     []Mapper
+	generators  map[string]registration
+	presets     map[string]Tags
+	fset        *token.FileSet
+	parsedFile  *ast.File
+	packageTags Tags
+	resolved    bool
+	// generated counts the plugins that generated code
+	generated int
+	// runs are the plugins that generated code, with their timings
+	runs []PluginRun
+	// warnings are the tags skipped by the generation
+	warnings []Diagnostic
+	// packageNames are the names declared at the top level of the source package
+	packageNames map[string]bool
+	// outPackage is the package of the generated code, when it is not the source package
+	outPackage string
+	// sourcePath is the import path of the source package, when generating into another package
+	sourcePath string
+	filter     Filter
+	typeDecls  map[string]typeDecl
 }
 
-func NewParser(parsedFile *ast.File) *Parser {
+func NewParser(fset *token.FileSet, parsedFile *ast.File) *Parser {
 	return &Parser{
-		Imports:    make(map[string]string),
-		generators: generators,
-		parsedFile: parsedFile,
+		Imports:      make(map[string]string),
+		generators:   generators,
+		presets:      presets,
+		fset:         fset,
+		parsedFile:   parsedFile,
+		packageNames: map[string]bool{},
+		typeDecls:    map[string]typeDecl{},
 	}
 }
 
-func (p *Parser) generateGoFile(filename string) {
-	code, err := p.GenerateCode(filename)
-	die(err, "Generating code")
-	err = os.WriteFile(filename, code, 0o644)
-	die(err, "Writing output")
+// writeGoFile writes the generated file, unless it already has the same content
+func writeGoFile(f File) (OutputStatus, error) {
+	if content, err := os.ReadFile(f.Name); err == nil && string(content) == f.Content {
+		return Unchanged, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(f.Name), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(f.Name, []byte(f.Content), 0o644); err != nil {
+		return "", err
+	}
+	return Written, nil
+}
+
+// removeStaleGenerated removes a file previously generated by gog, that no longer has anything to generate
+func removeStaleGenerated(filename string) (OutputStatus, error) {
+	content, err := os.ReadFile(filename)
+	if err != nil || !bytes.HasPrefix(content, []byte(generatedHeader)) {
+		return Skipped, nil
+	}
+	logger.Println("Removing", filename)
+	if err := os.Remove(filename); err != nil {
+		return "", err
+	}
+	return Removed, nil
 }
 
 func (p *Parser) GenerateCode(filename string) ([]byte, error) {
-	p.HPrintf("// Code generated by gog; DO NOT EDIT.\n")
+	p.HPrintf("%s\n", generatedHeader)
 	p.HPrintf("// Version: %s\n", config.Version)
-	p.HPrintf("package %s\n\n", p.parsedFile.Name.Name)
+	p.HPrintf("package %s\n\n", p.packageName())
+
+	if err := p.ResolveTags(); err != nil {
+		return nil, err
+	}
 
 	for _, mapper := range p.Mappers {
+		if !p.selected(mapper) {
+			continue
+		}
 		err := p.generate(mapper)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	for path, name := range p.Imports {
-		p.HPrintf("import %s %s\n", name, path)
+	body, imps, err := p.resolveImports(p.Body.Bytes())
+	if err != nil {
+		return nil, err
 	}
+	p.Body.Reset()
+	writeImports(&p.Scribler, imps)
+	p.BPrintf("%s", body)
+
 	code := p.Flush()
-	src, err := imports.Process(filename, code, nil)
+	src, err := format.Source(code)
 	if err != nil {
-		// Should never happen, but can arise when developing this code.
-		// The user can compile the output to see the error.
-		errMsg := err.Error()
-		log.Printf("warning: internal error: invalid Go generated: %s", errMsg)
-		printLine(string(code), errMsg)
-		log.Printf("warning: compile the package to analyze the error")
-		return code, nil
+		return nil, invalidCodeError(code, err, 0)
 	}
 	return src, nil
 }
 
 func (p *Parser) generate(mapper Mapper) error {
 	for _, tag := range mapper.GetTags() {
-		gen, ok := p.generators[tag.Name]
+		if !p.filter.allows(tag) {
+			continue
+		}
+		reg, ok := p.generators[tag.Name]
 		if !ok {
-			log.Printf("Could not find plugin for %s", tag)
+			p.warn(tag.Pos, tagLabel(tag), "could not find plugin %s", tag.Name)
 			continue
 		}
+		gen := reg.plugin
 
 		if !Contains(gen.Accepts(), mapper.Type()) {
-			log.Printf("Plugin %s can't handle %s", tag, mapper.Type())
+			p.warn(tag.Pos, tagLabel(tag), "plugin %s can't handle %s", tag.Name, mapper.Type())
 			continue
 		}
 
-		err := gen.GenerateBody(mapper)
+		if p.outOfPackage() && !reg.info.OutOfPackage {
+			return p.diagnostic(tag.Pos, tagLabel(tag), "cannot generate into package %s: the plugin needs access to the unexported API of package %s", p.outPackage, p.parsedFile.Name.Name)
+		}
+
+		if reg.info.Options != nil {
+			if err := reg.info.Options.Validate(tag.Args); err != nil {
+				return p.diagnostic(tag.ErrorPos(err), tagLabel(tag), "%s", err)
+			}
+		}
+
+		if reg.info.Check != nil {
+			if issues := reg.info.Check(mapper); len(issues) > 0 {
+				return p.diagnostic(issues[0].Pos, tagLabel(tag), "%s", issues[0].Message)
+			}
+		}
+
+		start := time.Now()
+		err := generateBody(gen, mapper)
 		if err != nil {
-			return err
+			var d Diagnostic
+			if errors.As(err, &d) {
+				return err
+			}
+			return p.diagnostic(tag.Pos, tagLabel(tag), "%s", err)
 		}
+		p.runs = append(p.runs, PluginRun{Plugin: reg.info.Name, Type: mapper.GetName(), DurationMs: millis(time.Since(start))})
 
 		p.BPrintf("\n")
 
 		s := gen.Flush()
-		p.BPrintf("\n // Generated by gog:%s\n\n%s", gen.Name(), s)
+		if err := checkSyntax(s); err != nil {
+			return p.diagnostic(tag.Pos, tagLabel(tag), "%s", err)
+		}
+		if p.outOfPackage() {
+			if err := p.checkOutOfPackage(s); err != nil {
+				return p.diagnostic(tag.Pos, tagLabel(tag), "%s", err)
+			}
+		}
+		p.BPrintf("\n // Generated by gog:%s\n\n%s", reg.info.Name, s)
+		p.generated++
 
 		imps := gen.Imports(mapper)
 		for path, name := range imps {
@@ -288,31 +666,103 @@ func (p *Parser) generate(mapper Mapper) error {
 	return nil
 }
 
-func printLine(code, errMsg string) {
-	left := strings.Index(errMsg, ":") + 1
-	right := strings.Index(errMsg[left:], ":")
-	line, err := strconv.Atoi(errMsg[left : left+right])
+// generateBody calls the plugin, turning a panic into an error,
+// so that a faulty plugin fails the file instead of the whole run.
+func generateBody(gen Plugin, mapper Mapper) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("plugin panicked: %v", r)
+		}
+	}()
+	return gen.GenerateBody(mapper)
+}
+
+// ResolveTags replaces the tags of the mappers by the tags that will be used in the generation:
+// the package tags are inherited, presets are expanded, opted out tags are removed
+// and the package default options are applied.
+func (p *Parser) ResolveTags() error {
+	if p.resolved {
+		return nil
+	}
+
+	inherited, defaults, err := p.packageDefaults()
+	if err != nil {
+		return err
+	}
+
+	for _, mapper := range p.Mappers {
+		if tag, ok := mapper.GetTags().FindTag(defaultsTag); ok {
+			return p.misplacedDefaults(tag)
+		}
+		tags := inheritTags(mapper.GetTags(), inherited)
+		tags, err := p.expandPresets(tags)
+		if err != nil {
+			return err
+		}
+		tags = p.acceptedTags(mapper, dedupTags(optOut(tags)))
+		tags, err = p.applyDefaults(tags, defaults)
+		if err != nil {
+			return err
+		}
+		if err := p.checkPresetConflicts(mapper, tags); err != nil {
+			return err
+		}
+		mapper.SetTags(tags)
+	}
+	p.resolved = true
+	return nil
+}
+
+// tagLabel identifies the tag in diagnostics
+func tagLabel(tag Tag) string {
+	if tag.Preset != "" {
+		return fmt.Sprintf("%s (from preset %s)", tag.Name, tag.Preset)
+	}
+	return tag.Name
+}
+
+// checkSyntax checks that the code generated by a plugin is valid Go
+func checkSyntax(code []byte) error {
+	const header = "package p\n"
+	src := append([]byte(header), code...)
+	_, err := parser.ParseFile(token.NewFileSet(), "", src, parser.ParseComments)
 	if err != nil {
-		log.Printf("> failed to identify line from: %s: %s", errMsg, err)
-		return
+		return invalidCodeError(code, err, strings.Count(header, "\n"))
+	}
+	return nil
+}
+
+// invalidCodeError describes the error in the generated code, showing the offending lines.
+// lineOffset is the number of lines that precede the code in the parsed source.
+func invalidCodeError(code []byte, err error, lineOffset int) error {
+	var list scanner.ErrorList
+	if !errors.As(err, &list) || len(list) == 0 {
+		return fmt.Errorf("invalid Go generated: %w", err)
 	}
+	first := list[0]
+	line := first.Pos.Line - lineOffset
+	return fmt.Errorf("invalid Go generated: line %d:%d: %s\n%s", line, first.Pos.Column, first.Msg, codeContext(string(code), line))
+}
+
+// codeContext returns the lines around the line
+func codeContext(code string, line int) string {
 	lower := line - printErrorOffset
 	upper := line + printErrorOffset
+	s := &Scribler{}
 	scanner := bufio.NewScanner(strings.NewReader(code))
 	cnt := 0
-	for scanner.Scan() {
+	for scanner.Scan() && cnt < upper {
 		cnt++
-		if cnt >= lower && cnt <= upper {
-			if cnt == line {
-				log.Printf("> %d: %s", cnt, scanner.Text())
-			} else {
-				log.Printf("  %d: %s", cnt, scanner.Text())
-			}
+		if cnt < lower {
+			continue
 		}
-		if cnt > upper {
-			return
+		marker := " "
+		if cnt == line {
+			marker = ">"
 		}
+		s.BPrintf("%s %d: %s\n", marker, cnt, scanner.Text())
 	}
+	return s.String()
 }
 
 func (p *Parser) genImp(node ast.Node) bool {
@@ -340,6 +790,10 @@ func (p *Parser) genDecl(relativePathToRoot []string, node ast.Node) bool {
 	}
 	for _, spec := range decl.Specs {
 		tspec := spec.(*ast.TypeSpec)
+		switch tspec.Type.(type) {
+		case *ast.StructType, *ast.InterfaceType:
+			p.addTypeDecl(decl, tspec)
+		}
 		switch iType := tspec.Type.(type) {
 		case *ast.StructType:
 			aStruct := &Struct{
@@ -362,6 +816,10 @@ func (p *Parser) genDecl(relativePathToRoot []string, node ast.Node) bool {
 				Package: p.parsedFile.Name.Name,
 			}
 			for _, astField := range iType.Methods.List {
+				if len(astField.Names) == 0 {
+					// an embedded interface
+					continue
+				}
 				mName := astField.Names[0].Name
 				m := parseType(astField.Type).(*Method)
 				method := Method{
@@ -369,6 +827,7 @@ func (p *Parser) genDecl(relativePathToRoot []string, node ast.Node) bool {
 					FuncName: mName,
 					Args:     m.Args,
 					Results:  m.Results,
+					Pos:      astField.Names[0].Pos(),
 				}
 				aInterface.Methods = append(aInterface.Methods, method)
 			}
@@ -394,7 +853,11 @@ func (p *Parser) funcDecl(node ast.Node) bool {
 		} else {
 			expr = field.Type
 		}
-		ident := expr.(*ast.Ident)
+		ident, ok := expr.(*ast.Ident)
+		if !ok {
+			// eg: the receiver of a generic type
+			return false
+		}
 		for _, s := range p.Mappers {
 			if ident.Name == s.GetName() {
 				// add to the list of methods
@@ -404,6 +867,7 @@ func (p *Parser) funcDecl(node ast.Node) bool {
 					FuncName: fn.Name.Name,
 					Args:     m.Args,
 					Results:  m.Results,
+					Pos:      fn.Name.Pos(),
 				}
 				s.AddMethod(method)
 			}
@@ -413,26 +877,88 @@ func (p *Parser) funcDecl(node ast.Node) bool {
 	return false
 }
 
+// linkFieldMappers sets the mapper of the fields whose type, or the type they point to, is declared in the file
+func (p *Parser) linkFieldMappers() {
+	mappers := map[string]Mapper{}
+	for _, m := range p.Mappers {
+		mappers[m.GetName()] = m
+	}
+	for _, m := range p.Mappers {
+		s, ok := m.(*Struct)
+		if !ok {
+			continue
+		}
+		for k, field := range s.Fields {
+			kind := field.Kind
+			if ptr, ok := kind.(Pointer); ok {
+				kind = ptr.Kinder
+			}
+			if basic, ok := kind.(Basic); ok && basic.Pck == "" {
+				s.Fields[k].Mapper = mappers[basic.Type]
+			}
+		}
+	}
+}
+
 func extractTagsFromDoc(doc *ast.CommentGroup) Tags {
 	tags := make([]Tag, 0)
 	if doc == nil {
 		return tags
 	}
 
-	docs := make([]string, 0)
-	for _, com := range doc.List {
-		docs = append(docs, com.Text)
-	}
-
-	for _, line := range docs {
-		if strings.HasPrefix(line, gogPrefix) {
-			tag, arg := splitIntoTagAndArgs(line)
-			tags = append(tags, Tag{tag, arg})
+	for i := 0; i < len(doc.List); i++ {
+		com := doc.List[i]
+		if !strings.HasPrefix(com.Text, gogPrefix) {
+			continue
+		}
+		tag := newTag(com)
+		// the arguments continue in the next comment lines
+		for tag.continues() && i+1 < len(doc.List) && !strings.HasPrefix(doc.List[i+1].Text, gogPrefix) {
+			i++
+			tag.appendArgs(doc.List[i])
 		}
+		tags = append(tags, tag)
 	}
 	return Tags(tags)
 }
 
+func newTag(com *ast.Comment) Tag {
+	name, args := splitIntoTagAndArgs(com.Text)
+	tag := Tag{
+		Name: name,
+		Args: args,
+		Pos:  com.Slash,
+	}
+	if args != "" {
+		tag.argLines = []argLine{{
+			offset: 0,
+			pos:    com.Slash + token.Pos(len(gogPrefix)+len(name)+1),
+		}}
+	}
+	return tag
+}
+
+// continues returns true if the arguments end with a backslash or have unclosed brackets
+func (t *Tag) continues() bool {
+	return strings.HasSuffix(t.Args, `\`) || argscan.Open(t.Args)
+}
+
+func (t *Tag) appendArgs(com *ast.Comment) {
+	text := strings.TrimPrefix(com.Text, "//")
+	line := strings.TrimSpace(text)
+	start := len(com.Text) - len(strings.TrimLeft(text, " \t"))
+
+	t.Args = strings.TrimSuffix(t.Args, `\`)
+	if t.Args != "" {
+		t.Args += "\n"
+	}
+	t.argLines = append(t.argLines, argLine{
+		offset: len(t.Args),
+		pos:    com.Slash + token.Pos(start),
+	})
+	t.Args += line
+}
+
 func splitIntoTagAndArgs(line string) (tag, rawArgs string) {
 	str := strings.TrimSpace(line)
 	offset := len(gogPrefix)
@@ -446,6 +972,7 @@ func splitIntoTagAndArgs(line string) (tag, rawArgs string) {
 func parseField(astField *ast.Field) Field {
 	var field Field
 	field.Kind = parseType(astField.Type)
+	field.Pos = astField.Pos()
 	if len(astField.Names) > 0 {
 		field.Name = astField.Names[0].Name
 	}
@@ -454,28 +981,38 @@ func parseField(astField *ast.Field) Field {
 	return field
 }
 
+// parseType returns the kind of the type expression.
+// The types without a kind of their own, like chan int or List[int], are a TypeExpr with their source.
 func parseType(expr ast.Expr) Kinder {
 	var kind Kinder
 	switch n := expr.(type) {
-	// TODO: Map type
-	// if the type is imported
 	case *ast.MapType:
 		key := parseType(n.Key)
 		val := parseType(n.Value)
 		kind = Map{key, val}
 	case *ast.ArrayType:
+		if n.Len != nil {
+			// an array with a length, eg: [2]int
+			return TypeExpr{Source: types.ExprString(n)}
+		}
 		kind = Array{parseType(n.Elt)}
 	case *ast.SelectorExpr:
-		pck := n.X.(*ast.Ident)
+		pck, ok := n.X.(*ast.Ident)
+		if !ok {
+			return TypeExpr{Source: types.ExprString(n)}
+		}
 		kind = Basic{Pck: pck.Name, Type: n.Sel.Name}
 	case *ast.StarExpr:
 		kind = Pointer{parseType(n.X)}
 	case *ast.Ident:
 		kind = Basic{Type: n.Name}
+	case *ast.Ellipsis:
+		kind = Variadic{parseType(n.Elt)}
 	case *ast.InterfaceType:
-		if n.Methods == nil || len(n.Methods.List) == 0 {
-			kind = &InterfaceVar{}
+		if n.Methods != nil && len(n.Methods.List) > 0 {
+			return TypeExpr{Source: types.ExprString(n)}
 		}
+		kind = &InterfaceVar{}
 	case *ast.FuncType:
 		args := []Field{}
 		results := []Field{}
@@ -493,7 +1030,7 @@ func parseType(expr ast.Expr) Kinder {
 		}
 		kind = &Method{Args: args, Results: results}
 	default:
-		fmt.Printf("not handling type %T\n", n)
+		kind = TypeExpr{Source: types.ExprString(n)}
 	}
 	return kind
 }
@@ -0,0 +1,169 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/quintans/gog/generator/argscan"
+)
+
+var ErrInvalidPreset = errors.New("invalid preset")
+
+var presets = map[string]Tags{}
+
+// RegisterPreset registers a preset, a tag that expands into other tags, eg:
+//
+//	RegisterPreset("entity", "[allArgsConstructor, builder{pointer: true}]")
+//
+// makes `// gog:entity` the same as `// gog:allArgsConstructor` and `// gog:builder {pointer: true}`
+func RegisterPreset(name, definition string) error {
+	if err := validatePluginName(name); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidPreset, err)
+	}
+	if _, ok := generators[name]; ok || name == defaultsTag {
+		return fmt.Errorf("%w: %s is the name of a plugin", ErrInvalidPreset, name)
+	}
+	if _, ok := presets[name]; ok {
+		return fmt.Errorf("%w: %s is already registered", ErrInvalidPreset, name)
+	}
+
+	tags, err := ParsePreset(definition)
+	if err != nil {
+		return fmt.Errorf("preset %s: %w", name, err)
+	}
+	for _, tag := range tags {
+		reg, ok := generators[tag.Name]
+		if !ok || reg.info.Options == nil {
+			continue
+		}
+		if err := reg.info.Options.Validate(tag.Args); err != nil {
+			return fmt.Errorf("preset %s: %s: %w", name, tag.Name, err)
+		}
+	}
+
+	presets[name] = tags
+	return nil
+}
+
+func UnregisterPreset(name string) {
+	delete(presets, name)
+}
+
+// ParsePreset parses the definition of a preset, a list of tags with optional arguments,
+// eg: `[allArgsConstructor, builder pointer=true]` or `[allArgsConstructor, builder{pointer: true}]`
+func ParsePreset(definition string) (Tags, error) {
+	def := strings.TrimSpace(definition)
+	if !strings.HasPrefix(def, "[") || !strings.HasSuffix(def, "]") {
+		return nil, fmt.Errorf("%w: expected a list of tags between brackets, got %s", ErrInvalidPreset, definition)
+	}
+	def = def[1 : len(def)-1]
+
+	tags := Tags{}
+	for _, item := range argscan.Split(def, ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		end := strings.IndexAny(item, "{ \t")
+		if end == -1 {
+			end = len(item)
+		}
+		name := item[:end]
+		if err := validatePluginName(name); err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPreset, err)
+		}
+		tags = append(tags, Tag{
+			Name: name,
+			Args: strings.TrimSpace(item[end:]),
+		})
+	}
+	if len(tags) == 0 {
+		return nil, fmt.Errorf("%w: no tags", ErrInvalidPreset)
+	}
+	return tags, nil
+}
+
+// expandPresets replaces the preset tags by the tags they are made of.
+func (p *Parser) expandPresets(tags Tags) (Tags, error) {
+	return p.expandPresetTags(tags, nil)
+}
+
+func (p *Parser) expandPresetTags(tags Tags, visiting []string) (Tags, error) {
+	expanded := make(Tags, 0, len(tags))
+	for _, tag := range tags {
+		preset, ok := p.presets[tag.Name]
+		if !ok {
+			expanded = append(expanded, tag)
+			continue
+		}
+		if Contains(visiting, tag.Name) {
+			return nil, p.diagnostic(tag.Pos, "", "preset cycle: %s -> %s", strings.Join(visiting, " -> "), tag.Name)
+		}
+		if tag.Args != "" {
+			return nil, p.diagnostic(tag.Pos, "", "preset %s does not accept arguments", tag.Name)
+		}
+
+		// the expanded tags are positioned where the preset is used
+		positioned := make(Tags, len(preset))
+		for k, t := range preset {
+			t.Pos = tag.Pos
+			positioned[k] = t
+		}
+		inner, err := p.expandPresetTags(positioned, append(visiting, tag.Name))
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range inner {
+			t.Preset = tag.Name
+			t.Inherited = tag.Inherited
+			expanded = append(expanded, t)
+		}
+	}
+	return expanded, nil
+}
+
+// checkPresetConflicts returns an error if two plugins coming from the same preset generate the same declaration for the mapper,
+// eg: record and builder both generate the getters.
+// The plugins that cannot generate the mapper are left to fail when generating.
+func (p *Parser) checkPresetConflicts(mapper Mapper, tags Tags) error {
+	generatedBy := map[string]map[string]Tag{}
+	for _, tag := range tags {
+		if tag.Preset == "" || !p.filter.allows(tag) {
+			continue
+		}
+		use := p.pluginUse(mapper, tag)
+		if use.Error != "" {
+			continue
+		}
+		symbols, ok := generatedBy[tag.Preset]
+		if !ok {
+			symbols = map[string]Tag{}
+			generatedBy[tag.Preset] = symbols
+		}
+		for _, symbol := range use.Symbols {
+			if other, ok := symbols[symbol]; ok {
+				return p.diagnostic(tag.Pos, "", "preset %s: plugins %s and %s both generate %s", tag.Preset, other.Name, tag.Name, symbol)
+			}
+			symbols[symbol] = tag
+		}
+	}
+	return nil
+}
+
+// Presets returns the names of the registered presets, sorted
+func Presets() []string {
+	names := make([]string, 0, len(presets))
+	for name := range presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupPreset returns the tags the preset expands into
+func LookupPreset(name string) (Tags, bool) {
+	tags, ok := presets[name]
+	return tags, ok
+}
@@ -0,0 +1,123 @@
+package generator
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+func TestParsePreset(t *testing.T) {
+	tags, err := ParsePreset(`[record, builder{pointer: true, roles: [a, b]}, getters prefix=Get]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ name, args string }{
+		{"record", ""},
+		{"builder", "{pointer: true, roles: [a, b]}"},
+		{"getters", "prefix=Get"},
+	}
+	if len(tags) != len(want) {
+		t.Fatalf("got %+v, want %+v", tags, want)
+	}
+	for k, w := range want {
+		if tags[k].Name != w.name || tags[k].Args != w.args {
+			t.Errorf("got %s %q, want %s %q", tags[k].Name, tags[k].Args, w.name, w.args)
+		}
+	}
+
+	for _, def := range []string{`record`, `[]`, `[@required]`} {
+		if _, err := ParsePreset(def); !errors.Is(err, ErrInvalidPreset) {
+			t.Errorf("%s: got error %v, want %v", def, err, ErrInvalidPreset)
+		}
+	}
+}
+
+func TestExpandPresets(t *testing.T) {
+	savedGenerators, savedPresets := generators, presets
+	t.Cleanup(func() {
+		generators, presets = savedGenerators, savedPresets
+	})
+	UnregisterAll()
+	presets = map[string]Tags{}
+
+	if err := Register(&fakePlugin{name: "builder"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RegisterPreset("builder", "[record]"); !errors.Is(err, ErrInvalidPreset) {
+		t.Errorf("got error %v, want %v", err, ErrInvalidPreset)
+	}
+	for name, def := range map[string]string{
+		"entity":    "[record, builder{pointer: true}, value]",
+		"aggregate": "[entity, getters]",
+		"loop":      "[cycle]",
+		"cycle":     "[loop]",
+	} {
+		if err := RegisterPreset(name, def); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		tags    string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "preset",
+			tags: "// gog:entity",
+			want: []string{"record", "builder {pointer: true}", "value"},
+		},
+		{
+			name: "nested_preset",
+			tags: "// gog:aggregate",
+			want: []string{"record", "builder {pointer: true}", "value", "getters"},
+		},
+		{
+			name: "explicit_overrides",
+			tags: "// gog:entity\n// gog:builder pointer=false",
+			want: []string{"record", "value", "builder pointer=false"},
+		},
+		{
+			name:    "cycle",
+			tags:    "// gog:loop",
+			wantErr: "src.go:3:1: preset cycle: loop -> cycle -> loop",
+		},
+		{
+			name:    "arguments",
+			tags:    "// gog:entity pointer=true",
+			wantErr: "src.go:3:1: preset entity does not accept arguments",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := "package p\n\n" + tt.tags + "\ntype Foo struct{}\n"
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := InspectGoFile(fset, nil, f)
+			err = p.ResolveTags()
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("got error %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := []string{}
+			for _, tag := range p.Mappers[0].GetTags() {
+				got = append(got, strings.TrimSpace(tag.Name+" "+tag.Args))
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,272 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"go/token"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const namespaceSeparator = "."
+
+var (
+	ErrDuplicatePlugin   = errors.New("plugin already registered")
+	ErrInvalidPluginName = errors.New("invalid plugin name")
+)
+
+type Plugin interface {
+	Accepts() []MapperType
+	Imports(Mapper) map[string]string
+	GenerateBody(Mapper) error
+	Name() string
+	Flush() []byte
+}
+
+// PluginInfo is the metadata carried by a registered plugin
+type PluginInfo struct {
+	// Name is the fully qualified name used in the tag, eg: acme.audit for `// gog:acme.audit`
+	Name        string
+	Namespace   string
+	Version     string
+	Description string
+	Accepts     []MapperType
+	Options     OptionSchema
+	// OutOfPackage is true if the plugin only needs the exported API of the source package,
+	// and can generate into another package
+	OutOfPackage bool
+	// FieldTags are the field tags the plugin reads, eg: @required
+	FieldTags []string
+	// MethodTags are the method tags the plugin reads, eg: @transactional
+	MethodTags []string
+	// Check reports what in the source would make the plugin generate invalid code
+	Check CheckFunc
+	// Construction is true if the plugin generates the way to create valid values of the type, like a constructor or a builder
+	Construction bool
+	// Immutable is true if the values of the types of the plugin must not change after being created
+	Immutable bool
+	// Embeds are the plugins whose code the plugin also generates, like the getters generated by record.
+	// The package defaults of these plugins apply to the options of the plugin with the same name.
+	Embeds []string
+}
+
+// Issue is a problem in the source that would make a plugin generate invalid code
+type Issue struct {
+	Pos     token.Pos
+	Message string
+}
+
+// CheckFunc checks the source of the mapper for a plugin, like the signature of a method the generated code calls
+type CheckFunc func(mapper Mapper) []Issue
+
+type registerOptions struct {
+	info    PluginInfo
+	options interface{}
+}
+
+type RegisterOption func(*registerOptions)
+
+// WithNamespace registers the plugin under a namespace, so that it is referred as `// gog:<namespace>.<name>`
+func WithNamespace(namespace string) RegisterOption {
+	return func(ro *registerOptions) {
+		ro.info.Namespace = namespace
+	}
+}
+
+func WithVersion(version string) RegisterOption {
+	return func(ro *registerOptions) {
+		ro.info.Version = version
+	}
+}
+
+func WithDescription(description string) RegisterOption {
+	return func(ro *registerOptions) {
+		ro.info.Description = description
+	}
+}
+
+// WithOptions declares the options struct that the plugin reads from its tag arguments.
+// The option schema is derived from it (see SchemaOf) and used to validate the tag arguments.
+func WithOptions(options interface{}) RegisterOption {
+	return func(ro *registerOptions) {
+		ro.options = options
+	}
+}
+
+// WithOutOfPackage declares that the plugin only needs the exported API of the source package, like mocks or decorators,
+// and can generate into another package.
+func WithOutOfPackage() RegisterOption {
+	return func(ro *registerOptions) {
+		ro.info.OutOfPackage = true
+	}
+}
+
+// WithEmbeds declares the plugins whose code the plugin also generates, eg: WithEmbeds("getters") for record,
+// so that `// gog:defaults getters pointer=true` also sets the pointer option of record
+func WithEmbeds(plugins ...string) RegisterOption {
+	return func(ro *registerOptions) {
+		ro.info.Embeds = plugins
+	}
+}
+
+type registration struct {
+	plugin Plugin
+	info   PluginInfo
+}
+
+var generators = map[string]registration{}
+
+func UnregisterAll() {
+	generators = map[string]registration{}
+}
+
+// Unregister removes every registration of the plugin, whatever the namespace it was registered with
+func Unregister(gen Plugin) {
+	for name, reg := range generators {
+		if reg.plugin.Name() == gen.Name() && reflect.TypeOf(reg.plugin) == reflect.TypeOf(gen) {
+			delete(generators, name)
+			logger.Printf("Unregistered generator: %s\n", name)
+		}
+	}
+}
+
+// WithFieldTags declares the field tags that the plugin reads, eg: @required.
+// A field tag that no plugin of the type reads is reported by gogvet.
+func WithFieldTags(tags ...string) RegisterOption {
+	return func(ro *registerOptions) {
+		ro.info.FieldTags = append(ro.info.FieldTags, tags...)
+	}
+}
+
+// WithMethodTags declares the method tags that the plugin reads, eg: @transactional.
+// A method tag that no plugin of the type reads is reported by gogvet.
+func WithMethodTags(tags ...string) RegisterOption {
+	return func(ro *registerOptions) {
+		ro.info.MethodTags = append(ro.info.MethodTags, tags...)
+	}
+}
+
+// WithCheck declares a check of the source, run before the plugin generates the code of a type and by gogvet.
+// It reports what would make the plugin generate invalid code, like a method with the wrong signature.
+func WithCheck(check CheckFunc) RegisterOption {
+	return func(ro *registerOptions) {
+		ro.info.Check = check
+	}
+}
+
+// WithConstruction declares that the plugin generates the way to create valid values of the type,
+// like a constructor or a builder, where the invariants of the type are checked.
+// Creating values of the type with a composite literal outside of its package is reported by gogvet.
+func WithConstruction() RegisterOption {
+	return func(ro *registerOptions) {
+		ro.info.Construction = true
+	}
+}
+
+// WithImmutable declares that the values of the types of the plugin must not change after being created, like records.
+// Assigning to their fields, or writing to the slices and maps returned by their getters, is reported by gogvet.
+func WithImmutable() RegisterOption {
+	return func(ro *registerOptions) {
+		ro.info.Immutable = true
+	}
+}
+
+// Register registers a plugin.
+// It fails if the plugin name is not valid or if a plugin with the same qualified name was already registered.
+func Register(gen Plugin, options ...RegisterOption) error {
+	ro := registerOptions{
+		info: PluginInfo{
+			Accepts: gen.Accepts(),
+		},
+	}
+	for _, opt := range options {
+		opt(&ro)
+	}
+	info := ro.info
+
+	if err := validatePluginName(gen.Name()); err != nil {
+		return err
+	}
+	if gen.Name() == defaultsTag {
+		return fmt.Errorf("%w: %s is reserved", ErrInvalidPluginName, defaultsTag)
+	}
+	info.Name = gen.Name()
+	if info.Namespace != "" {
+		if err := validatePluginName(info.Namespace); err != nil {
+			return fmt.Errorf("namespace: %w", err)
+		}
+		info.Name = info.Namespace + namespaceSeparator + info.Name
+	}
+
+	if ro.options != nil {
+		schema, err := SchemaOf(ro.options)
+		if err != nil {
+			return fmt.Errorf("plugin %s: %w", info.Name, err)
+		}
+		info.Options = schema
+	}
+
+	if _, ok := generators[info.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrDuplicatePlugin, info.Name)
+	}
+
+	generators[info.Name] = registration{
+		plugin: gen,
+		info:   info,
+	}
+	return nil
+}
+
+// MustRegister is like Register but panics if the plugin cannot be registered
+func MustRegister(gen Plugin, options ...RegisterOption) {
+	if err := Register(gen, options...); err != nil {
+		panic(err)
+	}
+}
+
+// Plugins returns the metadata of all the registered plugins, sorted by name
+func Plugins() []PluginInfo {
+	infos := make([]PluginInfo, 0, len(generators))
+	for _, reg := range generators {
+		infos = append(infos, reg.info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// LookupPlugin returns the metadata of the plugin registered with the qualified name
+func LookupPlugin(name string) (PluginInfo, bool) {
+	reg, ok := generators[name]
+	return reg.info, ok
+}
+
+// validatePluginName checks that every dot separated segment of the name is an identifier
+func validatePluginName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: empty name", ErrInvalidPluginName)
+	}
+	for _, segment := range strings.Split(name, namespaceSeparator) {
+		if !isIdentifier(segment) {
+			return fmt.Errorf("%w: %q", ErrInvalidPluginName, name)
+		}
+	}
+	return nil
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for k, c := range s {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case k > 0 && c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
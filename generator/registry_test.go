@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"errors"
+	"testing"
+)
+
+type fakePlugin struct {
+	Scribler
+	name string
+}
+
+func (f *fakePlugin) Name() string {
+	return f.name
+}
+
+func (*fakePlugin) Accepts() []MapperType {
+	return []MapperType{StructMapper}
+}
+
+func (*fakePlugin) Imports(Mapper) map[string]string {
+	return map[string]string{}
+}
+
+func (*fakePlugin) GenerateBody(Mapper) error {
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	saved := generators
+	t.Cleanup(func() {
+		generators = saved
+	})
+
+	tests := []struct {
+		name     string
+		plugins  []string
+		options  []RegisterOption
+		wantName string
+		wantErr  error
+	}{
+		{
+			name:     "plain",
+			plugins:  []string{"audit"},
+			wantName: "audit",
+		},
+		{
+			name:     "namespaced",
+			plugins:  []string{"audit"},
+			options:  []RegisterOption{WithNamespace("acme"), WithVersion("1.0.0")},
+			wantName: "acme.audit",
+		},
+		{
+			name:    "duplicate",
+			plugins: []string{"audit", "audit"},
+			wantErr: ErrDuplicatePlugin,
+		},
+		{
+			name:    "invalid_name",
+			plugins: []string{"@audit"},
+			wantErr: ErrInvalidPluginName,
+		},
+		{
+			name:    "invalid_namespace",
+			plugins: []string{"audit"},
+			options: []RegisterOption{WithNamespace("acme-corp")},
+			wantErr: ErrInvalidPluginName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			UnregisterAll()
+
+			var err error
+			for _, name := range tt.plugins {
+				err = Register(&fakePlugin{name: name}, tt.options...)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			info, ok := LookupPlugin(tt.wantName)
+			if !ok {
+				t.Fatalf("plugin %s was not registered", tt.wantName)
+			}
+			if len(info.Accepts) != 1 || info.Accepts[0] != StructMapper {
+				t.Errorf("got accepts %v, want [%s]", info.Accepts, StructMapper)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package generator
+
+import (
+	"io"
+	"log"
+	"os"
+	"time"
+)
+
+// logger prints the progress of a run, like the files being parsed. It is silenced by SetQuiet
+var logger = log.New(os.Stderr, "", log.LstdFlags)
+
+// SetQuiet stops printing the progress of a run and the skipped tags, that are still recorded in the report.
+// Errors and the files that cannot be read are still printed
+func SetQuiet(quiet bool) {
+	if quiet {
+		logger.SetOutput(io.Discard)
+	} else {
+		logger.SetOutput(os.Stderr)
+	}
+}
+
+// WithReport records in the report what the run does
+func WithReport(report *Report) ScanOption {
+	return func(so *ScanOptions) {
+		so.report = report
+	}
+}
+
+// Report records what a run did, for CI dashboards and wrapper scripts
+type Report struct {
+	// Files are the go files scanned
+	Files      []FileReport `json:"files"`
+	DurationMs float64      `json:"durationMs"`
+	start      time.Time
+}
+
+// FileReport is what was done for a go file
+type FileReport struct {
+	File string `json:"file"`
+	// Skipped is true if the file has no gog tags, so nothing was generated
+	Skipped bool `json:"skipped,omitempty"`
+	// Mappers are the structs and interfaces found
+	Mappers     []string     `json:"mappers"`
+	Plugins     []PluginRun  `json:"plugins"`
+	Outputs     []Output     `json:"outputs"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	// Warnings are the tags that were skipped, like the ones of unknown plugins. They do not fail the run
+	Warnings   []Diagnostic `json:"warnings"`
+	DurationMs float64      `json:"durationMs"`
+	start      time.Time
+}
+
+// PluginRun is a plugin that generated the code of a type
+type PluginRun struct {
+	Plugin     string  `json:"plugin"`
+	Type       string  `json:"type"`
+	DurationMs float64 `json:"durationMs"`
+}
+
+type OutputStatus string
+
+const (
+	// Written is a file written with new content
+	Written OutputStatus = "written"
+	// Unchanged is a file that already had the generated content, so it was not written
+	Unchanged OutputStatus = "unchanged"
+	// Removed is a file generated before, that no longer has anything to generate
+	Removed OutputStatus = "removed"
+	// Skipped is a file not written, because it has nothing to generate or the generation failed
+	Skipped OutputStatus = "skipped"
+)
+
+// Output is a file that the run generates
+type Output struct {
+	File   string       `json:"file"`
+	Status OutputStatus `json:"status"`
+}
+
+func NewReport() *Report {
+	return &Report{Files: []FileReport{}, start: time.Now()}
+}
+
+// Finish sets the duration of the run
+func (r *Report) Finish() {
+	r.DurationMs = millis(time.Since(r.start))
+}
+
+// Failed returns true if any file has diagnostics
+func (r *Report) Failed() bool {
+	for _, f := range r.Files {
+		if len(f.Diagnostics) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func newFileReport(file string) FileReport {
+	return FileReport{
+		File:        file,
+		Mappers:     []string{},
+		Plugins:     []PluginRun{},
+		Outputs:     []Output{},
+		Diagnostics: []Diagnostic{},
+		Warnings:    []Diagnostic{},
+		start:       time.Now(),
+	}
+}
+
+func (r *Report) add(f FileReport) {
+	if r == nil {
+		return
+	}
+	f.DurationMs = millis(time.Since(f.start))
+	r.Files = append(r.Files, f)
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,71 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReport(t *testing.T) {
+	registerFuncPlugins(t)
+	MustRegister(&panicPlugin{})
+	SetQuiet(true)
+	t.Cleanup(func() { SetQuiet(false) })
+
+	dir := t.TempDir()
+	sources := map[string]string{
+		"foo.go":     "package p\n\n// gog:record\ntype Foo struct{}\n\ntype Bar struct{}\n",
+		"plain.go":   "package p\n\ntype Baz struct{}\n",
+		"bad.go":     "package p\n\n// gog:record\ntype Qux struct{\n",
+		"panic.go":   "package p\n\n// gog:panicky\ntype Pan struct{}\n",
+		"unknown.go": "package p\n\n// gog:nosuch\ntype Unk struct{}\n",
+	}
+	for name, src := range sources {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := func() map[string]FileReport {
+		r := NewReport()
+		ScanDir(dir, WithReport(r))
+		r.Finish()
+		if !r.Failed() {
+			t.Error("got a report without failures, want bad.go to fail")
+		}
+		files := map[string]FileReport{}
+		for _, f := range r.Files {
+			files[filepath.Base(f.File)] = f
+		}
+		return files
+	}
+
+	got := files()
+	foo := got["foo.go"]
+	if len(foo.Mappers) != 2 || len(foo.Plugins) != 1 || foo.Plugins[0].Plugin != "record" || foo.Plugins[0].Type != "Foo" {
+		t.Errorf("got foo.go %+v", foo)
+	}
+	if len(foo.Outputs) != 1 || filepath.Base(foo.Outputs[0].File) != "foo_gen.go" || foo.Outputs[0].Status != Written {
+		t.Errorf("got foo.go outputs %+v, want foo_gen.go written", foo.Outputs)
+	}
+	if !got["plain.go"].Skipped {
+		t.Errorf("got plain.go %+v, want it skipped", got["plain.go"])
+	}
+	if bad := got["bad.go"]; len(bad.Diagnostics) == 0 || bad.Diagnostics[0].Pos.Line != 4 {
+		t.Errorf("got bad.go diagnostics %v, want a syntax error at line 4", bad.Diagnostics)
+	}
+	// a plugin that panics fails its file, and not the run
+	if pan := got["panic.go"]; len(pan.Diagnostics) != 1 || pan.Diagnostics[0].Plugin != "panicky" || pan.Diagnostics[0].Pos.Line != 3 {
+		t.Errorf("got panic.go diagnostics %v, want the panic of the plugin at line 3", pan.Diagnostics)
+	}
+	unknown := got["unknown.go"]
+	if len(unknown.Diagnostics) != 0 || len(unknown.Warnings) != 1 || unknown.Warnings[0].Message != "could not find plugin nosuch" {
+		t.Errorf("got unknown.go diagnostics %v and warnings %v, want a warning for the unknown plugin", unknown.Diagnostics, unknown.Warnings)
+	}
+
+	// the second run generates the same content
+	foo = files()["foo.go"]
+	if len(foo.Outputs) != 1 || foo.Outputs[0].Status != Unchanged {
+		t.Errorf("got foo.go outputs %+v, want foo_gen.go unchanged", foo.Outputs)
+	}
+}
@@ -0,0 +1,180 @@
+package generator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+type OptionKind string
+
+const (
+	BoolOption       OptionKind = "bool"
+	StringOption     OptionKind = "string"
+	IntOption        OptionKind = "int"
+	FloatOption      OptionKind = "float"
+	StringListOption OptionKind = "[]string"
+)
+
+// OptionSpec describes an option accepted in the tag arguments of a plugin
+type OptionSpec struct {
+	Name        string
+	Kind        OptionKind
+	Description string
+}
+
+type OptionSchema []OptionSpec
+
+// SchemaOf derives the option schema from an options struct.
+// The option name is the json name of the field, or the field name with the first letter in lower case,
+// and the description is taken from the `desc` struct tag.
+func SchemaOf(options interface{}) (OptionSchema, error) {
+	t := reflect.TypeOf(options)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("options must be a struct, got %T", options)
+	}
+
+	schema := OptionSchema{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		name := UncapFirst(f.Name)
+		if jsonName, _, _ := strings.Cut(f.Tag.Get("json"), ","); jsonName != "" {
+			if jsonName == "-" {
+				continue
+			}
+			name = jsonName
+		}
+		kind, err := optionKind(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("option %s: %w", name, err)
+		}
+		schema = append(schema, OptionSpec{
+			Name:        name,
+			Kind:        kind,
+			Description: f.Tag.Get("desc"),
+		})
+	}
+	return schema, nil
+}
+
+func optionKind(t reflect.Type) (OptionKind, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return BoolOption, nil
+	case reflect.String:
+		return StringOption, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return IntOption, nil
+	case reflect.Float32, reflect.Float64:
+		return FloatOption, nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.String {
+			return StringListOption, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported option type %s", t)
+}
+
+// Find returns the option with the name. As with json, the name is matched case insensitive.
+func (s OptionSchema) Find(name string) (OptionSpec, bool) {
+	for _, o := range s {
+		if strings.EqualFold(o.Name, name) {
+			return o, true
+		}
+	}
+	return OptionSpec{}, false
+}
+
+func (s OptionSchema) Names() []string {
+	names := make([]string, len(s))
+	for k, o := range s {
+		names[k] = o.Name
+	}
+	return names
+}
+
+// Validate checks that the raw tag arguments only use known options with values of the right type.
+// Errors are of type *ArgError.
+func (s OptionSchema) Validate(raw string) error {
+	args, err := ParseTagArgs(raw)
+	if err != nil {
+		return err
+	}
+	return s.ValidateArgs(args)
+}
+
+func (s OptionSchema) ValidateArgs(args TagArgs) error {
+	for _, arg := range args {
+		spec, ok := s.Find(arg.Key)
+		if !ok {
+			if len(s) == 0 {
+				return argErrorf(arg.Offset, "unknown option %q: no options are available", arg.Key)
+			}
+			return argErrorf(arg.Offset, "unknown option %q: available options are %s", arg.Key, strings.Join(s.Names(), ", "))
+		}
+		if !spec.Kind.accepts(arg.Value) {
+			return argErrorf(arg.Value.Offset, "option %q must be of type %s, got %s", arg.Key, spec.Kind, arg.Value.describe())
+		}
+	}
+	return nil
+}
+
+func (k OptionKind) accepts(v ArgValue) bool {
+	if v.Kind == NullArg {
+		return true
+	}
+
+	switch k {
+	case BoolOption:
+		return v.Kind == BoolArg
+	case StringOption:
+		return v.isString()
+	case IntOption:
+		if v.Kind != NumberArg {
+			return false
+		}
+		_, err := strconv.ParseInt(v.Raw, 10, 64)
+		return err == nil
+	case FloatOption:
+		return v.Kind == NumberArg
+	case StringListOption:
+		if v.Kind != ListArg {
+			return false
+		}
+		for _, item := range v.List {
+			if !item.isString() {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isString returns true for strings and for unquoted scalars, that can be read as strings
+func (v ArgValue) isString() bool {
+	switch v.Kind {
+	case StringArg:
+		return true
+	case NumberArg, BoolArg:
+		return !v.Quoted
+	}
+	return false
+}
+
+func (v ArgValue) describe() string {
+	switch v.Kind {
+	case ListArg, ObjectArg:
+		return string(v.Kind)
+	}
+	return v.Raw
+}
@@ -0,0 +1,77 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+)
+
+type testOptions struct {
+	Pointer bool     `desc:"pointer receivers"`
+	Prefix  string   `json:"prefix"`
+	Roles   []string `json:"roles"`
+	Retries int
+	hidden  bool
+}
+
+func TestSchemaOf(t *testing.T) {
+	schema, err := SchemaOf(testOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := OptionSchema{
+		{Name: "pointer", Kind: BoolOption, Description: "pointer receivers"},
+		{Name: "prefix", Kind: StringOption},
+		{Name: "roles", Kind: StringListOption},
+		{Name: "retries", Kind: IntOption},
+	}
+	if len(schema) != len(want) {
+		t.Fatalf("got %+v, want %+v", schema, want)
+	}
+	for k := range want {
+		if schema[k] != want[k] {
+			t.Errorf("got %+v, want %+v", schema[k], want[k])
+		}
+	}
+
+	_, err = SchemaOf(struct{ Fn func() }{})
+	if err == nil {
+		t.Error("expected error for unsupported option type")
+	}
+}
+
+func TestSchemaValidate(t *testing.T) {
+	schema, err := SchemaOf(testOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		args    string
+		wantErr string
+	}{
+		{name: "empty"},
+		{name: "valid", args: `{"pointer": true, "Prefix": "Get", "roles": ["a"], "retries": 3}`},
+		{name: "null", args: `{"prefix": null}`},
+		{name: "unknown", args: `{"pointr": true}`, wantErr: `unknown option "pointr": available options are pointer, prefix, roles, retries`},
+		{name: "wrong_type", args: `{"pointer": "yes"}`, wantErr: `option "pointer" must be of type bool, got "yes"`},
+		{name: "wrong_list_type", args: `{"roles": "a"}`, wantErr: `option "roles" must be of type []string, got "a"`},
+		{name: "wrong_int_type", args: `retries=1.5`, wantErr: `option "retries" must be of type int, got 1.5`},
+		{name: "key_value", args: `pointer=true prefix=Get roles=[a, 1]`},
+		{name: "invalid_json", args: `{"pointer": }`, wantErr: `missing value for "pointer"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := schema.Validate(tt.args)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
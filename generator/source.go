@@ -0,0 +1,80 @@
+package generator
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// File is a generated file
+type File struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// Result has the files generated for a source, and the errors found while generating them
+type Result struct {
+	Files       []File       `json:"files"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// GenerateSource generates the code of the go file from its source, instead of reading it from the disk,
+// and returns the generated files instead of writing them.
+// It is meant for editors, to preview the code generated for an unsaved buffer.
+// The generated files are named as if they were written and the errors are returned as diagnostics.
+func GenerateSource(workDir, fullFileName string, src []byte, options ...ScanOption) Result {
+	result := Result{Files: []File{}, Diagnostics: []Diagnostic{}}
+	if !strings.HasSuffix(fullFileName, goFilesExt) {
+		result.addError(fmt.Errorf("invalid file: %s", fullFileName))
+		return result
+	}
+
+	relativePathToRoot := relativeDir(workDir, fullFileName)
+	opts := newScanOptions(options)
+	opts.single = true
+
+	p, err := parseSource(relativePathToRoot, fullFileName, src)
+	if err != nil {
+		result.addError(err)
+		return result
+	}
+	// the files with nothing to generate are removed when writing
+	var stale []string
+	for k, out := range outFiles(p, fullFileName, opts) {
+		if k > 0 {
+			// a parser generates a single file
+			p, err = parseSource(relativePathToRoot, fullFileName, src)
+			if err != nil {
+				result.addError(err)
+				return result
+			}
+		}
+		fileName, err := p.prepare(fullFileName, filepath.Dir(fullFileName), out, opts)
+		if err != nil {
+			result.addError(err)
+			continue
+		}
+		code, err := p.GenerateCode(fileName)
+		if err != nil {
+			result.addError(err)
+			continue
+		}
+		if p.generated == 0 {
+			stale = append(stale, fileName)
+			continue
+		}
+		result.Files = append(result.Files, File{Name: fileName, Content: string(code)})
+	}
+
+	if opts.verify && len(result.Diagnostics) == 0 {
+		if err := Verify(fullFileName, src, result.Files, stale); err != nil {
+			result.addError(err)
+			result.Files = []File{}
+		}
+	}
+	return result
+}
+
+func (r *Result) addError(err error) {
+	r.Diagnostics = append(r.Diagnostics, diagnosticsOf(err)...)
+}
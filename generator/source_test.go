@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSource(t *testing.T) {
+	registerFuncPlugins(t)
+
+	// the source is an unsaved buffer, that is not on disk
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.go")
+
+	result := GenerateSource(dir, src, []byte(filterSrc))
+	if len(result.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics %v", result.Diagnostics)
+	}
+	got := map[string]string{}
+	for _, file := range result.Files {
+		got[filepath.Base(file.Name)] = strings.Join(generatedFuncs([]byte(file.Content)), ",")
+	}
+	want := map[string]string{
+		"src_gen.go":     "FooRecord,FooBuilder,BazRecord,BazBuilder",
+		"src_bar_gen.go": "BarRecord",
+	}
+	if len(got) != len(want) || got["src_gen.go"] != want["src_gen.go"] || got["src_bar_gen.go"] != want["src_bar_gen.go"] {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no files written, got %d", len(entries))
+	}
+}
+
+func TestGenerateSourceDiagnostics(t *testing.T) {
+	registerFuncPlugins(t)
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		src     string
+		options []ScanOption
+		want    string
+	}{
+		{
+			name: "syntax error",
+			src:  "package p\n\n// gog:record\ntype Foo struct {\n",
+			want: `[{"file":"src.go","line":4,"column":19,"message":"expected '}', found 'EOF'"}]`,
+		},
+		{
+			name:    "unknown plugin",
+			src:     "package p\n\n// gog:record\ntype Foo struct{}\n",
+			options: []ScanOption{WithSkip("getters")},
+			want:    `[{"message":"unknown plugin \"getters\""}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := GenerateSource(dir, filepath.Join(dir, "src.go"), []byte(tt.src), tt.options...)
+			if len(result.Files) != 0 {
+				t.Errorf("unexpected files %v", result.Files)
+			}
+			b, err := json.Marshal(result.Diagnostics)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.ReplaceAll(string(b), filepath.Join(dir, "src.go"), "src.go")
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateSourceUnhandledTypes(t *testing.T) {
+	registerFuncPlugins(t)
+	dir := t.TempDir()
+	src := `package p
+
+// gog:record
+type Foo struct {
+	events chan int
+	pair   [2]int
+	list   List[int]
+	named  interface{ Name() string }
+	fn     func(args ...string)
+}
+
+type List[T any] []T
+
+func (f Foo) Do(args ...string) {}
+`
+	// stdout only has what the caller prints, like the JSON of -stdin
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	result := GenerateSource(dir, filepath.Join(dir, "src.go"), []byte(src))
+	os.Stdout = stdout
+	w.Close()
+	printed, _ := io.ReadAll(r)
+	if len(printed) != 0 {
+		t.Errorf("got %q printed to stdout", printed)
+	}
+	if len(result.Diagnostics) != 0 || len(result.Files) != 1 {
+		t.Errorf("got %+v, want a file without diagnostics", result)
+	}
+
+	p, err := parseSource(nil, filepath.Join(dir, "src.go"), []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, field := range p.Mappers[0].GetFields() {
+		got = append(got, field.Kind.String()+" "+field.Kind.ZeroCondition("x"))
+	}
+	want := []string{
+		"chan int x == *new(chan int)",
+		"[2]int x == *new([2]int)",
+		"List[int] x == *new(List[int])",
+		"interface{Name() string} x == *new(interface{Name() string})",
+		"func(args ...string) () x == nil",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	m, _ := p.Mappers[0].FindMethod("Do")
+	if kind := m.Args[0].Kind; kind.String() != "...string" {
+		t.Errorf("got the variadic arg %s, want ...string", kind)
+	}
+}
@@ -0,0 +1,352 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type ArgKind string
+
+const (
+	StringArg ArgKind = "string"
+	NumberArg ArgKind = "number"
+	BoolArg   ArgKind = "bool"
+	NullArg   ArgKind = "null"
+	ListArg   ArgKind = "list"
+	ObjectArg ArgKind = "object"
+)
+
+// ArgError is an error in the tag arguments, at Offset of the raw arguments
+type ArgError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *ArgError) Error() string {
+	return e.Msg
+}
+
+func argErrorf(offset int, format string, args ...interface{}) *ArgError {
+	return &ArgError{
+		Offset: offset,
+		Msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+// ArgValue is a value of the tag arguments
+type ArgValue struct {
+	Kind ArgKind
+	// Offset of the value in the raw arguments
+	Offset int
+	// Raw is the text of a scalar value, as written
+	Raw string
+	// Quoted is true if the value was written as a quoted string
+	Quoted bool
+	// Str is the value of a string
+	Str    string
+	List   []ArgValue
+	Object TagArgs
+}
+
+// TagArg is a key value pair of the tag arguments
+type TagArg struct {
+	Key string
+	// Offset of the key in the raw arguments
+	Offset int
+	Value  ArgValue
+}
+
+type TagArgs []TagArg
+
+func (a TagArgs) Find(key string) (TagArg, bool) {
+	for _, arg := range a {
+		if strings.EqualFold(arg.Key, key) {
+			return arg, true
+		}
+	}
+	return TagArg{}, false
+}
+
+// ParseTagArgs parses the tag arguments, that can be written as a JSON object (keys may be unquoted),
+// `{"pointer": true}` or `{pointer: true}`, or as key value pairs, `pointer=true roles=[a,b]`.
+// Strings only need to be quoted if they have spaces or any of the delimiters `,=[]{}"`.
+func ParseTagArgs(raw string) (TagArgs, error) {
+	p := &argParser{src: raw}
+	p.skipSpace()
+	if p.eof() {
+		return nil, nil
+	}
+
+	if p.peek() == '{' {
+		v, err := p.object()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		if !p.eof() {
+			return nil, argErrorf(p.pos, "unexpected %q after the closing brace", p.peek())
+		}
+		return v.Object, nil
+	}
+
+	return p.pairs(func() bool { return p.eof() })
+}
+
+type argParser struct {
+	src string
+	pos int
+}
+
+func (p *argParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *argParser) peek() byte {
+	return p.src[p.pos]
+}
+
+func (p *argParser) skipSpace() {
+	for !p.eof() && isArgSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+func (p *argParser) skipSeparators() {
+	for !p.eof() && (isArgSpace(p.peek()) || p.peek() == ',') {
+		p.pos++
+	}
+}
+
+func isArgSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isArgDelimiter(c byte) bool {
+	return isArgSpace(c) || strings.IndexByte(`,=[]{}"`, c) >= 0
+}
+
+func (p *argParser) object() (ArgValue, error) {
+	start := p.pos
+	p.pos++ // {
+	args, err := p.pairs(func() bool {
+		if !p.eof() && p.peek() == '}' {
+			p.pos++
+			return true
+		}
+		return false
+	})
+	if err != nil {
+		return ArgValue{}, err
+	}
+	return ArgValue{Kind: ObjectArg, Offset: start, Object: args}, nil
+}
+
+// pairs parses key value pairs until end returns true
+func (p *argParser) pairs(end func() bool) (TagArgs, error) {
+	args := TagArgs{}
+	for {
+		p.skipSeparators()
+		if end() {
+			return args, nil
+		}
+		if p.eof() {
+			return nil, argErrorf(p.pos, "missing closing brace")
+		}
+
+		keyOffset := p.pos
+		key, err := p.key()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := args.Find(key); ok {
+			return nil, argErrorf(keyOffset, "duplicate option %q", key)
+		}
+
+		p.skipSpace()
+		if p.eof() || (p.peek() != '=' && p.peek() != ':') {
+			return nil, argErrorf(p.pos, "expected = or : after %q", key)
+		}
+		p.pos++
+		valueOffset := p.pos
+		p.skipSpace()
+
+		value, err := p.value(key)
+		if err != nil {
+			return nil, err
+		}
+		if value.Kind != ObjectArg && value.Kind != ListArg && !value.Quoted && p.followedByAssignment() {
+			// what was read as the value is the key of the next option
+			return nil, argErrorf(valueOffset, "missing value for %q", key)
+		}
+		args = append(args, TagArg{Key: key, Offset: keyOffset, Value: value})
+	}
+}
+
+func (p *argParser) followedByAssignment() bool {
+	i := p.pos
+	for i < len(p.src) && isArgSpace(p.src[i]) {
+		i++
+	}
+	return i < len(p.src) && (p.src[i] == '=' || p.src[i] == ':')
+}
+
+func (p *argParser) key() (string, error) {
+	if p.peek() == '"' {
+		v, err := p.string()
+		if err != nil {
+			return "", err
+		}
+		return v.Str, nil
+	}
+
+	start := p.pos
+	for !p.eof() && !isArgDelimiter(p.peek()) && p.peek() != ':' {
+		p.pos++
+	}
+	key := p.src[start:p.pos]
+	if !isIdentifier(key) {
+		return "", argErrorf(start, "expected option name, got %q", key)
+	}
+	return key, nil
+}
+
+func (p *argParser) value(key string) (ArgValue, error) {
+	if p.eof() {
+		return ArgValue{}, argErrorf(p.pos, "missing value for %q", key)
+	}
+
+	switch p.peek() {
+	case '"':
+		return p.string()
+	case '[':
+		return p.list(key)
+	case '{':
+		return p.object()
+	}
+
+	start := p.pos
+	for !p.eof() && !isArgDelimiter(p.peek()) {
+		p.pos++
+	}
+	raw := p.src[start:p.pos]
+	if raw == "" {
+		return ArgValue{}, argErrorf(start, "missing value for %q", key)
+	}
+
+	v := ArgValue{Offset: start, Raw: raw}
+	switch raw {
+	case "true", "false":
+		v.Kind = BoolArg
+	case "null":
+		v.Kind = NullArg
+	default:
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			v.Kind = NumberArg
+		} else {
+			v.Kind = StringArg
+			v.Str = raw
+		}
+	}
+	return v, nil
+}
+
+func (p *argParser) string() (ArgValue, error) {
+	start := p.pos
+	p.pos++ // opening quote
+	for !p.eof() {
+		switch p.peek() {
+		case '\\':
+			p.pos += 2
+			continue
+		case '"':
+			p.pos++
+			raw := p.src[start:p.pos]
+			var s string
+			if err := json.Unmarshal([]byte(raw), &s); err != nil {
+				return ArgValue{}, argErrorf(start, "invalid string %s", raw)
+			}
+			return ArgValue{Kind: StringArg, Offset: start, Raw: raw, Quoted: true, Str: s}, nil
+		}
+		p.pos++
+	}
+	return ArgValue{}, argErrorf(start, "unterminated string")
+}
+
+func (p *argParser) list(key string) (ArgValue, error) {
+	v := ArgValue{Kind: ListArg, Offset: p.pos, List: []ArgValue{}}
+	p.pos++ // [
+	for {
+		p.skipSpace()
+		if p.eof() {
+			return ArgValue{}, argErrorf(v.Offset, "missing closing bracket")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return v, nil
+		}
+
+		item, err := p.value(key)
+		if err != nil {
+			return ArgValue{}, err
+		}
+		v.List = append(v.List, item)
+
+		p.skipSpace()
+		if p.eof() {
+			return ArgValue{}, argErrorf(v.Offset, "missing closing bracket")
+		}
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return ArgValue{}, argErrorf(p.pos, "expected , or ] in the list of %q", key)
+		}
+	}
+}
+
+// JSON converts the arguments into a JSON object.
+// Unquoted scalars are converted into strings where the schema expects a string.
+func (a TagArgs) JSON(schema OptionSchema) ([]byte, error) {
+	return json.Marshal(a.toMap(schema))
+}
+
+func (a TagArgs) toMap(schema OptionSchema) map[string]interface{} {
+	m := make(map[string]interface{}, len(a))
+	for _, arg := range a {
+		var kind OptionKind
+		if spec, ok := schema.Find(arg.Key); ok {
+			kind = spec.Kind
+		}
+		m[arg.Key] = arg.Value.toInterface(kind)
+	}
+	return m
+}
+
+func (v ArgValue) toInterface(kind OptionKind) interface{} {
+	switch v.Kind {
+	case StringArg:
+		return v.Str
+	case NumberArg:
+		if kind == StringOption || kind == StringListOption {
+			return v.Raw
+		}
+		return json.Number(v.Raw)
+	case BoolArg:
+		if kind == StringOption || kind == StringListOption {
+			return v.Raw
+		}
+		return v.Raw == "true"
+	case ListArg:
+		list := make([]interface{}, len(v.List))
+		for k, item := range v.List {
+			list[k] = item.toInterface(kind)
+		}
+		return list
+	case ObjectArg:
+		return v.Object.toMap(nil)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package generator
+
+import (
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestTagUnmarshal(t *testing.T) {
+	type options struct {
+		Pointer bool
+		Prefix  string
+		Roles   []string
+		Retries int
+	}
+
+	tests := []struct {
+		name string
+		args string
+		want options
+	}{
+		{
+			name: "json",
+			args: `{"pointer": true, "prefix": "Get", "roles": ["a", "b"], "retries": 3}`,
+			want: options{Pointer: true, Prefix: "Get", Roles: []string{"a", "b"}, Retries: 3},
+		},
+		{
+			name: "unquoted_keys",
+			args: `{pointer: true, prefix: Get}`,
+			want: options{Pointer: true, Prefix: "Get"},
+		},
+		{
+			name: "key_value",
+			args: `pointer=true prefix=Get roles=[a, "b c"] retries=3`,
+			want: options{Pointer: true, Prefix: "Get", Roles: []string{"a", "b c"}, Retries: 3},
+		},
+		{
+			name: "unquoted_scalar_as_string",
+			args: `prefix=1 roles=[true]`,
+			want: options{Prefix: "1", Roles: []string{"true"}},
+		},
+		{
+			name: "multi_line",
+			args: "{\n\"pointer\": true,\nroles: [\na,\nb]\n}",
+			want: options{Pointer: true, Roles: []string{"a", "b"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := options{}
+			err := Tag{Args: tt.args}.Unmarshal(&got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTagArgsErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       string
+		wantErr    string
+		wantOffset int
+	}{
+		{name: "missing_value", args: `pointer= prefix=Get`, wantErr: `missing value for "pointer"`, wantOffset: 8},
+		{name: "missing_equal", args: `pointer true`, wantErr: `expected = or : after "pointer"`, wantOffset: 8},
+		{name: "duplicate", args: `a=1 a=2`, wantErr: `duplicate option "a"`, wantOffset: 4},
+		{name: "unterminated_string", args: `a="xx`, wantErr: `unterminated string`, wantOffset: 2},
+		{name: "missing_brace", args: `{"a": 1`, wantErr: `missing closing brace`, wantOffset: 7},
+		{name: "missing_bracket", args: `a=[1, 2`, wantErr: `missing closing bracket`, wantOffset: 2},
+		{name: "trailing", args: `{"a": 1} b`, wantErr: `unexpected 'b' after the closing brace`, wantOffset: 9},
+		{name: "invalid_key", args: `1a=2`, wantErr: `expected option name, got "1a"`, wantOffset: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseTagArgs(tt.args)
+			argErr, ok := err.(*ArgError)
+			if !ok {
+				t.Fatalf("got error %v, want *ArgError", err)
+			}
+			if argErr.Msg != tt.wantErr || argErr.Offset != tt.wantOffset {
+				t.Errorf("got %q at %d, want %q at %d", argErr.Msg, argErr.Offset, tt.wantErr, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestExtractTagsMultiLine(t *testing.T) {
+	src := `package p
+
+// Foo does foo
+// gog:getters {
+//   "pointer": true,
+//   prefix: Get
+// }
+// gog:builder pointer=true \
+//   other=1
+// more docs
+type Foo struct{}
+`
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := InspectGoFile(fset, nil, f)
+	tags := p.Mappers[0].GetTags()
+	if len(tags) != 2 {
+		t.Fatalf("got %d tags, want 2", len(tags))
+	}
+
+	getters := tags[0]
+	if getters.Name != "getters" || getters.Args != "{\n\"pointer\": true,\nprefix: Get\n}" {
+		t.Errorf("got getters tag %q with args %q", getters.Name, getters.Args)
+	}
+	args, err := getters.ParseArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefix, _ := args.Find("prefix")
+	if got := fset.Position(getters.ArgPos(prefix.Offset)).String(); got != "src.go:6:6" {
+		t.Errorf("got prefix position %s, want src.go:6:6", got)
+	}
+
+	builder := tags[1]
+	if builder.Name != "builder" || builder.Args != "pointer=true \nother=1" {
+		t.Errorf("got builder tag %q with args %q", builder.Name, builder.Args)
+	}
+	args, err = builder.ParseArgs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := args.Find("other")
+	if got := fset.Position(builder.ArgPos(other.Offset)).String(); got != "src.go:9:6" {
+		t.Errorf("got other position %s, want src.go:9:6", got)
+	}
+}
@@ -0,0 +1,195 @@
+package generator
+
+import (
+	"errors"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// WithVerify type checks the package of the source file together with the generated files before writing them.
+// If there are type errors, they are reported with the plugin that generated the offending code and no file is written.
+func WithVerify() ScanOption {
+	return func(so *ScanOptions) {
+		so.verify = true
+	}
+}
+
+// Diagnostics are the errors found while verifying the generated code
+type Diagnostics []Diagnostic
+
+func (d Diagnostics) Error() string {
+	msgs := make([]string, len(d))
+	for k, diagnostic := range d {
+		msgs[k] = diagnostic.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// pluginMarker is the comment that precedes the code generated by each plugin
+var pluginMarker = regexp.MustCompile(`^\s*// Generated by gog:(\S+)`)
+
+// Verify type checks the packages of the generated files, with the generated files replacing the ones on disk.
+// The files in stale are about to be removed, so they are left out.
+// The source file is read from src, if not nil, instead of the disk.
+func Verify(sourceFile string, src []byte, files []File, stale []string) error {
+	overlay := map[string]string{}
+	generated := map[string]string{}
+	if src != nil {
+		overlay[absPath(sourceFile)] = string(src)
+	}
+	for _, f := range files {
+		overlay[absPath(f.Name)] = f.Content
+		generated[absPath(f.Name)] = f.Content
+	}
+	for _, name := range stale {
+		overlay[absPath(name)] = ""
+	}
+
+	dirs := map[string]bool{}
+	for _, f := range files {
+		dirs[filepath.Dir(absPath(f.Name))] = true
+	}
+	var diagnostics Diagnostics
+	for _, dir := range sortedKeys(dirs) {
+		ds, err := verifyPackage(dir, overlay, generated)
+		if err != nil {
+			return err
+		}
+		diagnostics = append(diagnostics, ds...)
+	}
+	if len(diagnostics) > 0 {
+		return diagnostics
+	}
+	return nil
+}
+
+// verifyPackage type checks the package in the dir, reading the files from the overlay when they are there.
+// An empty content in the overlay removes the file.
+func verifyPackage(dir string, overlay, generated map[string]string) ([]Diagnostic, error) {
+	names := map[string]bool{}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names[filepath.Join(dir, entry.Name())] = true
+		}
+	}
+	for name := range overlay {
+		if filepath.Dir(name) == dir {
+			names[name] = true
+		}
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range sortedKeys(names) {
+		if !isGoSource(name) {
+			continue
+		}
+		content, ok := overlay[name]
+		if ok && content == "" {
+			continue
+		}
+		var source interface{}
+		if ok {
+			source = content
+		}
+		if _, isGenerated := generated[name]; !isGenerated {
+			if match, err := build.Default.MatchFile(dir, filepath.Base(name)); err != nil || !match {
+				continue
+			}
+		}
+		f, err := parser.ParseFile(fset, name, source, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	var diagnostics []Diagnostic
+	conf := types.Config{
+		Importer: importer.ForCompiler(fset, "source", nil),
+		Error: func(err error) {
+			var typeErr types.Error
+			if !errors.As(err, &typeErr) {
+				diagnostics = append(diagnostics, Diagnostic{Message: err.Error()})
+				return
+			}
+			pos := fset.Position(typeErr.Pos)
+			if strings.HasPrefix(typeErr.Msg, "\t") && len(diagnostics) > 0 {
+				// continues the previous error, eg: other declaration of Foo
+				last := &diagnostics[len(diagnostics)-1]
+				pos.Filename = relPath(pos.Filename)
+				last.Message += "\n" + pos.String() + ": " + strings.TrimPrefix(typeErr.Msg, "\t")
+				return
+			}
+			d := Diagnostic{Pos: pos, Message: typeErr.Msg}
+			if code, ok := generated[pos.Filename]; ok {
+				d.Plugin = pluginAt(code, pos.Line)
+				d.Message += "\n" + codeContext(code, pos.Line)
+			}
+			d.Pos.Filename = relPath(pos.Filename)
+			diagnostics = append(diagnostics, d)
+		},
+	}
+	conf.Check(files[0].Name.Name, fset, files, nil)
+	return diagnostics, nil
+}
+
+// pluginAt returns the plugin that generated the line of the code
+func pluginAt(code string, line int) string {
+	var plugin string
+	for k, l := range strings.Split(code, "\n") {
+		if k+1 > line {
+			break
+		}
+		if m := pluginMarker.FindStringSubmatch(l); m != nil {
+			plugin = m[1]
+		}
+	}
+	return plugin
+}
+
+func absPath(name string) string {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return name
+	}
+	return abs
+}
+
+// relPath returns the name relative to the working dir, if it is inside it
+func relPath(name string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return name
+	}
+	rel, err := filepath.Rel(wd, name)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return name
+	}
+	return rel
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
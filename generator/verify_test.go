@@ -0,0 +1,126 @@
+package generator
+
+import (
+	"errors"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	src := `package p
+
+type Foo struct {
+	name string
+}
+`
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	source := write("foo.go", src)
+	// a file generated before, declaring the same function
+	stale := write("foo_gen.go", "package p\n\nfunc (f Foo) Name() string { return f.name }\n")
+
+	valid := File{Name: filepath.Join(dir, "foo_foo_gen.go"), Content: `// Code generated by gog; DO NOT EDIT.
+package p
+
+// Generated by gog:getters
+
+func (f Foo) Name() string {
+	return f.name
+}
+`}
+	if err := Verify(source, nil, []File{valid}, []string{stale}); err != nil {
+		t.Errorf("got error %v, want none", err)
+	}
+
+	err := Verify(source, nil, []File{valid}, nil)
+	var diagnostics Diagnostics
+	if !errors.As(err, &diagnostics) || len(diagnostics) != 1 || !strings.Contains(diagnostics[0].Message, "already declared") {
+		t.Errorf("got %v, want the method declared twice", err)
+	}
+
+	invalid := File{Name: valid.Name, Content: `// Code generated by gog; DO NOT EDIT.
+package p
+
+// Generated by gog:getters
+
+func (f Foo) Name() string {
+	return f.name
+}
+
+// Generated by gog:record
+
+func (f Foo) Size() int {
+	return f.name
+}
+`}
+	err = Verify(source, nil, []File{invalid}, []string{stale})
+	if !errors.As(err, &diagnostics) || len(diagnostics) != 1 {
+		t.Fatalf("got %v, want one diagnostic", err)
+	}
+	d := diagnostics[0]
+	if d.Plugin != "record" || d.Pos.Line != 13 || !strings.HasSuffix(d.Pos.Filename, "foo_foo_gen.go") {
+		t.Errorf("got %s:%d from %q, want foo_foo_gen.go:13 from record", d.Pos.Filename, d.Pos.Line, d.Plugin)
+	}
+	if !strings.Contains(d.Message, "cannot use f.name") || !strings.Contains(d.Message, "> 13: \treturn f.name") {
+		t.Errorf("got message %q", d.Message)
+	}
+
+	// the source is read from src instead of the disk
+	err = Verify(source, []byte(strings.Replace(src, "name string", "name int", 1)), []File{valid}, []string{stale})
+	if !errors.As(err, &diagnostics) || len(diagnostics) != 1 || diagnostics[0].Plugin != "getters" {
+		t.Errorf("got %v, want an error in the code of getters", err)
+	}
+}
+
+func TestVerifyNamespacedPlugin(t *testing.T) {
+	saved := generators
+	t.Cleanup(func() {
+		generators = saved
+	})
+	UnregisterAll()
+	// a plugin of another namespace with the same name, that must not be blamed
+	MustRegister(&qualPlugin{body: func(c *Code, mapper Mapper) Decl { return nil }})
+	MustRegister(&qualPlugin{body: func(c *Code, mapper Mapper) Decl {
+		return &Func{
+			Name:    "Size",
+			Results: []Param{{Type: "int"}},
+			Body:    []Stmt{Return(Quote("size"))},
+		}
+	}}, WithNamespace("acme"))
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "foo.go")
+	src := "package p\n\n// gog:acme.qual\ntype Foo struct{}\n"
+	if err := os.WriteFile(source, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, source, src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "foo_gen.go")
+	code, err := InspectGoFile(fset, nil, f).GenerateCode(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(code), "// Generated by gog:acme.qual\n") {
+		t.Errorf("got\n%s\nwant the marker of acme.qual", code)
+	}
+
+	err = Verify(source, nil, []File{{Name: name, Content: string(code)}}, nil)
+	var diagnostics Diagnostics
+	if !errors.As(err, &diagnostics) || len(diagnostics) != 1 || diagnostics[0].Plugin != "acme.qual" {
+		t.Errorf("got %v, want an error in the code of acme.qual", err)
+	}
+}
@@ -0,0 +1,175 @@
+package generator
+
+import (
+	"fmt"
+	"go/build"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+const (
+	goModFile  = "go.mod"
+	goWorkFile = "go.work"
+)
+
+// WithExcludes leaves out of the scan the files and dirs matching the globs.
+// A glob is matched against the slash separated path relative to the scanned dir and against the base name,
+// eg: mocks, internal/legacy or *_mock.go
+func WithExcludes(globs ...string) ScanOption {
+	return func(so *ScanOptions) {
+		so.excludes = append(so.excludes, globs...)
+	}
+}
+
+// walkGoFiles calls fn with the go files of the dir, and of its sub dirs if recursive, finding them the way go list does:
+// dirs starting with . or _, testdata and vendor are left out, and so are nested modules, unless they are used by the go.work workspace.
+// The files excluded by build constraints, the files and dirs matching the exclude globs, and the files generated by gog, are also left out.
+// Unreadable sub dirs are logged and skipped.
+func walkGoFiles(dir string, recursive bool, excludes []string, fn func(path string)) error {
+	for _, glob := range excludes {
+		if _, err := path.Match(glob, ""); err != nil {
+			return fmt.Errorf("invalid exclude glob %q: %w", glob, err)
+		}
+	}
+	modules, err := workspaceModules(absPath(dir))
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if name == dir {
+				return err
+			}
+			log.Printf("warning: skipping %s: %v", name, err)
+			if entry != nil && entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if name == dir {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if entry.IsDir() {
+			if !recursive || ignoredName(entry.Name()) || entry.Name() == "testdata" || entry.Name() == "vendor" ||
+				excluded(rel, excludes) || otherModule(name, modules) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isGoSource(name) && !ignoredName(entry.Name()) && !excluded(rel, excludes) && buildable(name) && !isGenerated(name) {
+			fn(name)
+		}
+		return nil
+	})
+}
+
+// isGenerated returns true if the file was generated by gog, by its name, eg: foo_gen.go, or by its header,
+// so that a scan does not take the output of a previous scan as a source
+func isGenerated(name string) bool {
+	if strings.HasSuffix(name, "_"+genSuffix+goFilesExt) {
+		return true
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	header := make([]byte, len(generatedHeader))
+	_, err = io.ReadFull(file, header)
+	return err == nil && string(header) == generatedHeader
+}
+
+// buildable returns true if the build constraints of the file, its //go:build line and _GOOS or _GOARCH suffixes,
+// are satisfied by the default build context
+func buildable(name string) bool {
+	match, err := build.Default.MatchFile(filepath.Dir(name), filepath.Base(name))
+	return err == nil && match
+}
+
+// ignoredName returns true for the names that the go tool ignores
+func ignoredName(name string) bool {
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+func excluded(rel string, excludes []string) bool {
+	base := path.Base(rel)
+	for _, glob := range excludes {
+		if ok, _ := path.Match(glob, rel); ok {
+			return true
+		}
+		if ok, _ := path.Match(glob, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// otherModule returns true if the dir is the root of a nested module that is not used by the workspace
+func otherModule(dir string, modules map[string]bool) bool {
+	if _, err := os.Stat(filepath.Join(dir, goModFile)); err != nil {
+		return false
+	}
+	return !modules[absPath(dir)]
+}
+
+// workspaceModules returns the dirs of the modules used by the go.work of the dir, if any.
+// Like the go tool, the go.work is set by GOWORK or looked up from the dir upwards, and GOWORK=off disables it.
+func workspaceModules(dir string) (map[string]bool, error) {
+	modules := map[string]bool{}
+	workFile := os.Getenv("GOWORK")
+	switch workFile {
+	case "off":
+		return modules, nil
+	case "":
+		var ok bool
+		workFile, ok = findWorkFile(dir)
+		if !ok {
+			return modules, nil
+		}
+	}
+
+	data, err := os.ReadFile(workFile)
+	if err != nil {
+		return nil, err
+	}
+	work, err := modfile.ParseWork(workFile, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, use := range work.Use {
+		modDir := filepath.FromSlash(use.Path)
+		if !filepath.IsAbs(modDir) {
+			modDir = filepath.Join(filepath.Dir(workFile), modDir)
+		}
+		modules[filepath.Clean(modDir)] = true
+	}
+	return modules, nil
+}
+
+func findWorkFile(dir string) (string, bool) {
+	for {
+		workFile := filepath.Join(dir, goWorkFile)
+		if _, err := os.Stat(workFile); err == nil {
+			return workFile, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,175 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalkGoFiles(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	dir := t.TempDir()
+	files := []string{
+		"go.mod",
+		"foo.go",
+		"foo_test.go",
+		"foo_plan9.go",
+		"tools.go",
+		"_ignored.go",
+		"mocks/mock.go",
+		"bar/bar.go",
+		"bar/bar_mock.go",
+		"bar/testdata/data.go",
+		"vendor/dep/dep.go",
+		".git/hooks.go",
+		"_tools/tool.go",
+		"nested/go.mod",
+		"nested/nested.go",
+		"used/go.mod",
+		"used/used.go",
+	}
+	for _, name := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		content := "package p\n"
+		if name == "tools.go" {
+			content = "//go:build ignore\n\n" + content
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	walk := func(recursive bool, excludes ...string) string {
+		var got []string
+		err := walkGoFiles(dir, recursive, excludes, func(path string) {
+			rel, _ := filepath.Rel(dir, path)
+			got = append(got, filepath.ToSlash(rel))
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.Join(got, ",")
+	}
+
+	tests := []struct {
+		name      string
+		recursive bool
+		excludes  []string
+		work      string
+		want      string
+	}{
+		{
+			name: "dir",
+			want: "foo.go",
+		},
+		{
+			name:      "sub_dirs",
+			recursive: true,
+			want:      "bar/bar.go,bar/bar_mock.go,foo.go,mocks/mock.go",
+		},
+		{
+			name:      "excludes",
+			recursive: true,
+			excludes:  []string{"mocks", "*_mock.go"},
+			want:      "bar/bar.go,foo.go",
+		},
+		{
+			name:      "workspace",
+			recursive: true,
+			work:      "go 1.22\n\nuse (\n\t.\n\t./used\n)\n",
+			want:      "bar/bar.go,bar/bar_mock.go,foo.go,mocks/mock.go,used/used.go",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workFile := filepath.Join(dir, goWorkFile)
+			if tt.work != "" {
+				if err := os.WriteFile(workFile, []byte(tt.work), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				defer os.Remove(workFile)
+			}
+			if got := walk(tt.recursive, tt.excludes...); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if err := walkGoFiles(dir, true, []string{"["}, func(string) {}); err == nil {
+		t.Error("got no error, want an invalid glob error")
+	}
+}
+
+// structPlugin generates a struct, like the builder plugin does
+type structPlugin struct {
+	Code
+}
+
+func (*structPlugin) Name() string {
+	return "builder"
+}
+
+func (*structPlugin) Accepts() []MapperType {
+	return []MapperType{StructMapper}
+}
+
+func (*structPlugin) Imports(Mapper) map[string]string {
+	return map[string]string{}
+}
+
+func (s *structPlugin) GenerateBody(mapper Mapper) error {
+	s.Emit(&StructDecl{Name: mapper.GetName() + "Builder"})
+	return nil
+}
+
+func TestScanDirTwice(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	savedGenerators := generators
+	t.Cleanup(func() { generators = savedGenerators })
+	UnregisterAll()
+	MustRegister(&structPlugin{})
+	SetQuiet(true)
+	t.Cleanup(func() { SetQuiet(false) })
+
+	dir := t.TempDir()
+	sources := map[string]string{
+		"doc.go": "// gog:builder\npackage p\n",
+		"foo.go": "package p\n\ntype Foo struct{}\n",
+	}
+	for name, src := range sources {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the second scan does not take the files generated by the first one as sources
+	for i := 0; i < 2; i++ {
+		ScanDir(dir)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, entry := range entries {
+		got = append(got, entry.Name())
+	}
+	if want := "doc.go,foo.go,foo_gen.go"; strings.Join(got, ",") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+
+	files, err := TaggedFiles(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := range files {
+		files[k] = filepath.Base(files[k])
+	}
+	if want := "doc.go,foo.go"; strings.Join(files, ",") != want {
+		t.Errorf("got tagged files %v, want %s", files, want)
+	}
+}
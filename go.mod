@@ -1,8 +1,14 @@
 module github.com/quintans/gog
 
-go 1.19
+// go 1.22.0, go-cmp v0.6.0 and x/mod v0.21.0 are the minimums required by x/tools v0.26.0.
+go 1.22.0
 
 require (
-	github.com/google/go-cmp v0.5.7
-	golang.org/x/tools v0.0.0-20191024172055-b24f3822ec91
+	github.com/google/go-cmp v0.6.0
+	golang.org/x/mod v0.21.0
+	// x/tools v0.26.0 is the lowest release that builds with the current Go toolchains:
+	// v0.24.0 and older fail to compile internal/tokeninternal. gogvet uses it for the analysis facts.
+	golang.org/x/tools v0.26.0
 )
+
+require golang.org/x/sync v0.8.0 // indirect
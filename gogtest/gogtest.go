@@ -0,0 +1,253 @@
+// Package gogtest helps testing gog plugins.
+// It runs the registered plugins on inline sources, compares the generated code with golden files
+// and type checks the generated code together with the source, so that a test catches code that does not compile.
+//
+//	func TestFoo(t *testing.T) {
+//		gogtest.Run(t, "foo", `
+//	package p
+//
+//	// gog:myplugin
+//	type Foo struct {
+//		name string
+//	}
+//	`)
+//	}
+//
+// The generated code is compared with testdata/foo.golden. Run the tests with -update to write the golden files.
+package gogtest
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/quintans/gog/config"
+	"github.com/quintans/gog/generator"
+)
+
+// Version replaces the gog version in the header of the generated code,
+// so that the expected code does not change with every release
+const Version = "(devel)"
+
+const (
+	versionHeader  = "// Version: "
+	goldenExt      = ".golden"
+	defaultSrcName = "src.go"
+)
+
+var update = flag.Bool("update", false, "update the golden files of gogtest")
+
+type options struct {
+	fileName string
+	importer types.Importer
+	dir      string
+}
+
+type Option func(*options)
+
+// WithFileName sets the name of the source file, src.go by default.
+// The generated file is named after it, eg: src_gog.go
+func WithFileName(name string) Option {
+	return func(o *options) {
+		o.fileName = name
+	}
+}
+
+// WithImporter sets the importer used to type check the code.
+// By default the packages of the standard library and of the modules of the working dir can be imported,
+// like the validation package used by the generated constructors.
+func WithImporter(importer types.Importer) Option {
+	return func(o *options) {
+		o.importer = importer
+	}
+}
+
+// WithGoldenDir sets the dir of the golden files, testdata by default
+func WithGoldenDir(dir string) Option {
+	return func(o *options) {
+		o.dir = dir
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		fileName: defaultSrcName,
+		importer: defaultImporter,
+		dir:      "testdata",
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// defaultImporter imports the packages from their export data, built by go list in the working dir
+var defaultImporter types.Importer = &exportImporter{}
+
+type exportImporter struct {
+	mu       sync.Mutex
+	importer types.Importer
+}
+
+func (e *exportImporter) Import(path string) (*types.Package, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.importer == nil {
+		e.importer = importer.ForCompiler(token.NewFileSet(), "gc", func(path string) (io.ReadCloser, error) {
+			out, err := exec.Command("go", "list", "-export", "-f", "{{.Export}}", path).Output()
+			if err != nil {
+				return nil, fmt.Errorf("listing the export data of %s: %w", path, err)
+			}
+			return os.Open(strings.TrimSpace(string(out)))
+		})
+	}
+	return e.importer.Import(path)
+}
+
+// Run generates the code of the source, type checks it together with the source,
+// and compares it with the golden file <name>.golden
+func Run(t testing.TB, name, src string, opts ...Option) {
+	t.Helper()
+
+	code := Generate(t, src, opts...)
+	TypeCheck(t, src, code, opts...)
+	Golden(t, name, code, opts...)
+}
+
+// Generate runs the plugins of the tags of the source and returns the generated code,
+// with the version of the header replaced by Version
+func Generate(t testing.TB, src string, opts ...Option) string {
+	t.Helper()
+
+	code, err := generate(src, newOptions(opts))
+	if err != nil {
+		t.Fatalf("generating code: %v", err)
+	}
+	return code
+}
+
+// GenerateErr checks that generating the code of the source fails with an error containing wantErr
+func GenerateErr(t testing.TB, src, wantErr string, opts ...Option) {
+	t.Helper()
+
+	_, err := generate(src, newOptions(opts))
+	if err == nil || !strings.Contains(err.Error(), wantErr) {
+		t.Errorf("got error %v, want %q", err, wantErr)
+	}
+}
+
+func generate(src string, o options) (string, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, o.fileName, src, parser.ParseComments)
+	if err != nil {
+		return "", fmt.Errorf("parsing the source: %w", err)
+	}
+	code, err := generator.InspectGoFile(fset, nil, f).GenerateCode(generatedName(o.fileName))
+	if err != nil {
+		return "", err
+	}
+	return Normalize(string(code)), nil
+}
+
+func generatedName(fileName string) string {
+	return strings.TrimSuffix(fileName, ".go") + "_gog.go"
+}
+
+// Normalize replaces the gog version in the header of the generated code by Version
+func Normalize(code string) string {
+	return strings.Replace(code, versionHeader+config.Version+"\n", versionHeader+Version+"\n", 1)
+}
+
+// TypeCheck type checks the generated code together with the source, as a package
+func TypeCheck(t testing.TB, src, code string, opts ...Option) {
+	t.Helper()
+
+	if err := typeCheck(src, code, newOptions(opts)); err != nil {
+		t.Errorf("type checking the generated code:\n%v\ngenerated code:\n%s", err, numbered(code))
+	}
+}
+
+func typeCheck(src, code string, o options) error {
+	fset := token.NewFileSet()
+	var files []*ast.File
+	sources := []struct{ name, content string }{
+		{o.fileName, src},
+		{generatedName(o.fileName), code},
+	}
+	for _, s := range sources {
+		f, err := parser.ParseFile(fset, s.name, s.content, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		files = append(files, f)
+	}
+
+	var errs []error
+	conf := types.Config{
+		Importer: o.importer,
+		Error: func(err error) {
+			errs = append(errs, err)
+		},
+	}
+	conf.Check(files[0].Name.Name, fset, files, nil)
+	return errors.Join(errs...)
+}
+
+// numbered prefixes the lines of the code with their number, to locate the type errors
+func numbered(code string) string {
+	var b strings.Builder
+	for k, line := range strings.Split(strings.TrimSuffix(code, "\n"), "\n") {
+		fmt.Fprintf(&b, "%4d: %s\n", k+1, line)
+	}
+	return b.String()
+}
+
+// Golden compares the code with the golden file <name>.golden, or writes it with -update
+func Golden(t testing.TB, name, code string, opts ...Option) {
+	t.Helper()
+
+	o := newOptions(opts)
+	path := filepath.Join(o.dir, name+goldenExt)
+	if *update {
+		if err := os.MkdirAll(o.dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(code), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading the golden file, run with -update to create it: %v", err)
+	}
+	// the golden files may have been checked out with CRLF line endings
+	want = bytes.ReplaceAll(want, []byte("\r\n"), []byte("\n"))
+	Equal(t, code, string(want))
+}
+
+// Equal compares the generated code with the expected code, showing the diff if they are different
+func Equal(t testing.TB, got, want string) {
+	t.Helper()
+
+	if got != want {
+		t.Errorf("\ngot ----------\n%swant ++++++++++\n%sdiff =========\n%s", got, want, cmp.Diff(got, want))
+	}
+}
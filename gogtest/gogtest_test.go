@@ -0,0 +1,102 @@
+package gogtest_test
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/quintans/gog/gogtest"
+	_ "github.com/quintans/gog/plugins"
+)
+
+func TestRun(t *testing.T) {
+	gogtest.Run(t, "record", `
+package p
+
+import "time"
+
+// gog:record
+type Foo struct {
+	// gog:@required
+	name    string
+	created time.Time
+	tags    []string
+}
+`)
+}
+
+func TestGenerateErr(t *testing.T) {
+	gogtest.GenerateErr(t, `
+package p
+
+// gog:getters pointr=true
+type Foo struct {
+	name string
+}
+`, `unknown option "pointr"`)
+}
+
+func TestNormalize(t *testing.T) {
+	code := gogtest.Generate(t, `
+package p
+
+// gog:getters
+type Foo struct {
+	name string
+}
+`)
+	if !strings.Contains(code, "// Version: "+gogtest.Version+"\n") {
+		t.Errorf("version not normalized:\n%s", code)
+	}
+}
+
+func TestTypeCheck(t *testing.T) {
+	src := `
+package p
+
+type Foo struct {
+	name string
+}
+`
+	code := `package p
+
+func (f Foo) Name() int {
+	return f.name
+}
+`
+	rec := &recorder{TB: t}
+	rec.run(func() {
+		gogtest.TypeCheck(rec, src, code)
+	})
+	if len(rec.errors) != 1 || !strings.Contains(rec.errors[0], "src_gog.go:4:9: cannot use f.name") {
+		t.Errorf("got errors %q, want a type error in src_gog.go", rec.errors)
+	}
+}
+
+// recorder records the errors reported to a testing.TB
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	runtime.Goexit()
+}
+
+// run runs f in its own goroutine, so that Fatalf can stop it
+func (r *recorder) run(f func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	<-done
+}
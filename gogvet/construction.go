@@ -0,0 +1,119 @@
+package gogvet
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/quintans/gog/generator"
+)
+
+// ignoreComment, in the line of a composite literal or in the line before, stops the literal from being reported
+const ignoreComment = "//gogvet:ignore"
+
+var ConstructionAnalyzer = &analysis.Analyzer{
+	Name: "gogconstruct",
+	Doc: `report values of gog managed types created outside of their package without the generated code
+
+The types of plugins like record, value or builder must be created with the generated constructor or builder,
+where their invariants, like @required fields and validate(), are checked.
+A composite literal of such a type, outside of its package, is reported, unless it has the comment ` + ignoreComment + `
+in the same line or in the line before.`,
+	Run:       runConstruction,
+	FactTypes: []analysis.Fact{new(constructedFact)},
+}
+
+var checkNew bool
+
+func init() {
+	ConstructionAnalyzer.Flags.BoolVar(&checkNew, "new", false, "also report new(T) of gog managed types")
+}
+
+// constructedFact marks a type that must be created with the code generated by the plugin
+type constructedFact struct {
+	Plugin string
+}
+
+func (*constructedFact) AFact() {}
+
+func (f *constructedFact) String() string {
+	return "constructed by gog:" + f.Plugin
+}
+
+func runConstruction(pass *analysis.Pass) (interface{}, error) {
+	exportTypeFacts(pass, (*generator.Parser).ConstructedTypes, func(plugin string) analysis.Fact {
+		return &constructedFact{Plugin: plugin}
+	})
+
+	for _, file := range pass.Files {
+		ignored := ignoredLines(pass, file)
+		ast.Inspect(file, func(n ast.Node) bool {
+			var typ types.Type
+			what := "a composite literal"
+			switch n := n.(type) {
+			case *ast.CompositeLit:
+				typ = pass.TypesInfo.TypeOf(n)
+			case *ast.CallExpr:
+				if !checkNew || !isBuiltinNew(pass, n) {
+					return true
+				}
+				typ = pass.TypesInfo.TypeOf(n.Args[0])
+				what = "new"
+			default:
+				return true
+			}
+			if ignored[pass.Fset.Position(n.Pos()).Line] {
+				return true
+			}
+			if obj, fact, ok := constructedType(pass, typ); ok {
+				pass.Reportf(n.Pos(), "%s.%s must be created with the code generated by gog:%s, not %s",
+					obj.Pkg().Name(), obj.Name(), fact.Plugin, what)
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+// constructedType returns the type, if it is a type of another package that must be created with the generated code
+func constructedType(pass *analysis.Pass, typ types.Type) (*types.TypeName, *constructedFact, bool) {
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return nil, nil, false
+	}
+	obj := named.Obj()
+	if obj.Pkg() == nil || obj.Pkg() == pass.Pkg {
+		return nil, nil, false
+	}
+	fact := &constructedFact{}
+	if !pass.ImportObjectFact(obj, fact) {
+		return nil, nil, false
+	}
+	return obj, fact, true
+}
+
+func isBuiltinNew(pass *analysis.Pass, call *ast.CallExpr) bool {
+	ident, ok := call.Fun.(*ast.Ident)
+	if !ok || len(call.Args) != 1 {
+		return false
+	}
+	builtin, ok := pass.TypesInfo.Uses[ident].(*types.Builtin)
+	return ok && builtin.Name() == "new"
+}
+
+// ignoredLines returns the lines where literals are not reported, because of the ignore comment
+func ignoredLines(pass *analysis.Pass, file *ast.File) map[int]bool {
+	lines := map[int]bool{}
+	for _, group := range file.Comments {
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, ignoreComment) {
+				line := pass.Fset.Position(c.Pos()).Line
+				lines[line] = true
+				lines[line+1] = true
+			}
+		}
+	}
+	return lines
+}
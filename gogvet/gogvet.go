@@ -0,0 +1,91 @@
+// Package gogvet is an analyzer that checks the gog annotations, compatible with go vet.
+//
+// It reports unknown gog tags, invalid plugin options, field and method tags that no plugin of the type reads,
+// and the issues found by the checks of the plugins, like a validate method that is not validate() error.
+// The plugins are the ones registered in the binary running the analyzer.
+package gogvet
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/quintans/gog/generator"
+)
+
+var Analyzer = &analysis.Analyzer{
+	Name: "gogvet",
+	Doc:  "check the gog annotations: unknown tags, invalid plugin options, unused field tags and method signatures",
+	Run:  run,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, file := range pass.Files {
+		if isGenerated(file) {
+			continue
+		}
+		for _, d := range inspect(pass, file).Check() {
+			message := d.Message
+			if d.Plugin != "" {
+				message = d.Plugin + ": " + message
+			}
+			pass.Reportf(position(pass.Fset, file, d.Pos), "%s", message)
+		}
+	}
+	return nil, nil
+}
+
+// inspect parses the gog tags of the file, inheriting the package tags of the other files
+func inspect(pass *analysis.Pass, file *ast.File) *generator.Parser {
+	p := generator.InspectGoFile(pass.Fset, nil, file)
+	for _, other := range pass.Files {
+		if other != file {
+			p.AddPackageTags(generator.PackageDocTags(other))
+		}
+	}
+	return p
+}
+
+// exportTypeFacts exports a fact for each type of the package returned by types
+func exportTypeFacts(pass *analysis.Pass, types func(p *generator.Parser) (map[string]string, error), fact func(plugin string) analysis.Fact) {
+	for _, file := range pass.Files {
+		if isGenerated(file) {
+			continue
+		}
+		found, err := types(inspect(pass, file))
+		if err != nil {
+			// reported by gogvet
+			continue
+		}
+		for name, plugin := range found {
+			if obj := pass.Pkg.Scope().Lookup(name); obj != nil {
+				pass.ExportObjectFact(obj, fact(plugin))
+			}
+		}
+	}
+}
+
+// position converts the position of the diagnostic back into a position of the file
+func position(fset *token.FileSet, file *ast.File, pos token.Position) token.Pos {
+	tf := fset.File(file.Pos())
+	if tf == nil || !pos.IsValid() || pos.Line > tf.LineCount() {
+		return file.Package
+	}
+	return tf.LineStart(pos.Line) + token.Pos(pos.Column-1)
+}
+
+func isGenerated(file *ast.File) bool {
+	for _, group := range file.Comments {
+		if group.Pos() > file.Package {
+			break
+		}
+		for _, c := range group.List {
+			if strings.HasPrefix(c.Text, "// Code generated ") && strings.HasSuffix(c.Text, " DO NOT EDIT.") {
+				return true
+			}
+		}
+	}
+	return false
+}
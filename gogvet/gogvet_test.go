@@ -0,0 +1,27 @@
+package gogvet
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	_ "github.com/quintans/gog/plugins"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), Analyzer, "a")
+}
+
+func TestConstructionAnalyzer(t *testing.T) {
+	if err := ConstructionAnalyzer.Flags.Set("new", "true"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		checkNew = false
+	})
+	analysistest.Run(t, analysistest.TestData(), ConstructionAnalyzer, "client")
+}
+
+func TestImmutabilityAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), ImmutabilityAnalyzer, "immutable", "mutator")
+}
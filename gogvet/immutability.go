@@ -0,0 +1,238 @@
+package gogvet
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+
+	"golang.org/x/tools/go/analysis"
+
+	"github.com/quintans/gog/generator"
+)
+
+var ImmutabilityAnalyzer = &analysis.Analyzer{
+	Name: "gogimmutable",
+	Doc: `report changes to the values of immutable gog types
+
+The types of plugins like record or value are immutable.
+Assigning to their fields, outside of the generated code, is reported,
+as well as writing to the slices and maps returned by their getters, that are shared with the value.
+A change is not reported if it has the comment ` + ignoreComment + ` in the same line or in the line before.`,
+	Run:       runImmutability,
+	FactTypes: []analysis.Fact{new(immutableFact)},
+}
+
+// immutableFact marks a type whose values are made immutable by the plugin
+type immutableFact struct {
+	Plugin string
+}
+
+func (*immutableFact) AFact() {}
+
+func (f *immutableFact) String() string {
+	return "immutable by gog:" + f.Plugin
+}
+
+func runImmutability(pass *analysis.Pass) (interface{}, error) {
+	exportTypeFacts(pass, (*generator.Parser).ImmutableTypes, func(plugin string) analysis.Fact {
+		return &immutableFact{Plugin: plugin}
+	})
+
+	for _, file := range pass.Files {
+		if isGenerated(file) {
+			continue
+		}
+		m := &mutations{
+			pass:    pass,
+			ignored: ignoredLines(pass, file),
+			shared:  map[types.Object]getterCall{},
+		}
+		// the variables holding what a getter returned are found first, so that the writes through them are reported
+		ast.Inspect(file, m.findShared)
+		ast.Inspect(file, m.check)
+	}
+	return nil, nil
+}
+
+// getterCall is a call to a getter of an immutable type, returning a slice or a map
+type getterCall struct {
+	typ    *types.TypeName
+	method string
+	kind   string
+	fact   *immutableFact
+}
+
+type mutations struct {
+	pass    *analysis.Pass
+	ignored map[int]bool
+	// shared are the variables holding a slice or map returned by a getter
+	shared map[types.Object]getterCall
+}
+
+func (m *mutations) findShared(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		if len(n.Lhs) != len(n.Rhs) {
+			return true
+		}
+		for k, rhs := range n.Rhs {
+			m.share(n.Lhs[k], rhs)
+		}
+	case *ast.ValueSpec:
+		if len(n.Names) != len(n.Values) {
+			return true
+		}
+		for k, value := range n.Values {
+			m.share(n.Names[k], value)
+		}
+	}
+	return true
+}
+
+func (m *mutations) share(lhs, rhs ast.Expr) {
+	ident, ok := lhs.(*ast.Ident)
+	if !ok {
+		return
+	}
+	if call, ok := m.getterCall(rhs); ok {
+		if obj := m.pass.TypesInfo.ObjectOf(ident); obj != nil {
+			m.shared[obj] = call
+		}
+	}
+}
+
+func (m *mutations) check(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.AssignStmt:
+		if n.Tok == token.DEFINE {
+			return true
+		}
+		for _, lhs := range n.Lhs {
+			m.checkWrite(lhs)
+		}
+	case *ast.IncDecStmt:
+		m.checkWrite(n.X)
+	case *ast.CallExpr:
+		// delete(m, k) and clear(m) change the map
+		ident, ok := n.Fun.(*ast.Ident)
+		if !ok || len(n.Args) == 0 {
+			return true
+		}
+		if builtin, ok := m.pass.TypesInfo.Uses[ident].(*types.Builtin); ok && (builtin.Name() == "delete" || builtin.Name() == "clear") {
+			m.checkShared(n.Args[0], n.Pos())
+		}
+	}
+	return true
+}
+
+// checkWrite reports a write to a field of an immutable value, or to a slice or map shared by a getter
+func (m *mutations) checkWrite(expr ast.Expr) {
+	pos := expr.Pos()
+	for {
+		switch e := expr.(type) {
+		case *ast.ParenExpr:
+			expr = e.X
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			if m.checkShared(e.X, pos) {
+				return
+			}
+			expr = e.X
+		case *ast.SelectorExpr:
+			sel, ok := m.pass.TypesInfo.Selections[e]
+			if !ok || sel.Kind() != types.FieldVal {
+				return
+			}
+			if obj, fact, ok := immutableType(m.pass, sel.Recv()); ok {
+				m.report(pos, "assignment to %s.%s: %s is immutable (gog:%s)", obj.Name(), e.Sel.Name, obj.Name(), fact.Plugin)
+				return
+			}
+			expr = e.X
+		default:
+			return
+		}
+	}
+}
+
+// checkShared reports a write to the slice or map of expr, if it was returned by a getter of an immutable type
+func (m *mutations) checkShared(expr ast.Expr, pos token.Pos) bool {
+	call, ok := m.getterCall(expr)
+	if !ok {
+		ident, isIdent := unparen(expr).(*ast.Ident)
+		if !isIdent {
+			return false
+		}
+		call, ok = m.shared[m.pass.TypesInfo.ObjectOf(ident)]
+		if !ok {
+			return false
+		}
+	}
+	m.report(pos, "write to the %s returned by %s.%s: %s is immutable (gog:%s), copy the %s before changing it",
+		call.kind, call.typ.Name(), call.method, call.typ.Name(), call.fact.Plugin, call.kind)
+	return true
+}
+
+// getterCall returns the call, if it calls a method of an immutable type without arguments, returning a slice or a map
+func (m *mutations) getterCall(expr ast.Expr) (getterCall, bool) {
+	call, ok := unparen(expr).(*ast.CallExpr)
+	if !ok || len(call.Args) != 0 {
+		return getterCall{}, false
+	}
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return getterCall{}, false
+	}
+	sel, ok := m.pass.TypesInfo.Selections[selector]
+	if !ok || sel.Kind() != types.MethodVal {
+		return getterCall{}, false
+	}
+	obj, fact, ok := immutableType(m.pass, sel.Recv())
+	if !ok {
+		return getterCall{}, false
+	}
+	var kind string
+	switch m.pass.TypesInfo.TypeOf(call).Underlying().(type) {
+	case *types.Slice:
+		kind = "slice"
+	case *types.Map:
+		kind = "map"
+	default:
+		return getterCall{}, false
+	}
+	return getterCall{typ: obj, method: selector.Sel.Name, kind: kind, fact: fact}, true
+}
+
+func (m *mutations) report(pos token.Pos, format string, args ...interface{}) {
+	if m.ignored[m.pass.Fset.Position(pos).Line] {
+		return
+	}
+	m.pass.Reportf(pos, format, args...)
+}
+
+// immutableType returns the type, or the type pointed by it, if it is immutable
+func immutableType(pass *analysis.Pass, typ types.Type) (*types.TypeName, *immutableFact, bool) {
+	if ptr, ok := typ.(*types.Pointer); ok {
+		typ = ptr.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok {
+		return nil, nil, false
+	}
+	obj := named.Obj()
+	fact := &immutableFact{}
+	if !pass.ImportObjectFact(obj, fact) {
+		return nil, nil, false
+	}
+	return obj, fact, true
+}
+
+func unparen(expr ast.Expr) ast.Expr {
+	for {
+		paren, ok := expr.(*ast.ParenExpr)
+		if !ok {
+			return expr
+		}
+		expr = paren.X
+	}
+}
@@ -0,0 +1,23 @@
+package a
+
+// gog:recrd // want `unknown tag "recrd", did you mean "record"\?`
+type Foo struct {
+	// gog:@wither // want `tag @wither is not read by any plugin of Foo`
+	name string
+}
+
+// gog:getters
+type Bar struct {
+	// gog:@ignore
+	name string
+}
+
+// gog:record
+type Baz struct {
+	// gog:@required
+	name string
+}
+
+func (b Baz) validate() bool { // want `record: Baz.validate must have the signature validate\(\) error`
+	return b.name != ""
+}
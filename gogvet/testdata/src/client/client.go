@@ -0,0 +1,17 @@
+package client
+
+import "managed"
+
+func values() []interface{} {
+	return []interface{}{
+		managed.Foo{},              // want `managed.Foo must be created with the code generated by gog:record, not a composite literal`
+		&managed.Bar{Name: "a"},    // want `managed.Bar must be created with the code generated by gog:builder, not a composite literal`
+		[]managed.Bar{{Name: "b"}}, // want `managed.Bar must be created with the code generated by gog:builder, not a composite literal`
+		managed.Baz{Name: "c"},
+		managed.NewFoo("d"),
+		new(managed.Foo), // want `managed.Foo must be created with the code generated by gog:record, not new`
+		//gogvet:ignore
+		managed.Foo{},
+		managed.Bar{}, //gogvet:ignore
+	}
+}
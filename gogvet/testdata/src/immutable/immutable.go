@@ -0,0 +1,59 @@
+package immutable
+
+// gog:record
+type Foo struct { // want Foo:"immutable by gog:record"
+	name  string
+	tags  []string
+	attrs map[string]string
+	inner Inner
+}
+
+type Inner struct {
+	Count int
+}
+
+// Foo getters, as they would be generated
+
+func (f Foo) Name() string {
+	return f.name
+}
+
+func (f Foo) Tags() []string {
+	return f.tags
+}
+
+func (f Foo) Attrs() map[string]string {
+	return f.attrs
+}
+
+// Bar is mutable
+type Bar struct {
+	Name string
+	tags []string
+}
+
+func (b Bar) Tags() []string {
+	return b.tags
+}
+
+func (f *Foo) Rename(name string) {
+	f.name = name // want `assignment to Foo.name: Foo is immutable \(gog:record\)`
+}
+
+func (f *Foo) Change() {
+	f.tags[0] = "a"      // want `assignment to Foo.tags: Foo is immutable \(gog:record\)`
+	f.inner.Count++      // want `assignment to Foo.inner: Foo is immutable \(gog:record\)`
+	(*f).attrs["a"] = "" // want `assignment to Foo.attrs: Foo is immutable \(gog:record\)`
+	f.name = "b"         //gogvet:ignore
+}
+
+func NewFoo(name string) Foo {
+	f := Foo{name: name}
+	f.tags = []string{} // want `assignment to Foo.tags: Foo is immutable \(gog:record\)`
+	return f
+}
+
+func mutable(b *Bar) {
+	b.Name = "a"
+	b.Tags()[0] = "a"
+}
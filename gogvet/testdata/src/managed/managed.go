@@ -0,0 +1,21 @@
+package managed
+
+// gog:record
+type Foo struct {
+	// gog:@required
+	name string
+}
+
+// gog:builder
+type Bar struct {
+	Name string
+}
+
+// Baz is not managed by gog
+type Baz struct {
+	Name string
+}
+
+func NewFoo(name string) Foo {
+	return Foo{name: name}
+}
@@ -0,0 +1,20 @@
+package mutator
+
+import "immutable"
+
+func change(f immutable.Foo) {
+	f.Tags()[0] = "a"      // want `write to the slice returned by Foo.Tags: Foo is immutable \(gog:record\), copy the slice before changing it`
+	f.Attrs()["a"] = "b"   // want `write to the map returned by Foo.Attrs: Foo is immutable \(gog:record\), copy the map before changing it`
+	delete(f.Attrs(), "a") // want `write to the map returned by Foo.Attrs: Foo is immutable \(gog:record\), copy the map before changing it`
+
+	tags := f.Tags()
+	tags[1] = "b" // want `write to the slice returned by Foo.Tags: Foo is immutable \(gog:record\), copy the slice before changing it`
+	var attrs = f.Attrs()
+	attrs["c"] += "d" // want `write to the map returned by Foo.Attrs: Foo is immutable \(gog:record\), copy the map before changing it`
+
+	copied := append([]string{}, f.Tags()...)
+	copied[0] = "c"
+	name := f.Name()
+	name = "d"
+	_ = name
+}
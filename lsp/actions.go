@@ -0,0 +1,84 @@
+package lsp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/quintans/gog/generator"
+)
+
+// codeActions offers to regenerate the go file
+func (s *Server) codeActions(uri string) []codeAction {
+	path, ok := uriToPath(uri)
+	if !ok || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+		return []codeAction{}
+	}
+	title := "Regenerate " + filepath.Base(path)
+	return []codeAction{{
+		Title:   title,
+		Kind:    "source",
+		Command: &command{Title: title, Command: generateCommand, Arguments: []interface{}{uri}},
+	}}
+}
+
+func (s *Server) executeCommand(params executeCommandParams) *responseError {
+	if params.Command != generateCommand {
+		return &responseError{Code: codeInvalidParams, Message: "unknown command: " + params.Command}
+	}
+	if len(params.Arguments) != 1 {
+		return &responseError{Code: codeInvalidParams, Message: generateCommand + " expects the uri of the file"}
+	}
+	var uri string
+	if err := json.Unmarshal(params.Arguments[0], &uri); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	s.generate(uri)
+	return nil
+}
+
+// generate regenerates the go file from the open document, or from the disk if it is not open,
+// and publishes the errors as diagnostics
+func (s *Server) generate(uri string) {
+	path, ok := uriToPath(uri)
+	if !ok {
+		s.showMessage(messageError, "gog: cannot generate %s", uri)
+		return
+	}
+	text, open := s.docs[uri]
+	if !open {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			s.showMessage(messageError, "gog: %v", err)
+			return
+		}
+		text = string(src)
+	}
+
+	result := generator.GenerateSource(s.workDir, path, []byte(text))
+	diagnostics := checkTags(uri, text)
+	lines := strings.Split(text, "\n")
+	for _, d := range result.Diagnostics {
+		if d.Pos.Filename == path || d.Pos.Filename == "" {
+			diagnostics = append(diagnostics, toDiagnostic(lines, d))
+		}
+	}
+	s.publish(uri, diagnostics)
+	if len(result.Diagnostics) > 0 {
+		s.showMessage(messageError, "gog: generating %s failed: %s", filepath.Base(path), result.Diagnostics[0].Message)
+		return
+	}
+
+	for _, file := range result.Files {
+		if err := os.MkdirAll(filepath.Dir(file.Name), 0o755); err != nil {
+			s.showMessage(messageError, "gog: %v", err)
+			return
+		}
+		if err := os.WriteFile(file.Name, []byte(file.Content), 0o644); err != nil {
+			s.showMessage(messageError, "gog: %v", err)
+			return
+		}
+	}
+	s.showMessage(messageInfo, "gog: generated %d file(s) for %s", len(result.Files), filepath.Base(path))
+}
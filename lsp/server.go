@@ -0,0 +1,247 @@
+// Package lsp is a language server for the gog tags, speaking the Language Server Protocol over stdio.
+// It completes the tag names and the plugin options, shows the plugin docs on hover,
+// reports unknown tags and invalid options, and regenerates the current file with a code action.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/quintans/gog/config"
+)
+
+const generateCommand = "gog.generate"
+
+// Server is a language server for the gog tags
+type Server struct {
+	in      *bufio.Reader
+	out     io.Writer
+	mu      sync.Mutex
+	docs    map[string]string
+	workDir string
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	wd, _ := os.Getwd()
+	return &Server{
+		in:      bufio.NewReader(in),
+		out:     out,
+		docs:    map[string]string{},
+		workDir: wd,
+	}
+}
+
+// Serve handles the messages until the client exits or the input is closed
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(msg, &req); err != nil {
+			log.Printf("invalid message: %v", err)
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		s.handle(req)
+	}
+}
+
+func (s *Server) handle(req request) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("handling %s: %v", req.Method, r)
+			if req.ID != nil {
+				s.reply(req.ID, nil, &responseError{Code: codeInternalError, Message: fmt.Sprint(r)})
+			}
+		}
+	}()
+
+	result, err := s.dispatch(req)
+	if req.ID == nil {
+		// a notification
+		if err != nil {
+			log.Printf("handling %s: %s", req.Method, err.Message)
+		}
+		return
+	}
+	s.reply(req.ID, result, err)
+}
+
+func (s *Server) dispatch(req request) (interface{}, *responseError) {
+	switch req.Method {
+	case "initialize":
+		var params initializeParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		if dir, ok := uriToPath(params.RootURI); ok {
+			s.workDir = dir
+		}
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:       1,
+				CompletionProvider:     completionOptions{TriggerCharacters: []string{":", " ", "{", ","}},
+				HoverProvider:          true,
+				CodeActionProvider:     true,
+				ExecuteCommandProvider: executeCommandOptions{Commands: []string{generateCommand}},
+			},
+			ServerInfo: serverInfo{Name: "gog", Version: config.Version},
+		}, nil
+	case "initialized", "shutdown", "$/cancelRequest", "textDocument/didSave":
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		delete(s.docs, params.TextDocument.URI)
+		s.publish(params.TextDocument.URI, []diagnostic{})
+		return nil, nil
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return complete(s.line(params.TextDocument.URI, params.Position.Line), params.Position.Character), nil
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		h, ok := hoverAt(s.line(params.TextDocument.URI, params.Position.Line), params.Position)
+		if !ok {
+			return nil, nil
+		}
+		return h, nil
+	case "textDocument/codeAction":
+		var params codeActionParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return s.codeActions(params.TextDocument.URI), nil
+	case "workspace/executeCommand":
+		var params executeCommandParams
+		if err := unmarshalParams(req, &params); err != nil {
+			return nil, err
+		}
+		return nil, s.executeCommand(params)
+	}
+	return nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + req.Method}
+}
+
+func unmarshalParams(req request, v interface{}) *responseError {
+	if err := json.Unmarshal(req.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// update stores the text of the document and publishes its diagnostics
+func (s *Server) update(uri, text string) {
+	s.docs[uri] = text
+	s.publish(uri, checkTags(uri, text))
+}
+
+// line returns the line of the document, if the document is open
+func (s *Server) line(uri string, line int) string {
+	lines := strings.Split(s.docs[uri], "\n")
+	if line < 0 || line >= len(lines) {
+		return ""
+	}
+	return strings.TrimSuffix(lines[line], "\r")
+}
+
+func (s *Server) publish(uri string, diagnostics []diagnostic) {
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: uri, Diagnostics: diagnostics})
+}
+
+func (s *Server) showMessage(typ int, format string, args ...interface{}) {
+	s.notify("window/showMessage", showMessageParams{Type: typ, Message: fmt.Sprintf(format, args...)})
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, err *responseError) {
+	s.write(response{JSONRPC: "2.0", ID: id, Result: result, Error: err})
+}
+
+// read reads a message framed with a Content-Length header
+func (s *Server) read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("invalid Content-Length: %w", err)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length")
+	}
+	msg := make([]byte, length)
+	_, err := io.ReadFull(s.in, msg)
+	return msg, err
+}
+
+func (s *Server) write(msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("encoding message: %v", err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func uriToPath(uri string) (string, bool) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
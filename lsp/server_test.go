@@ -0,0 +1,255 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/quintans/gog/plugins"
+)
+
+const src = `package p
+
+// gog:allArgsContructor
+// gog:getters pointer=true
+// gog:record {}
+type Foo struct {
+	name string
+}
+`
+
+// session sends the messages to a server and returns the messages it writes
+func session(t *testing.T, messages ...interface{}) []map[string]interface{} {
+	t.Helper()
+	var in strings.Builder
+	for _, msg := range messages {
+		data, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(data), data)
+	}
+
+	var out strings.Builder
+	if err := NewServer(strings.NewReader(in.String()), &out).Serve(); err != nil {
+		t.Fatal(err)
+	}
+
+	replies := []map[string]interface{}{}
+	s := &Server{in: bufio.NewReader(strings.NewReader(out.String()))}
+	for {
+		msg, err := s.read()
+		if err == io.EOF {
+			return replies
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		reply := map[string]interface{}{}
+		if err := json.Unmarshal(msg, &reply); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, reply)
+	}
+}
+
+func call(id int, method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notify(method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+func at(line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": "file:///p/foo.go"},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}
+}
+
+func TestServer(t *testing.T) {
+	replies := session(t,
+		call(1, "initialize", map[string]interface{}{}),
+		notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": "file:///p/foo.go", "text": src},
+		}),
+		call(2, "textDocument/completion", at(3, 9)),
+		call(3, "textDocument/completion", at(3, 22)),
+		call(4, "textDocument/hover", at(3, 10)),
+		call(5, "textDocument/codeAction", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": "file:///p/foo.go"},
+		}),
+		call(6, "unknown/method", nil),
+		call(7, "textDocument/completion", at(4, 14)),
+		notify("exit", nil),
+	)
+	if len(replies) != 8 {
+		t.Fatalf("got %d replies, want 8", len(replies))
+	}
+
+	capabilities := replies[0]["result"].(map[string]interface{})["capabilities"].(map[string]interface{})
+	if capabilities["hoverProvider"] != true {
+		t.Errorf("missing hover capability: %v", capabilities)
+	}
+
+	diagnostics := replies[1]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	if len(diagnostics) != 1 {
+		t.Fatalf("got diagnostics %v, want 1", diagnostics)
+	}
+	d := diagnostics[0].(map[string]interface{})
+	if want := `unknown tag "allArgsContructor", did you mean "allArgsConstructor"?`; d["message"] != want {
+		t.Errorf("got diagnostic %q, want %q", d["message"], want)
+	}
+	start := d["range"].(map[string]interface{})["start"].(map[string]interface{})
+	if start["line"] != float64(2) || start["character"] != float64(0) {
+		t.Errorf("got diagnostic start %v, want 2:0", start)
+	}
+
+	if labels := completionLabels(replies[2]); !contains(labels, "getters") || !contains(labels, "record") {
+		t.Errorf("got tag completions %v", labels)
+	}
+	// pointer is already set
+	if labels := completionLabels(replies[3]); len(labels) != 0 {
+		t.Errorf("got option completions %v, want none", labels)
+	}
+	if labels := completionLabels(replies[7]); strings.Join(labels, ",") != "pointer" {
+		t.Errorf("got option completions %v, want [pointer]", labels)
+	}
+
+	contents := replies[4]["result"].(map[string]interface{})["contents"].(map[string]interface{})
+	if value := contents["value"].(string); !strings.Contains(value, "**getters**") || !strings.Contains(value, "`pointer` bool") {
+		t.Errorf("got hover %q", value)
+	}
+
+	actions := replies[5]["result"].([]interface{})
+	if len(actions) != 1 || actions[0].(map[string]interface{})["title"] != "Regenerate foo.go" {
+		t.Errorf("got code actions %v", actions)
+	}
+
+	if replies[6]["error"].(map[string]interface{})["code"] != float64(codeMethodNotFound) {
+		t.Errorf("got %v, want method not found", replies[6])
+	}
+}
+
+// TestServerOtherTypes generates a file with a variadic method, a generic field and a channel field,
+// that the parser once printed to stdout, the stream of the protocol, and the getters panicked on
+func TestServerOtherTypes(t *testing.T) {
+	const src = `package p
+
+type List[T any] struct {
+	items []T
+}
+
+// gog:getters
+type Foo struct {
+	c     chan int
+	names List[string]
+}
+
+func (f Foo) Do(args ...string) {}
+`
+	dir := t.TempDir()
+	uri := "file://" + filepath.ToSlash(filepath.Join(dir, "foo.go"))
+
+	stdout := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	replies := session(t,
+		call(1, "initialize", map[string]interface{}{"rootUri": "file://" + filepath.ToSlash(dir)}),
+		notify("textDocument/didOpen", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "text": src},
+		}),
+		call(2, "workspace/executeCommand", map[string]interface{}{"command": generateCommand, "arguments": []string{uri}}),
+		notify("exit", nil),
+	)
+	os.Stdout = stdout
+	w.Close()
+	printed, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(printed) > 0 {
+		t.Errorf("got %q written to stdout", printed)
+	}
+
+	// initialize, the diagnostics of didOpen and of the generation, the message of the generation and the reply of the command
+	if len(replies) != 5 {
+		t.Fatalf("got replies %v, want 5", replies)
+	}
+	for _, reply := range replies[1:3] {
+		if diagnostics := reply["params"].(map[string]interface{})["diagnostics"].([]interface{}); len(diagnostics) != 0 {
+			t.Errorf("got diagnostics %v, want none", diagnostics)
+		}
+	}
+	if msg := replies[3]["params"].(map[string]interface{})["message"]; msg != "gog: generated 1 file(s) for foo.go" {
+		t.Errorf("got message %q", msg)
+	}
+	if replies[4]["error"] != nil {
+		t.Errorf("got error %v", replies[4]["error"])
+	}
+
+	generated, err := os.ReadFile(filepath.Join(dir, "foo_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"func (f Foo) C() chan int {", "func (f Foo) Names() List[string] {"} {
+		if !strings.Contains(string(generated), want) {
+			t.Errorf("got generated code\n%s\nwant it to contain %q", generated, want)
+		}
+	}
+}
+
+func TestTagIn(t *testing.T) {
+	tests := map[string]string{
+		"// gog:record":               "record",
+		"// gog:-record":              "record",
+		"// gog:getters pointer=true": "getters",
+		"// gog:getters{pointer: 1}":  "getters",
+		"\t// gog:@required":          "@required",
+	}
+	for line, want := range tests {
+		tag, ok := tagIn(line)
+		if !ok || tag.name != want {
+			t.Errorf("%s: got %q, want %q", line, tag.name, want)
+		}
+	}
+	if _, ok := tagIn("// Foo is a foo"); ok {
+		t.Error("expected no tag")
+	}
+}
+
+func TestUTF16(t *testing.T) {
+	line := "// é 😀 gog"
+	if got := utf16Len(line); got != 11 {
+		t.Errorf("got utf16 length %d, want 11", got)
+	}
+	if got := byteOffset(line, 8); line[got:] != "gog" {
+		t.Errorf("got byte offset %d, at %q", got, line[got:])
+	}
+}
+
+func completionLabels(reply map[string]interface{}) []string {
+	labels := []string{}
+	for _, item := range reply["result"].([]interface{}) {
+		labels = append(labels, item.(map[string]interface{})["label"].(string))
+	}
+	return labels
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
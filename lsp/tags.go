@@ -0,0 +1,245 @@
+package lsp
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/quintans/gog/generator"
+)
+
+const (
+	// tagPrefix starts a gog tag comment
+	tagPrefix = "// gog:"
+	// defaultsTag sets the default options of a plugin in the package doc
+	defaultsTag = "defaults"
+	// optOutPrefix removes a tag inherited from the package doc
+	optOutPrefix = "-"
+)
+
+// tagContext is the gog tag in a line
+type tagContext struct {
+	// name is the tag name, without the opt out prefix
+	name string
+	// start and end are the byte offsets of the tag name in the line
+	start, end int
+	// args are the arguments after the name
+	args string
+}
+
+// tagIn finds the gog tag in the line
+func tagIn(line string) (tagContext, bool) {
+	i := strings.Index(line, tagPrefix)
+	if i < 0 {
+		return tagContext{}, false
+	}
+	start := i + len(tagPrefix)
+	end := start + strings.IndexAny(line[start:]+" ", " \t{")
+	name := line[start:end]
+	if strings.HasPrefix(name, optOutPrefix) {
+		start += len(optOutPrefix)
+		name = strings.TrimPrefix(name, optOutPrefix)
+	}
+	return tagContext{name: name, start: start, end: end, args: line[end:]}, true
+}
+
+// complete returns the tag names or the plugin options that can be written at the character of the line
+func complete(line string, character int) []completionItem {
+	items := []completionItem{}
+	tag, ok := tagIn(line)
+	col := byteOffset(line, character)
+	if !ok || col < tag.start || strings.HasPrefix(tag.name, "@") {
+		return items
+	}
+
+	if col <= tag.end {
+		for _, info := range generator.Plugins() {
+			items = append(items, completionItem{
+				Label:         info.Name,
+				Kind:          kindKeyword,
+				Detail:        info.Description,
+				Documentation: &markupContent{Kind: "markdown", Value: pluginDoc(info)},
+			})
+		}
+		for _, name := range generator.Presets() {
+			tags, _ := generator.LookupPreset(name)
+			items = append(items, completionItem{
+				Label:  name,
+				Kind:   kindKeyword,
+				Detail: presetDetail(tags),
+			})
+		}
+		items = append(items, completionItem{
+			Label:  defaultsTag,
+			Kind:   kindKeyword,
+			Detail: "default options of a plugin, in the package doc, eg: gog:defaults getters pointer=true",
+		})
+		return items
+	}
+
+	plugin, args := tag.name, tag.args
+	if plugin == defaultsTag {
+		fields := strings.Fields(line[tag.end:col])
+		if len(fields) == 0 || (len(fields) == 1 && !strings.HasSuffix(line[:col], " ")) {
+			// completing the plugin name of the defaults
+			for _, info := range generator.Plugins() {
+				items = append(items, completionItem{Label: info.Name, Kind: kindKeyword, Detail: info.Description})
+			}
+			return items
+		}
+		plugin, args = fields[0], strings.TrimPrefix(strings.TrimSpace(args), fields[0])
+	}
+	return optionItems(plugin, args)
+}
+
+// optionItems returns the options of the plugin that are not already in the arguments
+func optionItems(plugin, args string) []completionItem {
+	items := []completionItem{}
+	info, ok := generator.LookupPlugin(plugin)
+	if !ok {
+		return items
+	}
+	present := map[string]bool{}
+	if parsed, err := generator.ParseTagArgs(strings.TrimSpace(args)); err == nil {
+		for _, arg := range parsed {
+			present[strings.ToLower(arg.Key)] = true
+		}
+	}
+	for _, o := range info.Options {
+		if present[strings.ToLower(o.Name)] {
+			continue
+		}
+		items = append(items, completionItem{
+			Label:         o.Name,
+			Kind:          kindProperty,
+			Detail:        string(o.Kind),
+			Documentation: &markupContent{Kind: "markdown", Value: o.Description},
+		})
+	}
+	return items
+}
+
+// hoverAt returns the docs of the plugin or preset named by the tag under the position
+func hoverAt(line string, pos position) (hover, bool) {
+	tag, ok := tagIn(line)
+	col := byteOffset(line, pos.Character)
+	if !ok || col < tag.start || col > tag.end {
+		return hover{}, false
+	}
+
+	var doc string
+	if info, ok := generator.LookupPlugin(tag.name); ok {
+		doc = pluginDoc(info)
+	} else if tags, ok := generator.LookupPreset(tag.name); ok {
+		doc = fmt.Sprintf("**%s** preset\n\nexpands into: %s", tag.name, presetDetail(tags))
+	} else if tag.name == defaultsTag {
+		doc = "**defaults**\n\nsets, in the package doc, the default options of a plugin, eg: `gog:defaults getters pointer=true`"
+	} else {
+		return hover{}, false
+	}
+	return hover{
+		Contents: markupContent{Kind: "markdown", Value: doc},
+		Range: &rangeLSP{
+			Start: position{Line: pos.Line, Character: utf16Len(line[:tag.start])},
+			End:   position{Line: pos.Line, Character: utf16Len(line[:tag.end])},
+		},
+	}, true
+}
+
+// pluginDoc describes the plugin in markdown, like `gog describe`
+func pluginDoc(info generator.PluginInfo) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "**%s** %s\n\n", info.Name, info.Version)
+	if info.Description != "" {
+		fmt.Fprintf(&b, "%s\n\n", info.Description)
+	}
+	accepts := make([]string, len(info.Accepts))
+	for k, a := range info.Accepts {
+		accepts[k] = string(a)
+	}
+	fmt.Fprintf(&b, "accepts: %s\n\n", strings.Join(accepts, ", "))
+	if len(info.Options) == 0 {
+		b.WriteString("no options")
+		return b.String()
+	}
+	b.WriteString("options:\n")
+	for _, o := range info.Options {
+		fmt.Fprintf(&b, "- `%s` %s", o.Name, o.Kind)
+		if o.Description != "" {
+			fmt.Fprintf(&b, " - %s", o.Description)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func presetDetail(tags generator.Tags) string {
+	names := make([]string, len(tags))
+	for k, t := range tags {
+		names[k] = t.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// checkTags returns the diagnostics of the gog tags of the document
+func checkTags(uri, text string) []diagnostic {
+	diagnostics := []diagnostic{}
+	fset := token.NewFileSet()
+	// the syntax errors are reported by other tools, and the comments are still parsed
+	file, _ := parser.ParseFile(fset, uri, text, parser.ParseComments)
+	if file == nil {
+		return diagnostics
+	}
+
+	lines := strings.Split(text, "\n")
+	for _, d := range generator.NewParser(fset, file).CheckTags() {
+		diagnostics = append(diagnostics, toDiagnostic(lines, d))
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return diagnostics[i].Range.Start.Line < diagnostics[j].Range.Start.Line
+	})
+	return diagnostics
+}
+
+// toDiagnostic converts the diagnostic, ranging from its position to the end of the line
+func toDiagnostic(lines []string, d generator.Diagnostic) diagnostic {
+	message := d.Message
+	if d.Plugin != "" {
+		message = d.Plugin + ": " + message
+	}
+	r := rangeLSP{}
+	if d.Pos.IsValid() && d.Pos.Line <= len(lines) {
+		line := strings.TrimSuffix(lines[d.Pos.Line-1], "\r")
+		col := d.Pos.Column - 1
+		if col > len(line) {
+			col = len(line)
+		}
+		r.Start = position{Line: d.Pos.Line - 1, Character: utf16Len(line[:col])}
+		r.End = position{Line: d.Pos.Line - 1, Character: utf16Len(line)}
+	}
+	return diagnostic{Range: r, Severity: severityError, Source: "gog", Message: message}
+}
+
+// utf16Len is the length of s in UTF-16 code units, the unit of the LSP characters
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return n
+}
+
+// byteOffset converts the UTF-16 character of the line into a byte offset
+func byteOffset(line string, character int) int {
+	n := 0
+	for i, r := range line {
+		if n >= character {
+			return i
+		}
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return len(line)
+}
@@ -1,14 +1,19 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/quintans/gog/config"
 	"github.com/quintans/gog/generator"
+	"github.com/quintans/gog/lsp"
 
 	_ "github.com/quintans/gog/plugins"
 )
@@ -19,13 +24,56 @@ var (
 	fileName = flag.String("f", "", "file name to be parsed, overriding the environment variable GOFILE value")
 	dir      = flag.String("d", "", "dir to be parsed. If it ends with /...it will be recursive")
 	ver      = flag.Bool("v", false, "version")
+	cfgFile  = flag.String("config", "", "configuration file. By default "+config.FileName+" is looked up from the working dir up to the module root")
+	dirOut   = flag.String("o", "", "output dir. The generated code belongs to the package of this dir and imports the parsed package")
+	pkgOut   = flag.String("pkg", "", "package name of the generated code, when using -o. By default it is the name of the output dir")
+	types    = flag.String("type", "", "comma separated list of the types to generate, each into its own file")
+	line     = flag.Bool("line", false, "only generate the type whose doc comment has the go:generate directive, into its own file. The line of the directive is read from $GOLINE")
+	plugins  = flag.String("plugins", "", "comma separated list of the plugins, or presets, to run")
+	skip     = flag.String("skip", "", "comma separated list of the plugins, or presets, not to run")
+	exclude  = flag.String("exclude", "", "comma separated list of the globs of the files and dirs not to scan, added to the ones in the exclude of the [scan] config section")
+	stdin    = flag.Bool("stdin", false, "read the source of the file set with -filename from stdin and print the generated code to stdout, without writing any file")
+	srcName  = flag.String("filename", "", "file name of the source read from stdin, with -stdin")
+	verify   = flag.Bool("verify", false, "type check the package together with the generated code before writing it. Nothing is written if there are type errors")
+	quiet    = flag.Bool("q", false, "quiet, only printing errors and the files that cannot be read")
+	report   = flag.String("report", "", "print a report of the run to stdout, in the format: json")
+	jsonOut  = flag.Bool("json", false, "print the generated files and the diagnostics as JSON, with -stdin, or the inventory, with list")
 )
 
 func main() {
 	flag.Parse()
 
-	fmt.Println("gog version", config.Version)
+	out := os.Stdout
+	switch {
+	case *stdin, *report != "", generator.Contains([]string{"lsp", "list", "dump", "plugins", "describe"}, flag.Arg(0)):
+		// stdout only has the generated code, the report, the messages of the language server, the inventory, the model
+		// or the plugins
+		out = os.Stderr
+	}
 	if *ver {
+		fmt.Fprintln(out, "gog version", config.Version)
+		return
+	}
+	if *report != "" && *report != "json" {
+		log.Fatalf("unknown report format %q, it must be json", *report)
+	}
+
+	generator.SetQuiet(*quiet)
+	if !*quiet {
+		fmt.Fprintln(out, "gog version", config.Version)
+		for _, info := range generator.Plugins() {
+			log.Printf("Registered generator: %s\n", info.Name)
+		}
+	}
+
+	switch flag.Arg(0) {
+	case "plugins":
+		listPlugins(os.Stdout)
+		return
+	case "describe":
+		if err := describePlugin(os.Stdout, flag.Arg(1)); err != nil {
+			log.Fatal(err)
+		}
 		return
 	}
 
@@ -34,23 +82,221 @@ func main() {
 		log.Println(err)
 	}
 
+	excludes, err := loadConfig(wd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	excludes = append(excludes, splitList(*exclude)...)
+
+	switch flag.Arg(0) {
+	case "lsp":
+		if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "list":
+		if err := list(os.Stdout, wd, flag.Args()[1:], excludes); err != nil {
+			log.Fatal(err)
+		}
+		return
+	case "dump":
+		if err := dump(os.Stdout, wd, flag.Args()[1:], excludes); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	options := scanOptions()
+	if len(excludes) > 0 {
+		options = append(options, generator.WithExcludes(excludes...))
+	}
+
+	if *stdin {
+		if err := generateStdin(os.Stdin, os.Stdout, wd, options); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *report == "" {
+		scan(wd, options)
+		return
+	}
+
+	r := generator.NewReport()
+	scan(wd, append(options, generator.WithReport(r)))
+	r.Finish()
+	if err := encodeJSON(os.Stdout, r); err != nil {
+		log.Fatal(err)
+	}
+	if r.Failed() {
+		os.Exit(1)
+	}
+}
+
+func scan(wd string, options []generator.ScanOption) {
 	fileToParse := getFileToParse()
 	if fileToParse != "" {
-		generator.ScanAndGenerateFile(wd, fileToParse)
+		generator.ScanAndGenerateFile(wd, fileToParse, options...)
 		return
 	}
 
 	if *dir != "" {
 		if strings.HasSuffix(*dir, recurSuffix) {
-			generator.ScanDirAndSubDirs(strings.TrimSuffix(*dir, recurSuffix))
+			generator.ScanDirAndSubDirs(strings.TrimSuffix(*dir, recurSuffix), options...)
 			return
 		}
 
-		generator.ScanDir(*dir)
+		generator.ScanDir(*dir, options...)
 		return
 	}
 
-	generator.ScanCurrentDir()
+	generator.ScanCurrentDir(options...)
+}
+
+func scanOptions() []generator.ScanOption {
+	var options []generator.ScanOption
+	if *dirOut != "" {
+		options = append(options, generator.WithDirOut(*dirOut))
+	}
+	if *pkgOut != "" {
+		options = append(options, generator.WithPackageOut(*pkgOut))
+	}
+	if *types != "" {
+		options = append(options, generator.WithTypes(splitList(*types)...))
+	} else if *line {
+		goLine, err := strconv.Atoi(os.Getenv("GOLINE"))
+		if err != nil {
+			log.Fatal("-line must run from a go:generate directive, that sets $GOLINE")
+		}
+		options = append(options, generator.WithLine(goLine))
+	}
+	if *plugins != "" {
+		options = append(options, generator.WithPlugins(splitList(*plugins)...))
+	}
+	if *skip != "" {
+		options = append(options, generator.WithSkip(splitList(*skip)...))
+	}
+	if *verify {
+		options = append(options, generator.WithVerify())
+	}
+	return options
+}
+
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// generateStdin generates the code for the source read from in, and writes the generated files to out
+func generateStdin(in io.Reader, out io.Writer, wd string, options []generator.ScanOption) error {
+	if *srcName == "" {
+		return fmt.Errorf("-stdin requires -filename")
+	}
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	result := generator.GenerateSource(wd, *srcName, src, options...)
+
+	if *jsonOut {
+		return encodeJSON(out, result)
+	}
+
+	for _, file := range result.Files {
+		if len(result.Files) > 1 {
+			fmt.Fprintf(out, "// File: %s\n", file.Name)
+		}
+		fmt.Fprint(out, file.Content)
+	}
+	if len(result.Diagnostics) > 0 {
+		for _, d := range result.Diagnostics {
+			log.Println(d)
+		}
+		return fmt.Errorf("generating %s failed", *srcName)
+	}
+	return nil
+}
+
+// list prints the tagged types of the files or dirs in args, with the plugins that run for them and what they generate
+func list(out io.Writer, wd string, args, excludes []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	asJSON := fs.Bool("json", *jsonOut, "print the inventory as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	files, err := taggedFiles(fs.Args(), excludes)
+	if err != nil {
+		return err
+	}
+
+	inventory := []generator.TypeInventory{}
+	for _, file := range files {
+		types, err := generator.ListFile(wd, file)
+		if err != nil {
+			return fmt.Errorf("listing %s: %w", file, err)
+		}
+		inventory = append(inventory, types...)
+	}
+
+	if *asJSON {
+		return encodeJSON(out, inventory)
+	}
+	for _, t := range inventory {
+		fmt.Fprint(out, t)
+	}
+	return nil
+}
+
+// dump prints the parsed model of the files or dirs in args as JSON
+func dump(out io.Writer, wd string, args, excludes []string) error {
+	files, err := taggedFiles(args, excludes)
+	if err != nil {
+		return err
+	}
+
+	d := generator.NewDump()
+	for _, file := range files {
+		f, err := generator.DumpFile(wd, file)
+		if err != nil {
+			return fmt.Errorf("dumping %s: %w", file, err)
+		}
+		d.Files = append(d.Files, f)
+	}
+	return encodeJSON(out, d)
+}
+
+// taggedFiles returns the tagged go files of the paths, the current dir by default, leaving out the excluded ones.
+// A path can be a go file or a dir, listed recursively if it ends with /...
+func taggedFiles(paths, excludes []string) ([]string, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	var files []string
+	for _, path := range paths {
+		if strings.HasSuffix(path, ".go") {
+			files = append(files, path)
+			continue
+		}
+		recursive := strings.HasSuffix(path, recurSuffix)
+		tagged, err := generator.TaggedFiles(strings.TrimSuffix(path, recurSuffix), recursive, generator.WithExcludes(excludes...))
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, tagged...)
+	}
+	return files, nil
+}
+
+func encodeJSON(out io.Writer, v interface{}) error {
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
 }
 
 func getFileToParse() string {
@@ -60,3 +306,73 @@ func getFileToParse() string {
 
 	return os.Getenv("GOFILE")
 }
+
+func listPlugins(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tACCEPTS\tDESCRIPTION")
+	for _, info := range generator.Plugins() {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", info.Name, info.Version, joinAccepts(info.Accepts), info.Description)
+	}
+	w.Flush()
+}
+
+func describePlugin(out io.Writer, name string) error {
+	if name == "" {
+		return fmt.Errorf("usage: gog describe <plugin>")
+	}
+	info, ok := generator.LookupPlugin(name)
+	if !ok {
+		return fmt.Errorf("unknown plugin %q", name)
+	}
+
+	fmt.Fprintf(out, "%s %s\n", info.Name, info.Version)
+	if info.Description != "" {
+		fmt.Fprintf(out, "%s\n", info.Description)
+	}
+	fmt.Fprintf(out, "\naccepts: %s\n", joinAccepts(info.Accepts))
+	if info.OutOfPackage {
+		fmt.Fprintln(out, "can generate into another package")
+	}
+
+	if len(info.Options) == 0 {
+		fmt.Fprintln(out, "\nno options")
+		return nil
+	}
+	fmt.Fprintln(out, "\noptions:")
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, o := range info.Options {
+		fmt.Fprintf(w, "  %s\t%s\t%s\n", o.Name, o.Kind, o.Description)
+	}
+	return w.Flush()
+}
+
+func joinAccepts(types []generator.MapperType) string {
+	accepts := make([]string, len(types))
+	for k, a := range types {
+		accepts[k] = string(a)
+	}
+	return strings.Join(accepts, ",")
+}
+
+// loadConfig registers the presets of the config file and returns its exclude globs
+func loadConfig(wd string) ([]string, error) {
+	path := *cfgFile
+	if path == "" {
+		var ok bool
+		path, ok = config.Find(wd)
+		if !ok {
+			return nil, nil
+		}
+	}
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, err
+	}
+	for name, definition := range cfg.Presets {
+		if err := generator.RegisterPreset(name, definition); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return cfg.Exclude, nil
+}
@@ -1,17 +1,28 @@
 package plugins
 
 import (
+	"go/token"
+
+	"github.com/quintans/gog/config"
 	"github.com/quintans/gog/generator"
 )
 
 func init() {
-	generator.Register(&AllArgsConstructor{})
+	generator.MustRegister(
+		&AllArgsConstructor{},
+		generator.WithVersion(config.Version),
+		generator.WithDescription("generates a constructor that includes all the fields"),
+		generator.WithOptions(AllArgsConstructorOptions{}),
+		generator.WithOutOfPackage(),
+		generator.WithFieldTags(RequiredTag, DefaultTag),
+		generator.WithCheck(checkConstructor),
+	)
 }
 
 type AllArgsConstructorOptions struct{}
 
 type AllArgsConstructor struct {
-	generator.Scribler
+	generator.Code
 }
 
 func (c AllArgsConstructor) Name() string {
@@ -27,58 +38,88 @@ func (c AllArgsConstructor) Imports(mapper generator.Mapper) map[string]string {
 }
 
 func (c *AllArgsConstructor) GenerateBody(mapper generator.Mapper) error {
-	c.WriteBody(mapper, AllArgsConstructorOptions{})
+	options := AllArgsConstructorOptions{}
+	if err := unmarshalOptions(mapper, c.Name(), &options); err != nil {
+		return err
+	}
+	c.WriteBody(mapper, options)
 	return nil
 }
 
 func (c *AllArgsConstructor) WriteBody(mapper generator.Mapper, _ AllArgsConstructorOptions) {
-	args := &generator.Scribler{}
-	hasError := false
-	for _, field := range mapper.GetFields() {
-		args.BPrintf("%s %s,\n", generator.UncapFirst(field.NameOrKindName()), field.Kind.String())
-		if !hasError && field.HasTag(RequiredTag) {
+	structName := mapper.GetName()
+	fields := mapper.GetFields()
+
+	_, hasError := mapper.FindMethod(ValidateMethodName)
+	for _, field := range fields {
+		if field.HasTag(RequiredTag) {
 			hasError = true
 		}
 	}
-	structName := mapper.GetName()
-	receiver := generator.UncapFirstSingle(structName)
-	s := &generator.Scribler{}
+
+	scope := generator.NewScope()
 	if hasError {
-		_ = PrintZeroCheck(s, mapper, "")
+		// the parameters cannot shadow the identifiers used in the body
+		scope.Reserve("panic")
 	}
-
-	s.BPrintf("%s := %s{\n", receiver, structName)
-	for _, field := range mapper.GetFields() {
-		fieldName := field.NameOrKindName()
-		s.BPrintf("	%s: %s,\n", fieldName, field.NameForField())
+	params := make([]generator.Param, len(fields))
+	args := make([]generator.Expr, len(fields))
+	paramOf := map[string]generator.Expr{}
+	elems := make([]generator.KeyValue, len(fields))
+	for k, field := range fields {
+		name := scope.Name(generator.UncapFirst(field.NameOrKindName()))
+		params[k] = generator.Param{Name: name, Type: field.Kind.String()}
+		args[k] = generator.Id(name)
+		paramOf[field.NameOrKindName()] = args[k]
+		elems[k] = generator.KeyValue{Key: field.NameOrKindName(), Value: args[k]}
 	}
-	s.BPrintf("  }\n")
-
-	hasError = PrintValidate(s, mapper, receiver) || hasError
+	local := scope.Name(generator.UncapFirstSingle(structName))
 
-	retCode := structName
-	if hasError {
-		retCode = "(" + retCode + ", error)"
+	// the zero arguments are replaced by the default values
+	body := defaultAssigns(mapper, func(field generator.Field) generator.Expr {
+		return paramOf[field.NameOrKindName()]
+	})
+	if !hasError {
+		body = append(body, generator.Define(local, generator.Lit(structName, elems...)), generator.Blank(), generator.Return(generator.Id(local)))
+	} else {
+		errs := scope.Name("errs")
+		body = append(body, newErrs(&c.Code, mapper, errs))
+		body = append(body, zeroChecks(mapper, errs, func(field generator.Field) generator.Expr {
+			return paramOf[field.NameOrKindName()]
+		})...)
+		body = append(body, generator.Define(local, generator.Lit(structName, elems...)))
+		if validate, ok := validateCall(mapper, local, errs); ok {
+			body = append(body, validate)
+		}
+		body = append(body, returnErrs(mapper, errs), generator.Blank(), generator.Return(generator.Id(local), generator.Id("nil")))
 	}
-	c.BPrintf("\nfunc New%s(\n%s) %s {\n", structName, args, retCode)
-	c.BPrintf("%s\n", s)
-	c.BPrintf("return %s", receiver)
+	results := []generator.Param{{Type: structName}}
 	if hasError {
-		c.BPrintf(", nil")
+		results = append(results, generator.Param{Type: "error"})
 	}
-	c.BPrintf("\n}\n")
+	c.Emit(&generator.Func{
+		Name:      "New" + structName,
+		Params:    params,
+		Results:   results,
+		Multiline: true,
+		Body:      body,
+	})
 
 	if hasError {
-		c.BPrintf("\nfunc MustNew%s(\n%s) %s {\n", structName, args, structName)
-		c.BPrintf("  %s, err := New%s(\n", receiver, structName)
-		for _, field := range mapper.GetFields() {
-			c.BPrintf("%s,\n", generator.UncapFirst(field.NameOrKindName()))
-		}
-		c.BPrintf(")\n")
-		c.BPrintf("  if err != nil {\n")
-		c.BPrintf("    panic(err)\n")
-		c.BPrintf("  }\n")
-		c.BPrintf("  return %s\n", receiver)
-		c.BPrintf("}\n")
+		err := scope.Name("err")
+		c.Emit(&generator.Func{
+			Name:      "MustNew" + structName,
+			Params:    params,
+			Results:   []generator.Param{{Type: structName}},
+			Multiline: true,
+			Body: []generator.Stmt{
+				generator.Define(local+", "+err, generator.CallLines(generator.Id("New"+structName), args...)),
+				generator.If(
+					generator.Binary(generator.Id(err), token.NEQ, generator.Id("nil")),
+					generator.Do(generator.Call(generator.Id("panic"), generator.Id(err))),
+				),
+				generator.Return(generator.Id(local)),
+			},
+		})
 	}
 }
@@ -2,9 +2,13 @@ package plugins
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/quintans/gog/config"
+	"github.com/quintans/gog/generator"
 )
 
 func TestAllArgsConstructor(t *testing.T) {
@@ -59,7 +63,7 @@ type Foo struct {
 // Version: %s
 package p
 
-import "errors"
+import "github.com/quintans/gog/validation"
 
 // Generated by gog:allArgsConstructor
 
@@ -67,13 +71,17 @@ func NewFoo(
 	name string,
 	value int64,
 ) (Foo, error) {
+	errs := validation.New("Foo")
 	if name == "" {
-		return Foo{}, errors.New("Foo.name cannot be empty")
+		errs.Require("name")
 	}
 	f := Foo{
 		name:  name,
 		value: value,
 	}
+	if err := errs.Err(); err != nil {
+		return Foo{}, err
+	}
 
 	return f, nil
 }
@@ -114,7 +122,7 @@ type Foo struct {
 // Version: %s
 package p
 
-import "errors"
+import "github.com/quintans/gog/validation"
 
 // Generated by gog:allArgsConstructor
 
@@ -124,17 +132,18 @@ func NewFoo(
 	anything interface{},
 	factory func() interface{},
 ) (Foo, error) {
+	errs := validation.New("Foo")
 	if len(names) == 0 {
-		return Foo{}, errors.New("Foo.names cannot be empty")
+		errs.Require("names")
 	}
 	if len(values) == 0 {
-		return Foo{}, errors.New("Foo.values cannot be empty")
+		errs.Require("values")
 	}
 	if anything == nil {
-		return Foo{}, errors.New("Foo.anything cannot be empty")
+		errs.Require("anything")
 	}
 	if factory == nil {
-		return Foo{}, errors.New("Foo.factory cannot be empty")
+		errs.Require("factory")
 	}
 	f := Foo{
 		names:    names,
@@ -142,6 +151,9 @@ func NewFoo(
 		anything: anything,
 		factory:  factory,
 	}
+	if err := errs.Err(); err != nil {
+		return Foo{}, err
+	}
 
 	return f, nil
 }
@@ -180,7 +192,7 @@ type Foo struct {
 
 func (f Foo) validate() error {
 	if len(f.name) <= 3 {
-		error.New("name length must be higher than 3")
+		return errors.New("name length must be higher than 3")
 	}
 	return nil
 }
@@ -189,17 +201,23 @@ func (f Foo) validate() error {
 // Version: %s
 package p
 
+import "github.com/quintans/gog/validation"
+
 // Generated by gog:allArgsConstructor
 
 func NewFoo(
 	name string,
 	value int64,
 ) (Foo, error) {
+	errs := validation.New("Foo")
 	f := Foo{
 		name:  name,
 		value: value,
 	}
-	if err := f.validate(); err != nil {
+	if !errs.HasViolations() {
+		errs.Add(f.validate())
+	}
+	if err := errs.Err(); err != nil {
 		return Foo{}, err
 	}
 
@@ -219,6 +237,182 @@ func MustNewFoo(
 	}
 	return f
 }
+`, config.Version),
+		},
+		{
+			"AllArgsConstructor_name_collisions",
+			`
+package p
+
+// gog:allArgsConstructor
+type Foo struct {
+	// gog:@required
+	f          string
+	err        int
+	errors     []string
+	errs       int
+	validation bool
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+import validation2 "github.com/quintans/gog/validation"
+
+// Generated by gog:allArgsConstructor
+
+func NewFoo(
+	f string,
+	err int,
+	errors []string,
+	errs int,
+	validation bool,
+) (Foo, error) {
+	errs1 := validation2.New("Foo")
+	if f == "" {
+		errs1.Require("f")
+	}
+	f1 := Foo{
+		f:          f,
+		err:        err,
+		errors:     errors,
+		errs:       errs,
+		validation: validation,
+	}
+	if err := errs1.Err(); err != nil {
+		return Foo{}, err
+	}
+
+	return f1, nil
+}
+
+func MustNewFoo(
+	f string,
+	err int,
+	errors []string,
+	errs int,
+	validation bool,
+) Foo {
+	f1, err1 := NewFoo(
+		f,
+		err,
+		errors,
+		errs,
+		validation,
+	)
+	if err1 != nil {
+		panic(err1)
+	}
+	return f1
+}
+`, config.Version),
+		},
+		{
+			"AllArgsConstructor_with_defaults",
+			`
+package p
+
+import "time"
+
+// gog:allArgsConstructor
+type Foo struct {
+	// gog:@required
+	name string
+	// gog:@default 3
+	retries int
+	// gog:@default {"value": "time.Second*30"}
+	timeout time.Duration
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+import (
+	"time"
+
+	"github.com/quintans/gog/validation"
+)
+
+// Generated by gog:allArgsConstructor
+
+func NewFoo(
+	name string,
+	retries int,
+	timeout time.Duration,
+) (Foo, error) {
+	if retries == 0 {
+		retries = 3
+	}
+	if timeout == *new(time.Duration) {
+		timeout = time.Second * 30
+	}
+	errs := validation.New("Foo")
+	if name == "" {
+		errs.Require("name")
+	}
+	f := Foo{
+		name:    name,
+		retries: retries,
+		timeout: timeout,
+	}
+	if err := errs.Err(); err != nil {
+		return Foo{}, err
+	}
+
+	return f, nil
+}
+
+func MustNewFoo(
+	name string,
+	retries int,
+	timeout time.Duration,
+) Foo {
+	f, err := NewFoo(
+		name,
+		retries,
+		timeout,
+	)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+`, config.Version),
+		},
+		{
+			"AllArgsConstructor_with_defaults_without_error",
+			`
+package p
+
+// gog:allArgsConstructor
+type Foo struct {
+	name string
+	// gog:@default "x"
+	kind string
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+// Generated by gog:allArgsConstructor
+
+func NewFoo(
+	name string,
+	kind string,
+) Foo {
+	if kind == "" {
+		kind = "x"
+	}
+	f := Foo{
+		name: name,
+		kind: kind,
+	}
+
+	return f
+}
 `, config.Version),
 		},
 	}
@@ -229,3 +423,134 @@ func MustNewFoo(
 		})
 	}
 }
+
+func TestConstructorsOutOfPackage(t *testing.T) {
+	src := `package geo
+
+// gog:allArgsConstructor
+// gog:requiredArgsConstructor
+type Point struct {
+	// gog:@required
+	Name  string
+	Label Label
+	X     int
+}
+
+type Label string
+`
+	main := `package main
+
+import (
+	"fmt"
+
+	shapes "example.com/m/points"
+)
+
+func main() {
+	fmt.Print(shapes.MustNewPoint("a", "b", 1), shapes.NewPointRequired("c"))
+}
+`
+	// the generated code imports the validation package of this module
+	root, err := filepath.Abs("..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := os.ReadFile(filepath.Join(root, "go.sum"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":        "module example.com/m\n\ngo 1.22.0\n\nrequire github.com/quintans/gog v0.0.0\n\nreplace github.com/quintans/gog => " + root + "\n",
+		"go.sum":        string(sum),
+		"geo/geo.go":    src,
+		"main.go":       main,
+		"points/doc.go": "package shapes\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// gog -d geo -o points -pkg shapes
+	generator.ScanDir(filepath.Join(dir, "geo"), generator.WithDirOut(filepath.Join(dir, "points")), generator.WithPackageOut("shapes"))
+
+	got, err := os.ReadFile(filepath.Join(dir, "points", "geo_gen.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package shapes
+
+import (
+	"example.com/m/geo"
+	"github.com/quintans/gog/validation"
+)
+
+// Generated by gog:allArgsConstructor
+
+func NewPoint(
+	name string,
+	label geo.Label,
+	x int,
+) (geo.Point, error) {
+	errs := validation.New("Point")
+	if name == "" {
+		errs.Require("Name")
+	}
+	p := geo.Point{
+		Name:  name,
+		Label: label,
+		X:     x,
+	}
+	if err := errs.Err(); err != nil {
+		return geo.Point{}, err
+	}
+
+	return p, nil
+}
+
+func MustNewPoint(
+	name string,
+	label geo.Label,
+	x int,
+) geo.Point {
+	p, err := NewPoint(
+		name,
+		label,
+		x,
+	)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Generated by gog:requiredArgsConstructor
+
+func NewPointRequired(name string) geo.Point {
+	return geo.Point{
+		Name: name,
+	}
+}
+`, config.Version)
+	if string(got) != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("running the generated code: %v\n%s", err, out)
+	}
+	if want := "{a b 1} {c  0}"; string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+}
@@ -2,11 +2,14 @@ package plugins
 
 import (
 	"fmt"
+	"go/parser"
+	"go/token"
 	"strings"
 	"testing"
 
 	"github.com/quintans/gog/config"
 	"github.com/quintans/gog/generator"
+	"github.com/quintans/gog/gogtest"
 )
 
 const (
@@ -18,7 +21,7 @@ const (
 // Example of how to build a custom aspect generator
 
 func TestCustomPlugin(t *testing.T) {
-	generator.Register(&Aspect{})
+	registerAspect(t)
 
 	tests := []struct {
 		name string
@@ -194,15 +197,105 @@ func (a *BarAspect) Handle(ctx context.Context, code string) (int, error) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			run(t, tt.in, tt.out)
+			// the generated code calls a made up transaction package, so it is not type checked
+			got := gogtest.Generate(t, tt.in)
+			gogtest.Equal(t, got, gogtest.Normalize(tt.out))
 		})
 	}
 }
 
+func TestCustomPluginCheck(t *testing.T) {
+	registerAspect(t)
+
+	src := `package p
+
+import "context"
+
+// gog:aspect
+type Foo struct {
+	// gog:@required
+	name string
+}
+
+// gog:@transactional
+func (f Foo) Save(code string) error {
+	return nil
+}
+
+// gog:@transactional
+func (f Foo) Touch(ctx context.Context) {
+}
+
+// gog:@secured {"roles": ["user"]}
+// gog:@cached
+func (f Foo) WhoAmI(ctx context.Context) (string, error) {
+	return "myname", nil
+}
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "src.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, d := range generator.InspectGoFile(fset, nil, file).Check() {
+		got = append(got, d.Error())
+	}
+	want := []string{
+		"src.go:7:2: tag @required is not read by any plugin of Foo",
+		"src.go:12:14: aspect: Foo.Save must have a context.Context argument to use @transactional",
+		"src.go:17:14: aspect: Foo.Touch must return an error to use @transactional",
+		"src.go:21:1: tag @cached is not read by any plugin of Foo",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func registerAspect(t *testing.T) {
+	t.Helper()
+	aspect := &Aspect{}
+	err := generator.Register(aspect,
+		generator.WithMethodTags(AspectTxTag, AspectMonitorTag, AspectSecuredTag),
+		generator.WithCheck(checkAspect),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		generator.Unregister(aspect)
+	})
+}
+
+// checkAspect checks that the methods have what the aspects use
+func checkAspect(mapper generator.Mapper) []generator.Issue {
+	var issues []generator.Issue
+	for _, m := range mapper.GetMethods() {
+		if !m.IsExported() {
+			continue
+		}
+		for _, tag := range m.Tags.Filter(AspectTxTag, AspectSecuredTag) {
+			if m.ContextArgName() == "" {
+				issues = append(issues, generator.Issue{
+					Pos:     m.Pos,
+					Message: fmt.Sprintf("%s.%s must have a context.Context argument to use %s", mapper.GetName(), m.Name(), tag.Name),
+				})
+			}
+		}
+		if m.HasTag(AspectTxTag) && (len(m.Results) == 0 || !m.Results[len(m.Results)-1].IsError()) {
+			issues = append(issues, generator.Issue{
+				Pos:     m.Pos,
+				Message: fmt.Sprintf("%s.%s must return an error to use %s", mapper.GetName(), m.Name(), AspectTxTag),
+			})
+		}
+	}
+	return issues
+}
+
 type AspectOptions struct{}
 
 type Aspect struct {
-	generator.Scribler
+	generator.Code
 }
 
 func (a Aspect) Name() string {
@@ -254,7 +347,7 @@ func (a *Aspect) WriteBody(mapper generator.Mapper, _ AspectOptions) error {
 				if er := tag.Unmarshal(&options); er != nil {
 					return er
 				}
-				body = monitor(&m, methodName, options)
+				body = monitor(&a.Code, &m, methodName, options)
 			case AspectTxTag:
 				body, err = transactional(&m, methodName)
 				if err != nil {
@@ -297,17 +390,17 @@ type AspectSecuredOptions struct {
 	Roles []string
 }
 
-func monitor(m *generator.Method, methodName string, options AspectMonitorOptions) string {
+func monitor(c *generator.Code, m *generator.Method, methodName string, options AspectMonitorOptions) string {
 	sign := m.Signature(false)
 	s := generator.Scribler{}
 	s.BPrintf(`func%s{
-		now := time.Now()
+		now := %s()
 		defer func(){
-			if time.Since(now) > %d*time.Second {
-				fmt.Println("slow call")
+			if %s(now) > %d*%s {
+				%s("slow call")
 			}
 		}()
-	`, sign, options.Threshold)
+	`, sign, c.Qual("time", "Now"), c.Qual("time", "Since"), options.Threshold, c.Qual("time", "Second"), c.Qual("fmt", "Println"))
 	if m.HasResults() {
 		s.BPrintf("return ")
 	}
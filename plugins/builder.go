@@ -2,23 +2,41 @@ package plugins
 
 import (
 	"fmt"
+	"go/token"
 	"strings"
 
+	"github.com/quintans/gog/config"
 	"github.com/quintans/gog/generator"
 )
 
 func init() {
-	generator.Register(&Builder{})
+	generator.MustRegister(
+		&Builder{},
+		generator.WithVersion(config.Version),
+		generator.WithDescription("generates a builder for the struct"),
+		generator.WithOptions(BuilderOptions{}),
+		generator.WithConstruction(),
+		generator.WithFieldTags(RequiredTag, DefaultTag),
+		generator.WithEmbeds("getters"),
+		generator.WithCheck(checkBuilder),
+	)
 }
 
-type BuilderOptions struct{}
+type BuilderOptions struct {
+	Pointer bool `desc:"getters of the struct use pointer receivers"`
+}
 
 type Builder struct {
-	generator.Scribler
+	generator.Code
 }
 
+const builderPlugin = "builder"
+
+// builderRecv is the receiver of the methods of the builders
+var builderRecv = generator.Id("b")
+
 func (b *Builder) Name() string {
-	return "builder"
+	return builderPlugin
 }
 
 func (*Builder) Accepts() []generator.MapperType {
@@ -30,105 +48,439 @@ func (b *Builder) Imports(mapper generator.Mapper) map[string]string {
 }
 
 func (b *Builder) GenerateBody(mapper generator.Mapper) error {
-	return b.WriteBody(mapper, BuilderOptions{})
+	options := BuilderOptions{}
+	if err := unmarshalOptions(mapper, b.Name(), &options); err != nil {
+		return err
+	}
+	return b.WriteBody(mapper, options)
 }
 
-func (b *Builder) WriteBody(mapper generator.Mapper, _ BuilderOptions) error {
-	b.genStructAndNew(mapper)
-	b.genBuilderSetters(mapper)
-	b.genBuild(mapper)
-	b.genToBuild(mapper)
-	err := b.genGetters(mapper)
+func (b *Builder) WriteBody(mapper generator.Mapper, options BuilderOptions) error {
+	nested := nestedBuilders(mapper)
+	b.genStructAndNew(mapper, nested)
+	b.genBuilderSetters(mapper, nested)
+	b.Emit(buildFunc(&b.Code, mapper, "*"+mapper.GetName()+"Builder", nested))
+	b.Emit(toBuildFunc(mapper, mapper.GetName()+"Builder", "*"+mapper.GetName()+"Builder"))
+	err := b.genGetters(mapper, options)
 	if err != nil {
 		return fmt.Errorf("generating Builder getters: %w", err)
 	}
 
-	_ = PrintIsZero(&b.Scribler, mapper)
-	_ = PrintString(&b.Scribler, mapper)
+	emitIsZeroAndString(&b.Code, mapper)
 
 	return nil
 }
 
-func (b *Builder) genStructAndNew(mapper generator.Mapper) {
+func (b *Builder) genStructAndNew(mapper generator.Mapper, nested []nestedBuilder) {
 	structName := mapper.GetName()
-	b.BPrintf("\ntype %sBuilder struct {\n", structName)
+	builderName := structName + "Builder"
+	fields := []generator.Param{}
 	for _, field := range mapper.GetFields() {
-		b.BPrintf("%s\n", field.String())
+		fields = append(fields, generator.Param{Name: field.Name, Type: field.Kind.String()})
+	}
+	for _, n := range nested {
+		fields = append(fields, generator.Param{Name: n.name, Type: "*" + n.builderName()})
 	}
-	b.BPrintf("}\n")
+	b.Emit(&generator.StructDecl{Name: builderName, Fields: fields})
 
-	args := &generator.Scribler{}
-	props := &generator.Scribler{}
+	scope := generator.NewScope()
+	params := []generator.Param{}
+	elems := []generator.KeyValue{}
 	for _, field := range mapper.GetFields() {
 		if field.HasTag(RequiredTag) {
-			name := generator.UncapFirst(field.NameOrKindName())
-			args.BPrintf("%s %s,", name, field.Kind.String())
-			props.BPrintf("%s: %s,\n", name, name)
+			name := scope.Name(generator.UncapFirst(field.NameOrKindName()))
+			params = append(params, generator.Param{Name: name, Type: field.Kind.String()})
+			elems = append(elems, generator.KeyValue{Key: field.NameForField(), Value: generator.Id(name)})
+		} else if expr, ok := defaultOf(field); ok {
+			elems = append(elems, generator.KeyValue{Key: field.NameForField(), Value: expr})
 		}
 	}
-	b.BPrintf("\nfunc New%sBuilder(%s) *%sBuilder {\n return &%sBuilder{\n%s} \n}\n", structName, args, structName, structName, props)
+	b.Emit(&generator.Func{
+		Name:    "New" + builderName,
+		Params:  params,
+		Results: []generator.Param{{Type: "*" + builderName}},
+		Body:    []generator.Stmt{generator.Return(generator.Unary(token.AND, generator.Lit(builderName, elems...)))},
+	})
 }
 
-func (b *Builder) genBuilderSetters(mapper generator.Mapper) {
+// genBuilderSetters generates the setters and the helpers of the builder.
+// A helper whose name is already taken, by a setter, Build or a previous helper, is not generated,
+// eg: AddItem is generated for items []int and not for item []int.
+func (b *Builder) genBuilderSetters(mapper generator.Mapper, nested []nestedBuilder) {
+	recv := "*" + mapper.GetName() + "Builder"
+	methods := generator.NewScope("Build")
 	for _, field := range mapper.GetFields() {
-		builderFieldName := field.NameForField()
-		fieldName := field.NameOrKindName()
-		method := strings.Title(fieldName)
-		if field.Name == "" {
-			method = "With" + method
+		methods.Reserve(setterMethod(field))
+	}
+	for _, field := range mapper.GetFields() {
+		setter := setterFunc(field, recv, recv)
+		n, isNested := findNested(nested, field)
+		if isNested {
+			// the value set replaces the one being built
+			setter.Body = append([]generator.Stmt{generator.Assign(generator.Sel(builderRecv, n.name), generator.Id("nil"))}, setter.Body...)
+		}
+		b.Emit(setter)
+
+		switch kind := field.Kind.(type) {
+		case generator.Array:
+			b.genAdders(methods, field, kind, recv)
+		case generator.Map:
+			b.genPut(methods, field, kind, recv)
+		}
+		if isNested {
+			b.genNestedWith(methods, n, recv)
 		}
-		argName := generator.UncapFirst(fieldName)
-		b.BPrintf("\nfunc (b *%sBuilder) %s(%s %s) *%sBuilder {\n", mapper.GetName(), method, argName, field.Kind.String(), mapper.GetName())
-		b.BPrintf("	b.%s = %s\n", builderFieldName, argName)
-		b.BPrintf("  return b\n")
-		b.BPrintf("}\n")
 	}
 }
 
-func (b *Builder) genBuild(mapper generator.Mapper) {
-	s := &generator.Scribler{}
-	hasError := PrintZeroCheck(s, mapper, "b")
+// emitHelper emits the helper method, unless its name is already in use by another method of the builder
+func (b *Builder) emitHelper(methods *generator.Scope, helper *generator.Func) {
+	if methods.InUse(helper.Name) {
+		return
+	}
+	methods.Reserve(helper.Name)
+	b.Emit(helper)
+}
 
-	structName := mapper.GetName()
-	s.BPrintf("s := %s{\n", structName)
+// genAdders generates the methods that append to the slice field, eg: AddThing(thing int) and AddThings(things ...int) for things []int
+func (b *Builder) genAdders(methods *generator.Scope, field generator.Field, kind generator.Array, recv string) {
+	name := field.NameOrKindName()
+	target := generator.Sel(builderRecv, field.NameForField())
+	scope := generator.NewScope("b")
+	if singular := singularOf(name); singular != name {
+		arg := scope.Name(generator.UncapFirst(singular))
+		b.emitHelper(methods, &generator.Func{
+			Recv:    &generator.Param{Name: "b", Type: recv},
+			Name:    "Add" + strings.Title(singular),
+			Params:  []generator.Param{{Name: arg, Type: kind.Kinder.String()}},
+			Results: []generator.Param{{Type: recv}},
+			Body: []generator.Stmt{
+				generator.Assign(target, generator.Call(generator.Id("append"), target, generator.Id(arg))),
+				generator.Return(builderRecv),
+			},
+		})
+	}
+	arg := scope.Name(generator.UncapFirst(name))
+	b.emitHelper(methods, &generator.Func{
+		Recv:    &generator.Param{Name: "b", Type: recv},
+		Name:    "Add" + strings.Title(name),
+		Params:  []generator.Param{{Name: arg, Type: "..." + kind.Kinder.String()}},
+		Results: []generator.Param{{Type: recv}},
+		Body: []generator.Stmt{
+			generator.Assign(target, generator.CallSpread(generator.Id("append"), target, generator.Id(arg))),
+			generator.Return(builderRecv),
+		},
+	})
+}
+
+// genPut generates the method that puts an entry in the map field, eg: PutTag(key string, value int) for tags map[string]int
+func (b *Builder) genPut(methods *generator.Scope, field generator.Field, kind generator.Map, recv string) {
+	target := generator.Sel(builderRecv, field.NameForField())
+	scope := generator.NewScope("b")
+	key := scope.Name("key")
+	value := scope.Name("value")
+	b.emitHelper(methods, &generator.Func{
+		Recv:    &generator.Param{Name: "b", Type: recv},
+		Name:    "Put" + strings.Title(singularOf(field.NameOrKindName())),
+		Params:  []generator.Param{{Name: key, Type: kind.Key.String()}, {Name: value, Type: kind.Val.String()}},
+		Results: []generator.Param{{Type: recv}},
+		Body: []generator.Stmt{
+			generator.If(generator.Binary(target, token.EQL, generator.Id("nil")), generator.Assign(target, generator.Lit(kind.String()))),
+			generator.Assign(generator.Index(target, generator.Id(key)), generator.Id(value)),
+			generator.Return(builderRecv),
+		},
+	})
+}
+
+// genNestedWith generates the method that builds the field with its own builder, eg: OtherWith(fn func(*Dto2Builder)),
+// starting from the value already set, if any. The field is built when the outer struct is built.
+func (b *Builder) genNestedWith(methods *generator.Scope, n nestedBuilder, recv string) {
+	target := generator.Sel(builderRecv, n.field.NameForField())
+	nestedTarget := generator.Sel(builderRecv, n.name)
+	toBuild := generator.Call(generator.Sel(target, "ToBuild"))
+	start := []generator.Stmt{generator.Assign(nestedTarget, generator.Unary(token.AND, generator.Lit(n.builderName())))}
+	if _, ok := n.field.Kind.(generator.Pointer); ok {
+		start = append(start, generator.If(generator.Binary(target, token.NEQ, generator.Id("nil")), generator.Assign(nestedTarget, toBuild)))
+	} else {
+		start = []generator.Stmt{generator.Assign(nestedTarget, toBuild)}
+	}
+	b.emitHelper(methods, &generator.Func{
+		Recv:    &generator.Param{Name: "b", Type: recv},
+		Name:    strings.Title(n.field.NameOrKindName()) + "With",
+		Params:  []generator.Param{{Name: "fn", Type: "func(*" + n.builderName() + ")"}},
+		Results: []generator.Param{{Type: recv}},
+		Body: []generator.Stmt{
+			generator.If(generator.Binary(nestedTarget, token.EQL, generator.Id("nil")), start...),
+			generator.Do(generator.Call(generator.Id("fn"), nestedTarget)),
+			generator.Return(builderRecv),
+		},
+	})
+}
+
+// nestedBuilder is a field whose type, or the type it points to, is a struct of the same file with a gog builder
+type nestedBuilder struct {
+	field generator.Field
+	// name is the field of the builder holding the builder of the field
+	name string
+}
+
+func (n nestedBuilder) builderName() string {
+	return n.field.Mapper.GetName() + "Builder"
+}
+
+// nestedBuilders returns the fields of the struct that can be built with their own builder
+func nestedBuilders(mapper generator.Mapper) []nestedBuilder {
+	scope := generator.NewScope()
+	for _, field := range mapper.GetFields() {
+		scope.Reserve(field.NameForField())
+	}
+	var nested []nestedBuilder
 	for _, field := range mapper.GetFields() {
-		fieldName := field.NameOrKindName()
-		s.BPrintf("	%s: b.%s,\n", fieldName, field.NameForField())
+		m := field.Mapper
+		if m != nil && m.Type() == generator.StructMapper && m.GetTags().HasTag(builderPlugin) {
+			nested = append(nested, nestedBuilder{
+				field: field,
+				name:  scope.Name(generator.UncapFirst(field.NameOrKindName()) + "Builder"),
+			})
+		}
+	}
+	return nested
+}
+
+func findNested(nested []nestedBuilder, field generator.Field) (nestedBuilder, bool) {
+	for _, n := range nested {
+		if n.field.Name == field.Name && n.field.Kind.String() == field.Kind.String() {
+			return n, true
+		}
+	}
+	return nestedBuilder{}, false
+}
+
+// singularOf returns the singular of the plural name, or the name if it is not a plural, eg: entries -> entry, boxes -> box
+func singularOf(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "sses"), strings.HasSuffix(name, "xes"),
+		strings.HasSuffix(name, "ches"), strings.HasSuffix(name, "shes"):
+		return strings.TrimSuffix(name, "es")
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+		return strings.TrimSuffix(name, "s")
+	}
+	return name
+}
+
+// setterMethod is the name of the builder method that sets the field, eg: Name, or WithBar for the embedded Bar
+func setterMethod(field generator.Field) string {
+	method := strings.Title(field.NameOrKindName())
+	if field.Name == "" {
+		method = "With" + method
 	}
-	s.BPrintf("  }\n\n")
+	return method
+}
 
-	hasError = PrintValidate(s, mapper, "s") || hasError
-	retCode := structName
+// setterFunc is the builder method, with the recv type, that sets the field and returns the builder as the result type
+func setterFunc(field generator.Field, recv, result string) *generator.Func {
+	scope := generator.NewScope("b")
+	arg := scope.Name(generator.UncapFirst(field.NameOrKindName()))
+	return &generator.Func{
+		Recv:    &generator.Param{Name: "b", Type: recv},
+		Name:    setterMethod(field),
+		Params:  []generator.Param{{Name: arg, Type: field.Kind.String()}},
+		Results: []generator.Param{{Type: result}},
+		Body: []generator.Stmt{
+			generator.Assign(generator.Sel(builderRecv, field.NameForField()), generator.Id(arg)),
+			generator.Return(builderRecv),
+		},
+	}
+}
+
+// setterOf returns the unexported setter of the field, eg: setValue(value int64) error, if the struct declares it
+func setterOf(mapper generator.Mapper, field generator.Field) (generator.Method, bool) {
+	return mapper.FindMethod("set" + strings.Title(field.NameOrKindName()))
+}
+
+// checkBuilder checks the methods and the tags of the struct that the generated builder uses
+func checkBuilder(mapper generator.Mapper) []generator.Issue {
+	issues := checkConstructor(mapper)
+	for _, field := range mapper.GetFields() {
+		m, ok := setterOf(mapper, field)
+		if !ok {
+			continue
+		}
+		if len(m.Args) == 1 && m.Args[0].Kind.String() == field.Kind.String() &&
+			(len(m.Results) == 0 || (len(m.Results) == 1 && m.Results[0].IsError())) {
+			continue
+		}
+		issues = append(issues, generator.Issue{
+			Pos: m.Pos,
+			Message: fmt.Sprintf("%s.%s must have the signature %s(%s) or %s(%s) error",
+				mapper.GetName(), m.Name(), m.Name(), field.Kind, m.Name(), field.Kind),
+		})
+	}
+	return issues
+}
+
+// buildReturnsError returns true if the Build method of the builder of the struct returns an error
+func buildReturnsError(mapper generator.Mapper, nested []nestedBuilder) bool {
+	return buildErrors(mapper, nested, map[string]bool{})
+}
+
+func buildErrors(mapper generator.Mapper, nested []nestedBuilder, seen map[string]bool) bool {
+	if seen[mapper.GetName()] {
+		return false
+	}
+	seen[mapper.GetName()] = true
+	if _, ok := mapper.FindMethod(ValidateMethodName); ok {
+		return true
+	}
+	for _, field := range mapper.GetFields() {
+		if field.HasTag(RequiredTag) {
+			return true
+		}
+		if m, ok := setterOf(mapper, field); ok && len(m.Results) > 0 {
+			return true
+		}
+	}
+	for _, n := range nested {
+		if buildErrors(n.field.Mapper, nestedBuilders(n.field.Mapper), seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildFunc is the Build method of the builder with the recv type, that checks the required fields and validates the struct.
+// The nested fields being built by their own builder are built first,
+// and the fields with an unexported setter are set by calling it, returning its error, if any.
+// The maps are copied and the slices are clipped, so that changing the builder after Build does not change the struct.
+func buildFunc(c *generator.Code, mapper generator.Mapper, recv string, nested []nestedBuilder) *generator.Func {
+	structName := mapper.GetName()
+	hasError := buildReturnsError(mapper, nested)
+	const errs = "errs"
+	scope := generator.NewScope("b", "s", "err", "k", "v", errs)
+
+	var body []generator.Stmt
 	if hasError {
-		retCode = "(" + retCode + ", error)"
+		body = append(body, newErrs(c, mapper, errs))
+	}
+	for _, n := range nested {
+		v := scope.Name(generator.UncapFirst(n.field.NameOrKindName()))
+		call := generator.Call(generator.Sel(builderRecv, n.name, "Build"))
+		target := generator.Sel(builderRecv, n.field.NameForField())
+		_, isPointer := n.field.Kind.(generator.Pointer)
+		returnsError := buildReturnsError(n.field.Mapper, nestedBuilders(n.field.Mapper))
+		var stmts []generator.Stmt
+		switch {
+		case returnsError:
+			stmts = append(stmts, generator.Define(v+", err", call), generator.Do(generator.Call(generator.Sel(generator.Id(errs), "Add"), generator.Id("err"))))
+		case isPointer:
+			stmts = append(stmts, generator.Define(v, call))
+		default:
+			stmts = append(stmts, generator.Assign(target, call))
+		}
+		if isPointer {
+			stmts = append(stmts, generator.Assign(target, generator.Unary(token.AND, generator.Id(v))))
+		} else if returnsError {
+			stmts = append(stmts, generator.Assign(target, generator.Id(v)))
+		}
+		body = append(body, generator.If(generator.Binary(generator.Sel(builderRecv, n.name), token.NEQ, generator.Id("nil")), stmts...))
+	}
+	body = append(body, zeroChecks(mapper, errs, func(field generator.Field) generator.Expr {
+		return generator.Sel(builderRecv, field.NameForField())
+	})...)
+
+	elems := []generator.KeyValue{}
+	var setters []generator.Stmt
+	var copies []generator.Stmt
+	for _, field := range mapper.GetFields() {
+		stmts, value := detached(scope, field, generator.Sel(builderRecv, field.NameForField()))
+		copies = append(copies, stmts...)
+		m, ok := setterOf(mapper, field)
+		if !ok {
+			elems = append(elems, generator.KeyValue{Key: field.NameOrKindName(), Value: value})
+			continue
+		}
+		call := generator.Call(generator.Sel(generator.Id("s"), m.Name()), value)
+		if len(m.Results) == 0 {
+			setters = append(setters, generator.Do(call))
+			continue
+		}
+		setters = append(setters, generator.Do(generator.Call(generator.Sel(generator.Id(errs), "Add"), call)))
+	}
+	body = append(body, copies...)
+	body = append(body, generator.Define("s", generator.Lit(structName, elems...)), generator.Blank())
+	if len(setters) > 0 {
+		body = append(body, setters...)
+		body = append(body, generator.Blank())
 	}
-	b.BPrintf("\n\nfunc (b *%sBuilder) Build() %s {\n", structName, retCode)
-	b.BPrintf("%s\n", s)
-	b.BPrintf("return s")
+	if validate, ok := validateCall(mapper, "s", errs); ok {
+		body = append(body, validate)
+	}
+
+	results := []generator.Param{{Type: structName}}
 	if hasError {
-		b.BPrintf(", nil")
+		results = append(results, generator.Param{Type: "error"})
+		body = append(body, returnErrs(mapper, errs), generator.Blank(), generator.Return(generator.Id("s"), generator.Id("nil")))
+	} else {
+		body = append(body, generator.Return(generator.Id("s")))
+	}
+	return &generator.Func{
+		Recv:    &generator.Param{Name: "b", Type: recv},
+		Name:    "Build",
+		Results: results,
+		Body:    body,
 	}
-	b.BPrintf("\n}\n")
 }
 
-func (b *Builder) genToBuild(mapper generator.Mapper) {
+// toBuildFunc is the ToBuild method of the struct, returning a builder of the builderName struct, as the result type, with the values of the struct
+// The maps are copied and the slices are clipped, so that changing the builder does not change the struct.
+func toBuildFunc(mapper generator.Mapper, builderName, result string) *generator.Func {
 	structName := mapper.GetName()
-	b.BPrintf("\n\nfunc (b *%s) ToBuild() *%sBuilder {", structName, structName)
-	b.BPrintf("\nreturn &%sBuilder{\n", structName)
+	scope := generator.NewScope("b", "k", "v")
+	var body []generator.Stmt
+	elems := []generator.KeyValue{}
 	for _, field := range mapper.GetFields() {
-		fieldName := field.NameOrKindName()
-		b.BPrintf("%s: b.%s,\n", field.NameForField(), fieldName)
+		stmts, value := detached(scope, field, generator.Sel(builderRecv, field.NameOrKindName()))
+		body = append(body, stmts...)
+		elems = append(elems, generator.KeyValue{Key: field.NameForField(), Value: value})
+	}
+	body = append(body, generator.Return(generator.Unary(token.AND, generator.Lit(builderName, elems...))))
+	return &generator.Func{
+		Recv:    &generator.Param{Name: "b", Type: "*" + structName},
+		Name:    "ToBuild",
+		Results: []generator.Param{{Type: result}},
+		Body:    body,
+	}
+}
+
+// detached returns the value of the field, with the map copied or the slice clipped, so that appending to or putting in it
+// does not change the value it was taken from. The statements copy the map into a variable named from the field,
+// using the names k and v, that must be reserved in the scope.
+func detached(scope *generator.Scope, field generator.Field, value generator.Expr) ([]generator.Stmt, generator.Expr) {
+	switch kind := field.Kind.(type) {
+	case generator.Array:
+		length := generator.Call(generator.Id("len"), value)
+		return nil, generator.Slice(value, generator.Expr{}, length, length)
+	case generator.Map:
+		m := generator.Id(scope.Name(generator.UncapFirst(field.NameOrKindName())))
+		return []generator.Stmt{
+			generator.Var(m.String(), kind.String()),
+			generator.If(generator.Binary(value, token.NEQ, generator.Id("nil")),
+				generator.Assign(m, generator.Call(generator.Id("make"), generator.Raw(kind.String()), generator.Call(generator.Id("len"), value))),
+				generator.Range("k", "v", value, generator.Assign(generator.Index(m, generator.Id("k")), generator.Id("v"))),
+			),
+		}, m
 	}
-	b.BPrintf("}\n}\n")
+	return nil, value
 }
 
-func (b *Builder) genGetters(mapper generator.Mapper) error {
+func (b *Builder) genGetters(mapper generator.Mapper, options BuilderOptions) error {
 	getters := Getters{}
-	err := getters.WriteBody(mapper, GetterOptions{})
+	err := getters.WriteBody(mapper, GetterOptions{Pointer: options.Pointer})
 	if err != nil {
 		return fmt.Errorf("writing Builder body: %w", err)
 	}
-	b.BPrintf("\n")
-	b.Body.Write(getters.Body.Bytes())
+	b.Append(&getters.Code)
 	return nil
 }
@@ -2,9 +2,13 @@ package plugins
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 
 	"github.com/quintans/gog/config"
+	"github.com/quintans/gog/gogtest"
 )
 
 func TestBuilder(t *testing.T) {
@@ -34,21 +38,23 @@ type Foo struct {
 }
 
 func (f Foo) validate() error {
-	if timeout < 0 {
+	if f.timeout < 0 {
 		return errors.New("timeout must be > 0")
 	}
 	return nil
 }
 
+type Bar struct{}
 `,
 			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
 // Version: %s
 package p
 
 import (
-	"errors"
 	"fmt"
 	"time"
+
+	"github.com/quintans/gog/validation"
 )
 
 // Generated by gog:builder
@@ -88,11 +94,12 @@ func (b *FooBuilder) Timeout(timeout time.Duration) *FooBuilder {
 }
 
 func (b *FooBuilder) Build() (Foo, error) {
+	errs := validation.New("Foo")
 	if b.name == "" {
-		return Foo{}, errors.New("Foo.name cannot be empty")
+		errs.Require("name")
 	}
 	if (b.when == time.Time{}) {
-		return Foo{}, errors.New("Foo.when cannot be empty")
+		errs.Require("when")
 	}
 	s := Foo{
 		Bar:     b.Bar,
@@ -101,7 +108,10 @@ func (b *FooBuilder) Build() (Foo, error) {
 		timeout: b.timeout,
 	}
 
-	if err := s.validate(); err != nil {
+	if !errs.HasViolations() {
+		errs.Add(s.validate())
+	}
+	if err := errs.Err(); err != nil {
 		return Foo{}, err
 	}
 
@@ -140,6 +150,674 @@ func (f Foo) IsZero() bool {
 func (f Foo) String() string {
 	return fmt.Sprintf("Foo{Bar: %%+v, name: %%+v, when: %%+v, timeout: %%+v}", f.Bar, f.name, f.when, f.timeout)
 }
+`, config.Version),
+		},
+		{
+			"Setters",
+			`
+package p
+
+import "errors"
+
+// gog:builder
+type Foo struct {
+	// gog:@required
+	name  string
+	value int64
+	note  string
+}
+
+func (f *Foo) setValue(value int64) error {
+	if value < 0 {
+		return errors.New("value must be >= 0")
+	}
+	f.value = value
+	return nil
+}
+
+func (f *Foo) setNote(note string) {
+	f.note = note
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+import (
+	"fmt"
+
+	"github.com/quintans/gog/validation"
+)
+
+// Generated by gog:builder
+
+type FooBuilder struct {
+	name  string
+	value int64
+	note  string
+}
+
+func NewFooBuilder(name string) *FooBuilder {
+	return &FooBuilder{
+		name: name,
+	}
+}
+
+func (b *FooBuilder) Name(name string) *FooBuilder {
+	b.name = name
+	return b
+}
+
+func (b *FooBuilder) Value(value int64) *FooBuilder {
+	b.value = value
+	return b
+}
+
+func (b *FooBuilder) Note(note string) *FooBuilder {
+	b.note = note
+	return b
+}
+
+func (b *FooBuilder) Build() (Foo, error) {
+	errs := validation.New("Foo")
+	if b.name == "" {
+		errs.Require("name")
+	}
+	s := Foo{
+		name: b.name,
+	}
+
+	errs.Add(s.setValue(b.value))
+	s.setNote(b.note)
+
+	if err := errs.Err(); err != nil {
+		return Foo{}, err
+	}
+
+	return s, nil
+}
+
+func (b *Foo) ToBuild() *FooBuilder {
+	return &FooBuilder{
+		name:  b.name,
+		value: b.value,
+		note:  b.note,
+	}
+}
+
+func (f Foo) Name() string {
+	return f.name
+}
+
+func (f Foo) Value() int64 {
+	return f.value
+}
+
+func (f Foo) Note() string {
+	return f.note
+}
+
+func (f Foo) IsZero() bool {
+	return f == Foo{}
+}
+
+func (f Foo) String() string {
+	return fmt.Sprintf("Foo{name: %%+v, value: %%+v, note: %%+v}", f.name, f.value, f.note)
+}
+`, config.Version),
+		},
+		{
+			"Collections",
+			`
+package p
+
+// gog:builder
+type Foo struct {
+	things  []int
+	entries []string
+	data    []byte
+	tags    map[string]int
+	bar     *Bar
+	baz     Baz
+}
+
+// gog:builder
+type Bar struct {
+	// gog:@required
+	name string
+}
+
+// gog:builder
+type Baz struct {
+	size int
+}
+
+func use() {
+	_, _ = NewFooBuilder().AddThing(1).AddThings(2, 3).AddEntry("a").AddData(1, 2).PutTag("a", 1).
+		BarWith(func(b *BarBuilder) { b.Name("x") }).
+		BazWith(func(b *BazBuilder) { b.Size(2) }).Build()
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+import (
+	"fmt"
+
+	"github.com/quintans/gog/validation"
+)
+
+// Generated by gog:builder
+
+type FooBuilder struct {
+	things     []int
+	entries    []string
+	data       []byte
+	tags       map[string]int
+	bar        *Bar
+	baz        Baz
+	barBuilder *BarBuilder
+	bazBuilder *BazBuilder
+}
+
+func NewFooBuilder() *FooBuilder {
+	return &FooBuilder{}
+}
+
+func (b *FooBuilder) Things(things []int) *FooBuilder {
+	b.things = things
+	return b
+}
+
+func (b *FooBuilder) AddThing(thing int) *FooBuilder {
+	b.things = append(b.things, thing)
+	return b
+}
+
+func (b *FooBuilder) AddThings(things ...int) *FooBuilder {
+	b.things = append(b.things, things...)
+	return b
+}
+
+func (b *FooBuilder) Entries(entries []string) *FooBuilder {
+	b.entries = entries
+	return b
+}
+
+func (b *FooBuilder) AddEntry(entry string) *FooBuilder {
+	b.entries = append(b.entries, entry)
+	return b
+}
+
+func (b *FooBuilder) AddEntries(entries ...string) *FooBuilder {
+	b.entries = append(b.entries, entries...)
+	return b
+}
+
+func (b *FooBuilder) Data(data []byte) *FooBuilder {
+	b.data = data
+	return b
+}
+
+func (b *FooBuilder) AddData(data ...byte) *FooBuilder {
+	b.data = append(b.data, data...)
+	return b
+}
+
+func (b *FooBuilder) Tags(tags map[string]int) *FooBuilder {
+	b.tags = tags
+	return b
+}
+
+func (b *FooBuilder) PutTag(key string, value int) *FooBuilder {
+	if b.tags == nil {
+		b.tags = map[string]int{}
+	}
+	b.tags[key] = value
+	return b
+}
+
+func (b *FooBuilder) Bar(bar *Bar) *FooBuilder {
+	b.barBuilder = nil
+	b.bar = bar
+	return b
+}
+
+func (b *FooBuilder) BarWith(fn func(*BarBuilder)) *FooBuilder {
+	if b.barBuilder == nil {
+		b.barBuilder = &BarBuilder{}
+		if b.bar != nil {
+			b.barBuilder = b.bar.ToBuild()
+		}
+	}
+	fn(b.barBuilder)
+	return b
+}
+
+func (b *FooBuilder) Baz(baz Baz) *FooBuilder {
+	b.bazBuilder = nil
+	b.baz = baz
+	return b
+}
+
+func (b *FooBuilder) BazWith(fn func(*BazBuilder)) *FooBuilder {
+	if b.bazBuilder == nil {
+		b.bazBuilder = b.baz.ToBuild()
+	}
+	fn(b.bazBuilder)
+	return b
+}
+
+func (b *FooBuilder) Build() (Foo, error) {
+	errs := validation.New("Foo")
+	if b.barBuilder != nil {
+		bar, err := b.barBuilder.Build()
+		errs.Add(err)
+		b.bar = &bar
+	}
+	if b.bazBuilder != nil {
+		b.baz = b.bazBuilder.Build()
+	}
+	var tags map[string]int
+	if b.tags != nil {
+		tags = make(map[string]int, len(b.tags))
+		for k, v := range b.tags {
+			tags[k] = v
+		}
+	}
+	s := Foo{
+		things:  b.things[:len(b.things):len(b.things)],
+		entries: b.entries[:len(b.entries):len(b.entries)],
+		data:    b.data[:len(b.data):len(b.data)],
+		tags:    tags,
+		bar:     b.bar,
+		baz:     b.baz,
+	}
+
+	if err := errs.Err(); err != nil {
+		return Foo{}, err
+	}
+
+	return s, nil
+}
+
+func (b *Foo) ToBuild() *FooBuilder {
+	var tags map[string]int
+	if b.tags != nil {
+		tags = make(map[string]int, len(b.tags))
+		for k, v := range b.tags {
+			tags[k] = v
+		}
+	}
+	return &FooBuilder{
+		things:  b.things[:len(b.things):len(b.things)],
+		entries: b.entries[:len(b.entries):len(b.entries)],
+		data:    b.data[:len(b.data):len(b.data)],
+		tags:    tags,
+		bar:     b.bar,
+		baz:     b.baz,
+	}
+}
+
+func (f Foo) Things() []int {
+	return f.things
+}
+
+func (f Foo) Entries() []string {
+	return f.entries
+}
+
+func (f Foo) Data() []byte {
+	return f.data
+}
+
+func (f Foo) Tags() map[string]int {
+	return f.tags
+}
+
+func (f Foo) Bar() *Bar {
+	return f.bar
+}
+
+func (f Foo) Baz() Baz {
+	return f.baz
+}
+
+func (f Foo) IsZero() bool {
+	return len(f.things) == 0 ||
+		len(f.entries) == 0 ||
+		len(f.data) == 0 ||
+		len(f.tags) == 0 ||
+		f.bar == nil ||
+		(f.baz == Baz{})
+}
+
+func (f Foo) String() string {
+	return fmt.Sprintf("Foo{things: %%+v, entries: %%+v, data: %%+v, tags: %%+v, bar: %%+v, baz: %%+v}", f.things, f.entries, f.data, f.tags, f.bar, f.baz)
+}
+
+// Generated by gog:builder
+
+type BarBuilder struct {
+	name string
+}
+
+func NewBarBuilder(name string) *BarBuilder {
+	return &BarBuilder{
+		name: name,
+	}
+}
+
+func (b *BarBuilder) Name(name string) *BarBuilder {
+	b.name = name
+	return b
+}
+
+func (b *BarBuilder) Build() (Bar, error) {
+	errs := validation.New("Bar")
+	if b.name == "" {
+		errs.Require("name")
+	}
+	s := Bar{
+		name: b.name,
+	}
+
+	if err := errs.Err(); err != nil {
+		return Bar{}, err
+	}
+
+	return s, nil
+}
+
+func (b *Bar) ToBuild() *BarBuilder {
+	return &BarBuilder{
+		name: b.name,
+	}
+}
+
+func (b Bar) Name() string {
+	return b.name
+}
+
+func (b Bar) IsZero() bool {
+	return b == Bar{}
+}
+
+func (b Bar) String() string {
+	return fmt.Sprintf("Bar{name: %%+v}", b.name)
+}
+
+// Generated by gog:builder
+
+type BazBuilder struct {
+	size int
+}
+
+func NewBazBuilder() *BazBuilder {
+	return &BazBuilder{}
+}
+
+func (b *BazBuilder) Size(size int) *BazBuilder {
+	b.size = size
+	return b
+}
+
+func (b *BazBuilder) Build() Baz {
+	s := Baz{
+		size: b.size,
+	}
+
+	return s
+}
+
+func (b *Baz) ToBuild() *BazBuilder {
+	return &BazBuilder{
+		size: b.size,
+	}
+}
+
+func (b Baz) Size() int {
+	return b.size
+}
+
+func (b Baz) IsZero() bool {
+	return b == Baz{}
+}
+
+func (b Baz) String() string {
+	return fmt.Sprintf("Baz{size: %%+v}", b.size)
+}
+`, config.Version),
+		},
+		{
+			"Defaults",
+			`
+package p
+
+import "time"
+
+// gog:builder
+type Foo struct {
+	// gog:@required
+	name string
+	// gog:@default 3
+	retries int
+	// gog:@default {"value": "time.Second*30"}
+	timeout time.Duration
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quintans/gog/validation"
+)
+
+// Generated by gog:builder
+
+type FooBuilder struct {
+	name    string
+	retries int
+	timeout time.Duration
+}
+
+func NewFooBuilder(name string) *FooBuilder {
+	return &FooBuilder{
+		name:    name,
+		retries: 3,
+		timeout: time.Second * 30,
+	}
+}
+
+func (b *FooBuilder) Name(name string) *FooBuilder {
+	b.name = name
+	return b
+}
+
+func (b *FooBuilder) Retries(retries int) *FooBuilder {
+	b.retries = retries
+	return b
+}
+
+func (b *FooBuilder) Timeout(timeout time.Duration) *FooBuilder {
+	b.timeout = timeout
+	return b
+}
+
+func (b *FooBuilder) Build() (Foo, error) {
+	errs := validation.New("Foo")
+	if b.name == "" {
+		errs.Require("name")
+	}
+	s := Foo{
+		name:    b.name,
+		retries: b.retries,
+		timeout: b.timeout,
+	}
+
+	if err := errs.Err(); err != nil {
+		return Foo{}, err
+	}
+
+	return s, nil
+}
+
+func (b *Foo) ToBuild() *FooBuilder {
+	return &FooBuilder{
+		name:    b.name,
+		retries: b.retries,
+		timeout: b.timeout,
+	}
+}
+
+func (f Foo) Name() string {
+	return f.name
+}
+
+func (f Foo) Retries() int {
+	return f.retries
+}
+
+func (f Foo) Timeout() time.Duration {
+	return f.timeout
+}
+
+func (f Foo) IsZero() bool {
+	return f == Foo{}
+}
+
+func (f Foo) String() string {
+	return fmt.Sprintf("Foo{name: %%+v, retries: %%+v, timeout: %%+v}", f.name, f.retries, f.timeout)
+}
+`, config.Version),
+		},
+		{
+			"Helper_name_collisions",
+			`
+package p
+
+// gog:builder
+type Foo struct {
+	items  []int
+	item   []int
+	tags   map[string]int
+	putTag string
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+import "fmt"
+
+// Generated by gog:builder
+
+type FooBuilder struct {
+	items  []int
+	item   []int
+	tags   map[string]int
+	putTag string
+}
+
+func NewFooBuilder() *FooBuilder {
+	return &FooBuilder{}
+}
+
+func (b *FooBuilder) Items(items []int) *FooBuilder {
+	b.items = items
+	return b
+}
+
+func (b *FooBuilder) AddItem(item int) *FooBuilder {
+	b.items = append(b.items, item)
+	return b
+}
+
+func (b *FooBuilder) AddItems(items ...int) *FooBuilder {
+	b.items = append(b.items, items...)
+	return b
+}
+
+func (b *FooBuilder) Item(item []int) *FooBuilder {
+	b.item = item
+	return b
+}
+
+func (b *FooBuilder) Tags(tags map[string]int) *FooBuilder {
+	b.tags = tags
+	return b
+}
+
+func (b *FooBuilder) PutTag(putTag string) *FooBuilder {
+	b.putTag = putTag
+	return b
+}
+
+func (b *FooBuilder) Build() Foo {
+	var tags map[string]int
+	if b.tags != nil {
+		tags = make(map[string]int, len(b.tags))
+		for k, v := range b.tags {
+			tags[k] = v
+		}
+	}
+	s := Foo{
+		items:  b.items[:len(b.items):len(b.items)],
+		item:   b.item[:len(b.item):len(b.item)],
+		tags:   tags,
+		putTag: b.putTag,
+	}
+
+	return s
+}
+
+func (b *Foo) ToBuild() *FooBuilder {
+	var tags map[string]int
+	if b.tags != nil {
+		tags = make(map[string]int, len(b.tags))
+		for k, v := range b.tags {
+			tags[k] = v
+		}
+	}
+	return &FooBuilder{
+		items:  b.items[:len(b.items):len(b.items)],
+		item:   b.item[:len(b.item):len(b.item)],
+		tags:   tags,
+		putTag: b.putTag,
+	}
+}
+
+func (f Foo) Items() []int {
+	return f.items
+}
+
+func (f Foo) Item() []int {
+	return f.item
+}
+
+func (f Foo) Tags() map[string]int {
+	return f.tags
+}
+
+func (f Foo) PutTag() string {
+	return f.putTag
+}
+
+func (f Foo) IsZero() bool {
+	return len(f.items) == 0 ||
+		len(f.item) == 0 ||
+		len(f.tags) == 0 ||
+		f.putTag == ""
+}
+
+func (f Foo) String() string {
+	return fmt.Sprintf("Foo{items: %%+v, item: %%+v, tags: %%+v, putTag: %%+v}", f.items, f.item, f.tags, f.putTag)
+}
 `, config.Version),
 		},
 	}
@@ -150,3 +828,64 @@ func (f Foo) String() string {
 		})
 	}
 }
+
+func TestBuilderInvalidSetter(t *testing.T) {
+	runErr(t, `
+package p
+
+// gog:builder
+type Foo struct {
+	value int64
+}
+
+func (f *Foo) setValue(value string) {}
+`, `src.go:9:15: builder: Foo.setValue must have the signature setValue(int64) or setValue(int64) error`)
+}
+
+func TestBuilderDetached(t *testing.T) {
+	src := `
+package main
+
+// gog:builder
+type Foo struct {
+	things []int
+	attrs  map[string]int
+}
+`
+	// the helpers called after Build, or on the builder of ToBuild, do not change the built struct
+	main := `package main
+
+import "fmt"
+
+func main() {
+	b := NewFooBuilder().AddThings(1).PutAttr("a", 1)
+	b.things = append(make([]int, 0, 10), b.things...)
+	foo := b.Build()
+	b.AddThing(2).PutAttr("b", 2)
+	foo.ToBuild().AddThing(3).PutAttr("c", 3)
+	fmt.Print(foo.Things(), cap(foo.Things()), foo.Attrs())
+}
+`
+	dir := t.TempDir()
+	files := map[string]string{
+		"go.mod":     "module p\n",
+		"src.go":     src,
+		"src_gen.go": gogtest.Generate(t, src),
+		"main.go":    main,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("running the generated code: %v\n%s", err, out)
+	}
+	if want := "[1] 1 map[a:1]"; string(out) != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+}
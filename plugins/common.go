@@ -1,50 +1,111 @@
 package plugins
 
-import "github.com/quintans/gog/generator"
+import (
+	"fmt"
+	"go/token"
+	"strings"
+
+	"github.com/quintans/gog/generator"
+)
 
 const (
 	ValidateMethodName = "validate"
 	RequiredTag        = "@required"
 	IgnoreTag          = "@ignore"
 	WitherTag          = "@wither"
+
+	// validationPath is the package of the error returned by the generated constructors and builders.
+	// It is imported by the generated code, so the module of the generated code must require it.
+	validationPath = "github.com/quintans/gog/validation"
 )
 
-func PrintValidate(s *generator.Scribler, mapper generator.Mapper, receiver string) bool {
-	_, ok := mapper.FindMethod(ValidateMethodName)
-	if ok {
-		structName := mapper.GetName()
-		s.BPrintf("  if err := %s.validate(); err != nil {", receiver)
-		s.BPrintf("    return %s{}, err", structName)
-		s.BPrintf("  }\n\n")
-	}
-	return ok
+// unmarshalOptions reads the options of the plugin from its tag arguments, if the tag is present
+func unmarshalOptions(mapper generator.Mapper, plugin string, options interface{}) error {
+	tag, ok := mapper.GetTags().FindTag(plugin)
+	if !ok {
+		return nil
+	}
+	if err := tag.Unmarshal(options); err != nil {
+		return fmt.Errorf("reading %s options: %w", plugin, err)
+	}
+	return nil
 }
 
-func PrintZeroCheck(s *generator.Scribler, mapper generator.Mapper, receiver string) bool {
-	if receiver != "" {
-		receiver += "."
+// checkConstructor checks the methods and the tags of the struct that the generated constructor uses
+func checkConstructor(mapper generator.Mapper) []generator.Issue {
+	return append(checkValidate(mapper), checkDefaults(mapper)...)
+}
+
+// newErrs returns the statement that declares errs, the validation error collecting the errors of the struct
+func newErrs(c *generator.Code, mapper generator.Mapper, errs string) generator.Stmt {
+	return generator.Define(errs, generator.Call(generator.Id(c.Qual(validationPath, "New")), generator.Quote(mapper.GetName())))
+}
+
+// returnErrs returns the statement that returns errs, if it collected any error
+func returnErrs(mapper generator.Mapper, errs string) generator.Stmt {
+	err := generator.Id("err")
+	return generator.IfInit(
+		generator.Define("err", generator.Call(generator.Sel(generator.Id(errs), "Err"))),
+		generator.Binary(err, token.NEQ, generator.Id("nil")),
+		generator.Return(generator.Lit(mapper.GetName()), err),
+	)
+}
+
+// validateCall returns the statement that adds to errs the error of the validate method of the struct, if the method exists.
+// It is only called if the required fields are not empty, so that it can rely on them.
+func validateCall(mapper generator.Mapper, receiver, errs string) (generator.Stmt, bool) {
+	if _, ok := mapper.FindMethod(ValidateMethodName); !ok {
+		return nil, false
 	}
-	structName := mapper.GetName()
-	checked := false
+	return generator.If(
+		generator.Unary(token.NOT, generator.Call(generator.Sel(generator.Id(errs), "HasViolations"))),
+		generator.Do(generator.Call(
+			generator.Sel(generator.Id(errs), "Add"),
+			generator.Call(generator.Sel(generator.Id(receiver), ValidateMethodName)),
+		)),
+	), true
+}
+
+// checkValidate checks that the validate method of the struct, called by the generated code, is validate() error
+func checkValidate(mapper generator.Mapper) []generator.Issue {
+	m, ok := mapper.FindMethod(ValidateMethodName)
+	if !ok || (len(m.Args) == 0 && len(m.Results) == 1 && m.Results[0].IsError()) {
+		return nil
+	}
+	return []generator.Issue{{
+		Pos:     m.Pos,
+		Message: fmt.Sprintf("%s.%s must have the signature %s() error", mapper.GetName(), ValidateMethodName, ValidateMethodName),
+	}}
+}
+
+// zeroChecks returns the statements that add to errs the required fields that are empty.
+// value returns the expression holding the value of the field.
+func zeroChecks(mapper generator.Mapper, errs string, value func(generator.Field) generator.Expr) []generator.Stmt {
+	var stmts []generator.Stmt
 	for _, field := range mapper.GetFields() {
 		if field.HasTag(RequiredTag) {
-			checked = true
-			s.BPrintf("  if %s {\n", field.Kind.ZeroCondition(receiver+field.NameForField()))
-			s.BPrintf("    return %s{}, errors.New(\"%s.%s cannot be empty\")\n", structName, structName, field.Name)
-			s.BPrintf("  }\n")
+			stmts = append(stmts, generator.If(
+				zeroCondition(field, value(field)),
+				generator.Do(generator.Call(generator.Sel(generator.Id(errs), "Require"), generator.Quote(field.NameOrKindName()))),
+			))
 		}
 	}
-	return checked
+	return stmts
 }
 
-func PrintIsZero(s *generator.Scribler, mapper generator.Mapper) bool {
-	structName := mapper.GetName()
+// zeroCondition returns the condition that is true when the value of the field is the zero value of its kind
+func zeroCondition(field generator.Field, value generator.Expr) generator.Expr {
+	return generator.Raw(field.Kind.ZeroCondition(value.String()))
+}
+
+// isZeroFunc returns the IsZero method of the struct, unless the struct already declares it
+func isZeroFunc(mapper generator.Mapper) (*generator.Func, bool) {
 	if _, ok := mapper.FindMethod("IsZero"); ok {
-		return false
+		return nil, false
 	}
 
+	structName := mapper.GetName()
 	receiver := generator.UncapFirstSingle(structName)
-	s.BPrintf("\nfunc (%s %s) IsZero() bool {\n", receiver, structName)
 	comp := true
 	for _, f := range mapper.GetFields() {
 		_, basic := f.Kind.(generator.Basic)
@@ -52,54 +113,136 @@ func PrintIsZero(s *generator.Scribler, mapper generator.Mapper) bool {
 			comp = false
 		}
 	}
+
+	recv := generator.Id(receiver)
+	var expr generator.Expr
 	if comp {
-		s.BPrintf("  return %s == %s{}\n", receiver, structName)
+		expr = generator.Binary(recv, token.EQL, generator.Lit(structName))
 	} else {
-		last := len(mapper.GetFields()) - 1
-		s.BPrintf("  return ")
-		for k, field := range mapper.GetFields() {
-			s.BPrintf("%s", field.Kind.ZeroCondition(receiver+"."+field.NameForField()))
-			if k < last {
-				s.BPrintf(" ||\n")
-			}
+		conds := make([]generator.Expr, 0, len(mapper.GetFields()))
+		for _, field := range mapper.GetFields() {
+			conds = append(conds, zeroCondition(field, generator.Sel(recv, field.NameForField())))
 		}
+		expr = generator.OrLines(conds...)
 	}
-	s.BPrintf("}\n")
 
-	return true
+	return &generator.Func{
+		Recv:    &generator.Param{Name: receiver, Type: structName},
+		Name:    "IsZero",
+		Results: []generator.Param{{Type: "bool"}},
+		Body:    []generator.Stmt{generator.Return(expr)},
+	}, true
 }
 
-func PrintString(s *generator.Scribler, mapper generator.Mapper) bool {
-	structName := mapper.GetName()
+// stringFunc returns the String method of the struct, unless the struct already declares it
+func stringFunc(c *generator.Code, mapper generator.Mapper) (*generator.Func, bool) {
 	if _, ok := mapper.FindMethod("String"); ok {
-		return false
+		return nil, false
 	}
 
+	structName := mapper.GetName()
 	receiver := generator.UncapFirstSingle(structName)
-	s.BPrintf("\nfunc (%s %s) String() string {\n", receiver, structName)
-
-	s.BPrintf("  return fmt.Sprintf(\"%s{", structName)
-	fieldCount := 0
+	formats := []string{}
+	args := []generator.Expr{}
 	for _, field := range mapper.GetFields() {
 		if field.IsFunc() {
 			continue
 		}
+		formats = append(formats, field.NameOrKindName()+": %+v")
+		args = append(args, generator.Sel(generator.Id(receiver), field.NameOrKindName()))
+	}
+	format := generator.Quote(structName + "{" + strings.Join(formats, ", ") + "}")
 
-		if fieldCount > 0 {
-			s.BPrintf(", ")
-		}
-		fieldCount++
-		s.BPrintf("%s: %%+v", field.NameOrKindName())
+	return &generator.Func{
+		Recv:    &generator.Param{Name: receiver, Type: structName},
+		Name:    "String",
+		Results: []generator.Param{{Type: "string"}},
+		Body: []generator.Stmt{
+			generator.Return(generator.Call(generator.Id(c.Qual("fmt", "Sprintf")), append([]generator.Expr{format}, args...)...)),
+		},
+	}, true
+}
+
+// emitIsZeroAndString emits the IsZero and String methods that the struct does not declare
+func emitIsZeroAndString(c *generator.Code, mapper generator.Mapper) {
+	if f, ok := isZeroFunc(mapper); ok {
+		c.Emit(f)
+	}
+	if f, ok := stringFunc(c, mapper); ok {
+		c.Emit(f)
 	}
-	s.BPrintf("}\", ")
-	for k, field := range mapper.GetFields() {
-		if k > 0 {
-			s.BPrintf(", ")
+}
+
+// PrintValidate writes the call of the validate method of the struct, returning its error, if the method exists.
+//
+// Deprecated: emit the code with generator.Code, like the plugins of this package do.
+// It will be removed in the next release.
+func PrintValidate(s *generator.Scribler, mapper generator.Mapper, receiver string) bool {
+	if _, ok := mapper.FindMethod(ValidateMethodName); !ok {
+		return false
+	}
+	s.BStmts(
+		generator.IfInit(
+			generator.Define("err", generator.Call(generator.Sel(generator.Id(receiver), ValidateMethodName))),
+			generator.Binary(generator.Id("err"), token.NEQ, generator.Id("nil")),
+			generator.Return(generator.Lit(mapper.GetName()), generator.Id("err")),
+		),
+		generator.Blank(),
+	)
+	return true
+}
+
+// PrintZeroCheck writes the checks of the required fields, returning an error on the first one that is empty.
+// The error is created with errors.New, so the plugin must import errors.
+//
+// Deprecated: emit the code with generator.Code, like the plugins of this package do.
+// It will be removed in the next release.
+func PrintZeroCheck(s *generator.Scribler, mapper generator.Mapper, receiver string) bool {
+	structName := mapper.GetName()
+	checked := false
+	for _, field := range mapper.GetFields() {
+		if field.HasTag(RequiredTag) {
+			checked = true
+			msg := generator.Quote(fmt.Sprintf("%s.%s cannot be empty", structName, field.Name))
+			value := generator.Id(field.NameForField())
+			if receiver != "" {
+				value = generator.Sel(generator.Id(receiver), field.NameForField())
+			}
+			s.BStmts(generator.If(
+				zeroCondition(field, value),
+				generator.Return(generator.Lit(structName), generator.Call(generator.Id("errors.New"), msg)),
+			))
 		}
-		s.BPrintf("%s.%s", receiver, field.NameOrKindName())
 	}
-	s.BPrintf(")\n")
-	s.BPrintf("}\n")
+	return checked
+}
+
+// PrintIsZero writes the IsZero method of the struct, unless the struct already declares it
+//
+// Deprecated: emit the code with generator.Code, like the plugins of this package do.
+// It will be removed in the next release.
+func PrintIsZero(s *generator.Scribler, mapper generator.Mapper) bool {
+	f, ok := isZeroFunc(mapper)
+	if !ok {
+		return false
+	}
+	var c generator.Code
+	c.Emit(f)
+	s.BPrint(c.String())
+	return true
+}
 
+// PrintString writes the String method of the struct, unless the struct already declares it
+//
+// Deprecated: emit the code with generator.Code, like the plugins of this package do.
+// It will be removed in the next release.
+func PrintString(s *generator.Scribler, mapper generator.Mapper) bool {
+	var c generator.Code
+	f, ok := stringFunc(&c, mapper)
+	if !ok {
+		return false
+	}
+	c.Emit(f)
+	s.BPrint(c.String())
 	return true
 }
@@ -0,0 +1,90 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/quintans/gog/generator"
+	"github.com/quintans/gog/gogtest"
+)
+
+// legacy is a plugin written with the print helpers, before generator.Code
+type legacy struct {
+	generator.Code
+}
+
+func (*legacy) Name() string {
+	return "legacy"
+}
+
+func (*legacy) Accepts() []generator.MapperType {
+	return []generator.MapperType{generator.StructMapper}
+}
+
+func (*legacy) Imports(generator.Mapper) map[string]string {
+	return map[string]string{"errors": ""}
+}
+
+func (l *legacy) GenerateBody(mapper generator.Mapper) error {
+	l.BPrintf("\nfunc Check%s(f %s) (%s, error) {\n", mapper.GetName(), mapper.GetName(), mapper.GetName())
+	PrintZeroCheck(&l.Scribler, mapper, "f")
+	PrintValidate(&l.Scribler, mapper, "f")
+	l.BPrintf("return f, nil\n}\n")
+	PrintIsZero(&l.Scribler, mapper)
+	PrintString(&l.Scribler, mapper)
+	return nil
+}
+
+func TestDeprecatedPrintHelpers(t *testing.T) {
+	plugin := &legacy{}
+	if err := generator.Register(plugin, generator.WithFieldTags(RequiredTag)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { generator.Unregister(plugin) })
+
+	in := `
+package p
+
+// gog:legacy
+type Foo struct {
+	// gog:@required
+	name  string
+	value int64
+}
+
+func (f Foo) validate() error {
+	return nil
+}
+`
+	got := gogtest.Generate(t, in)
+	gogtest.TypeCheck(t, in, got)
+	gogtest.Equal(t, got, gogtest.Normalize(`// Code generated by gog; DO NOT EDIT.
+// Version: (devel)
+package p
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Generated by gog:legacy
+
+func CheckFoo(f Foo) (Foo, error) {
+	if f.name == "" {
+		return Foo{}, errors.New("Foo.name cannot be empty")
+	}
+	if err := f.validate(); err != nil {
+		return Foo{}, err
+	}
+
+	return f, nil
+}
+
+func (f Foo) IsZero() bool {
+	return f == Foo{}
+}
+
+func (f Foo) String() string {
+	return fmt.Sprintf("Foo{name: %+v, value: %+v}", f.name, f.value)
+}
+`))
+}
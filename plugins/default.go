@@ -0,0 +1,150 @@
+package plugins
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+
+	"github.com/quintans/gog/generator"
+)
+
+// DefaultTag sets the default value of a field, as a Go expression: `// gog:@default 30` or `// gog:@default {"value": "time.Second*30"}`
+const DefaultTag = "@default"
+
+// defaultOf returns the expression of the default value of the field, if it has one
+func defaultOf(field generator.Field) (generator.Expr, bool) {
+	tag, ok := field.FindTag(DefaultTag)
+	if !ok {
+		return generator.Expr{}, false
+	}
+	expr, err := defaultExpr(tag)
+	if err != nil {
+		return generator.Expr{}, false
+	}
+	return generator.Raw(expr), true
+}
+
+func defaultExpr(tag generator.Tag) (string, error) {
+	expr := strings.TrimSpace(tag.Args)
+	if strings.HasPrefix(expr, "{") {
+		var args struct {
+			Value string `json:"value"`
+		}
+		if err := tag.Unmarshal(&args); err != nil {
+			return "", err
+		}
+		expr = strings.TrimSpace(args.Value)
+	}
+	if expr == "" {
+		return "", errors.New("missing value, eg: // gog:@default 30")
+	}
+	return expr, nil
+}
+
+// checkDefaults checks that the default values of the fields are valid expressions of the type of the field
+func checkDefaults(mapper generator.Mapper) []generator.Issue {
+	var issues []generator.Issue
+	for _, field := range mapper.GetFields() {
+		tag, ok := field.FindTag(DefaultTag)
+		if !ok {
+			continue
+		}
+		expr, err := defaultExpr(tag)
+		if err == nil {
+			err = checkDefaultExpr(expr, field.Kind)
+		}
+		if err == nil && field.HasTag(RequiredTag) {
+			err = errors.New("a required field cannot have a default")
+		}
+		if err != nil {
+			issues = append(issues, generator.Issue{
+				Pos:     tag.Pos,
+				Message: fmt.Sprintf("invalid %s of %s.%s: %s", DefaultTag, mapper.GetName(), field.NameOrKindName(), err),
+			})
+		}
+	}
+	return issues
+}
+
+// checkDefaultExpr checks that the expression is valid Go and, when the field has a predeclared type, like int or string,
+// and the expression only uses predeclared identifiers, that it can be assigned to the field.
+// The other expressions, like time.Second*30, are checked when the generated code is compiled.
+func checkDefaultExpr(expr string, kind generator.Kinder) error {
+	x, err := parser.ParseExpr(expr)
+	if err != nil {
+		return err
+	}
+	typeName, ok := types.Universe.Lookup(kind.String()).(*types.TypeName)
+	if !ok || !predeclared(x) {
+		return nil
+	}
+
+	tv, err := types.Eval(token.NewFileSet(), nil, token.NoPos, expr)
+	if err != nil {
+		return evalErr(err)
+	}
+	if !types.AssignableTo(tv.Type, typeName.Type()) {
+		return fmt.Errorf("cannot use %s (%s) as %s", expr, tv.Type, kind)
+	}
+	if tv.Value != nil {
+		// a constant must be representable by the type, eg: 300 is not an int8
+		if _, err := types.Eval(token.NewFileSet(), nil, token.NoPos, kind.String()+"("+expr+")"); err != nil {
+			return evalErr(err)
+		}
+	}
+	return nil
+}
+
+// evalErr drops the position in the evaluated expression from the error
+func evalErr(err error) error {
+	var terr types.Error
+	if errors.As(err, &terr) {
+		return errors.New(terr.Msg)
+	}
+	return err
+}
+
+// predeclared returns true if the expression only uses predeclared identifiers, like true or len
+func predeclared(x ast.Expr) bool {
+	ok := true
+	ast.Inspect(x, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			ok = false
+		case *ast.Ident:
+			if types.Universe.Lookup(n.Name) == nil {
+				ok = false
+			}
+		}
+		return ok
+	})
+	return ok
+}
+
+// defaultAssigns returns the statements that replace the zero values of the fields by their default values.
+// value returns the expression holding the value of the field.
+func defaultAssigns(mapper generator.Mapper, value func(generator.Field) generator.Expr) []generator.Stmt {
+	var stmts []generator.Stmt
+	for _, field := range mapper.GetFields() {
+		if expr, ok := defaultOf(field); ok {
+			stmts = append(stmts, generator.If(defaultCondition(field, value(field)), generator.Assign(value(field), expr)))
+		}
+	}
+	return stmts
+}
+
+// defaultCondition returns the condition of the value being the zero of the field.
+// The kind of a named type, like time.Duration, does not tell if it is a struct,
+// so it is compared with *new(T), that is the zero value of any type.
+func defaultCondition(field generator.Field, value generator.Expr) generator.Expr {
+	if b, ok := field.Kind.(generator.Basic); ok {
+		if _, ok := generator.Zero(b.Name()); !ok {
+			return generator.Binary(value, token.EQL, generator.Raw(fmt.Sprintf("*new(%s)", b)))
+		}
+	}
+	return zeroCondition(field, value)
+}
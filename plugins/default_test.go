@@ -0,0 +1,71 @@
+package plugins
+
+import "testing"
+
+func TestInvalidDefault(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr string
+	}{
+		{
+			"wrong_type",
+			`
+package p
+
+// gog:builder
+type Foo struct {
+	// gog:@default "a"
+	value int
+}
+`,
+			`src.go:6:2: builder: invalid @default of Foo.value: cannot use "a" (untyped string) as int`,
+		},
+		{
+			"overflow",
+			`
+package p
+
+// gog:options
+type Foo struct {
+	// gog:@default 300
+	value int8
+}
+`,
+			`src.go:6:2: options: invalid @default of Foo.value: constant 300 overflows int8`,
+		},
+		{
+			"required",
+			`
+package p
+
+// gog:record
+type Foo struct {
+	// gog:@required
+	// gog:@default 1
+	value int
+}
+`,
+			`src.go:7:2: record: invalid @default of Foo.value: a required field cannot have a default`,
+		},
+		{
+			"missing_value",
+			`
+package p
+
+// gog:builder
+type Foo struct {
+	// gog:@default
+	value int
+}
+`,
+			`src.go:6:2: builder: invalid @default of Foo.value: missing value, eg: // gog:@default 30`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runErr(t, tt.in, tt.wantErr)
+		})
+	}
+}
@@ -4,19 +4,26 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/quintans/gog/config"
 	"github.com/quintans/gog/generator"
 )
 
 func init() {
-	generator.Register(&Getters{})
+	generator.MustRegister(
+		&Getters{},
+		generator.WithVersion(config.Version),
+		generator.WithDescription("generates a getter for every field"),
+		generator.WithOptions(GetterOptions{}),
+		generator.WithFieldTags(IgnoreTag),
+	)
 }
 
 type GetterOptions struct {
-	Pointer bool
+	Pointer bool `desc:"getters use pointer receivers"`
 }
 
 type Getters struct {
-	generator.Scribler
+	generator.Code
 }
 
 func (b *Getters) Name() string {
@@ -33,10 +40,8 @@ func (b *Getters) Imports(mapper generator.Mapper) map[string]string {
 
 func (b *Getters) GenerateBody(mapper generator.Mapper) error {
 	options := GetterOptions{}
-	if tag, ok := mapper.GetTags().FindTag(b.Name()); ok {
-		if err := tag.Unmarshal(&options); err != nil {
-			return err
-		}
+	if err := unmarshalOptions(mapper, b.Name(), &options); err != nil {
+		return err
 	}
 
 	err := b.WriteBody(mapper, options)
@@ -47,12 +52,11 @@ func (b *Getters) GenerateBody(mapper generator.Mapper) error {
 }
 
 func (b *Getters) WriteBody(mapper generator.Mapper, options GetterOptions) error {
-	var star string
+	structName := mapper.GetName()
+	recv := &generator.Param{Name: generator.UncapFirstSingle(structName), Type: structName}
 	if options.Pointer {
-		star = "*"
+		recv.Type = "*" + structName
 	}
-	structName := mapper.GetName()
-	receiver := generator.UncapFirstSingle(structName)
 	for _, field := range mapper.GetFields() {
 		if field.HasTag(IgnoreTag) {
 			continue
@@ -62,9 +66,12 @@ func (b *Getters) WriteBody(mapper generator.Mapper, options GetterOptions) erro
 		if field.IsNested() {
 			getter = "Get" + getter
 		}
-		b.BPrintf("\nfunc (%s %s%s) %s() %s {\n", receiver, star, structName, getter, field.Kind.String())
-		b.BPrintf("  return %s.%s\n", receiver, fieldName)
-		b.BPrintf("}\n")
+		b.Emit(&generator.Func{
+			Recv:    recv,
+			Name:    getter,
+			Results: []generator.Param{{Type: field.Kind.String()}},
+			Body:    []generator.Stmt{generator.Return(generator.Sel(generator.Id(recv.Name), fieldName))},
+		})
 	}
 
 	return nil
@@ -24,6 +24,8 @@ type Foo struct {
 	name  string
 	timeout int64
 }
+
+type Bar struct{}
 `,
 			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
 // Version: %s
@@ -42,6 +44,28 @@ func (f *Foo) Name() string {
 func (f *Foo) Timeout() int64 {
 	return f.timeout
 }
+`, config.Version),
+		},
+		{
+			"Getter_KeyValue",
+			`
+package p
+
+// gog:getters \
+//   pointer=true
+type Foo struct {
+	name  string
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+// Generated by gog:getters
+
+func (f *Foo) Name() string {
+	return f.name
+}
 `, config.Version),
 		},
 		{
@@ -56,6 +80,8 @@ type Foo struct {
 	value []Bar
 	timeout int64
 }
+
+type Bar struct{}
 `,
 			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
 // Version: %s
@@ -70,6 +96,41 @@ func (f Foo) Name() string {
 func (f Foo) Timeout() int64 {
 	return f.timeout
 }
+`, config.Version),
+		},
+		{
+			"Getter_other_types",
+			`
+package p
+
+// gog:getters
+type Foo struct {
+	events chan int
+	pair   [2]int
+	list   List[int]
+}
+
+type List[T any] []T
+
+func (f Foo) Do(args ...string) {}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+// Generated by gog:getters
+
+func (f Foo) Events() chan int {
+	return f.events
+}
+
+func (f Foo) Pair() [2]int {
+	return f.pair
+}
+
+func (f Foo) List() List[int] {
+	return f.list
+}
 `, config.Version),
 		},
 	}
@@ -80,3 +141,43 @@ func (f Foo) Timeout() int64 {
 		})
 	}
 }
+
+func TestGetterInvalidOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		wantErr string
+	}{
+		{
+			"unknown_option",
+			`
+package p
+
+// gog:getters {"pointr":true}
+type Foo struct {
+	name  string
+}
+`,
+			`src.go:4:17: getters: unknown option "pointr": available options are pointer`,
+		},
+		{
+			"wrong_type",
+			`
+package p
+
+// Foo is a struct
+// gog:getters {"pointer":1}
+type Foo struct {
+	name  string
+}
+`,
+			`src.go:5:27: getters: option "pointer" must be of type bool, got 1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runErr(t, tt.in, tt.wantErr)
+		})
+	}
+}
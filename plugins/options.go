@@ -1,17 +1,28 @@
 package plugins
 
 import (
+	"go/token"
 	"strings"
 
+	"github.com/quintans/gog/config"
 	"github.com/quintans/gog/generator"
 )
 
 func init() {
-	generator.Register(&Options{})
+	generator.MustRegister(
+		&Options{},
+		generator.WithVersion(config.Version),
+		generator.WithDescription("generates functional options and a constructor for the struct"),
+		generator.WithOptions(OptionsOptions{}),
+		generator.WithFieldTags(RequiredTag, IgnoreTag, DefaultTag),
+		generator.WithCheck(checkDefaults),
+	)
 }
 
+type OptionsOptions struct{}
+
 type Options struct {
-	generator.Scribler
+	generator.Code
 }
 
 func (b *Options) Name() string {
@@ -27,43 +38,65 @@ func (b *Options) Imports(mapper generator.Mapper) map[string]string {
 }
 
 func (b *Options) GenerateBody(mapper generator.Mapper) error {
+	options := OptionsOptions{}
+	if err := unmarshalOptions(mapper, b.Name(), &options); err != nil {
+		return err
+	}
+	return b.WriteBody(mapper, options)
+}
+
+func (b *Options) WriteBody(mapper generator.Mapper, _ OptionsOptions) error {
+	structName := mapper.GetName()
 	for _, field := range mapper.GetFields() {
 		if field.HasTag(RequiredTag) || field.HasTag(IgnoreTag) {
 			continue
 		}
 
 		fieldName := field.NameOrKindName()
-		optionFunc := mapper.GetName() + strings.Title(fieldName)
-		arg := generator.UncapFirst(fieldName)
-		b.BPrintf("func %s(%s %s) func(*%s) {\n", optionFunc, arg, field.Kind.String(), mapper.GetName())
-		b.BPrintf("	return func(t *%s) {\n", mapper.GetName())
-		b.BPrintf("		t.%s = %s\n", fieldName, arg)
-		b.BPrintf("	}\n")
-		b.BPrintf("}\n\n")
+		scope := generator.NewScope()
+		arg := scope.Name(generator.UncapFirst(fieldName))
+		t := scope.Name("t")
+		b.Emit(&generator.Func{
+			Name:    structName + strings.Title(fieldName),
+			Params:  []generator.Param{{Name: arg, Type: field.Kind.String()}},
+			Results: []generator.Param{{Type: "func(*" + structName + ")"}},
+			Body: []generator.Stmt{
+				generator.Return(generator.FuncLit(
+					[]generator.Param{{Name: t, Type: "*" + structName}},
+					nil,
+					generator.Assign(generator.Sel(generator.Id(t), fieldName), generator.Id(arg)),
+				)),
+			},
+		})
 	}
 
-	args := &generator.Scribler{}
+	scope := generator.NewScope()
+	params := []generator.Param{}
+	elems := []generator.KeyValue{}
 	for _, field := range mapper.GetFields() {
 		if field.HasTag(RequiredTag) {
-			args.BPrintf("%s %s,", generator.UncapFirst(field.NameOrKindName()), field.Kind.String())
+			name := scope.Name(generator.UncapFirst(field.NameOrKindName()))
+			params = append(params, generator.Param{Name: name, Type: field.Kind.String()})
+			elems = append(elems, generator.KeyValue{Key: field.NameOrKindName(), Value: generator.Id(name)})
+		} else if expr, ok := defaultOf(field); ok {
+			elems = append(elems, generator.KeyValue{Key: field.NameOrKindName(), Value: expr})
 		}
 	}
+	options := scope.Name("options")
+	params = append(params, generator.Param{Name: options, Type: "...func(*" + structName + ")"})
+	t := scope.Name("t")
+	option := scope.Name("option")
 
-	structName := mapper.GetName()
-	b.BPrintf("\nfunc New%sOptions(%s options ...func(*%s)) *%s {\n", structName, args, structName, structName)
-	b.BPrintf("	t := &%s {\n", structName)
-	for _, field := range mapper.GetFields() {
-		if field.HasTag(RequiredTag) {
-			fieldName := field.NameOrKindName()
-			b.BPrintf("	%s: %s,\n", fieldName, generator.UncapFirst(fieldName))
-		}
-	}
-	b.BPrintf("	}\n")
-	b.BPrintf("	for _, option := range options {\n")
-	b.BPrintf("		option(t)\n")
-	b.BPrintf("	}\n")
-	b.BPrintf("	return t\n")
-	b.BPrintf("}\n")
+	b.Emit(&generator.Func{
+		Name:    "New" + structName + "Options",
+		Params:  params,
+		Results: []generator.Param{{Type: "*" + structName}},
+		Body: []generator.Stmt{
+			generator.Define(t, generator.Unary(token.AND, generator.Lit(structName, elems...))),
+			generator.Range("_", option, generator.Id(options), generator.Do(generator.Call(generator.Id(option), generator.Id(t)))),
+			generator.Return(generator.Id(t)),
+		},
+	})
 
 	return nil
 }
@@ -64,6 +64,8 @@ type Foo struct {
 	value []Bar
 	timeout int64
 }
+
+type Bar struct{}
 `,
 			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
 // Version: %s
@@ -86,6 +88,56 @@ func NewFooOptions(name string, options ...func(*Foo)) *Foo {
 	}
 	return t
 }
+`, config.Version),
+		},
+		{
+			"Option_Defaults",
+			`
+package p
+
+import "time"
+
+// gog:options
+type Foo struct {
+	// gog:@required
+	name string
+	// gog:@default 3
+	retries int
+	// gog:@default {"value": "time.Second*30"}
+	timeout time.Duration
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+import "time"
+
+// Generated by gog:options
+
+func FooRetries(retries int) func(*Foo) {
+	return func(t *Foo) {
+		t.retries = retries
+	}
+}
+
+func FooTimeout(timeout time.Duration) func(*Foo) {
+	return func(t *Foo) {
+		t.timeout = timeout
+	}
+}
+
+func NewFooOptions(name string, options ...func(*Foo)) *Foo {
+	t := &Foo{
+		name:    name,
+		retries: 3,
+		timeout: time.Second * 30,
+	}
+	for _, option := range options {
+		option(t)
+	}
+	return t
+}
 `, config.Version),
 		},
 	}
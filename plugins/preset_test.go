@@ -0,0 +1,50 @@
+package plugins
+
+import (
+	"testing"
+
+	"github.com/quintans/gog/generator"
+	"github.com/quintans/gog/gogtest"
+)
+
+func TestPreset(t *testing.T) {
+	// the preset of the README
+	if err := generator.RegisterPreset("entity", "[allArgsConstructor, builder{pointer: true}]"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { generator.UnregisterPreset("entity") })
+
+	in := `
+package p
+
+// gog:entity
+type Foo struct {
+	// gog:@required
+	name   string
+	things []int
+}
+
+func (f *Foo) validate() error {
+	return nil
+}
+`
+	got := gogtest.Generate(t, in)
+	gogtest.TypeCheck(t, in, got)
+}
+
+func TestPresetConflict(t *testing.T) {
+	if err := generator.RegisterPreset("dto", "[record, builder]"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { generator.UnregisterPreset("dto") })
+
+	in := `
+package p
+
+// gog:dto
+type Foo struct {
+	name string
+}
+`
+	gogtest.GenerateErr(t, in, "preset dto: plugins record and builder both generate Foo.Name")
+}
@@ -3,23 +3,36 @@ package plugins
 import (
 	"fmt"
 
+	"github.com/quintans/gog/config"
 	"github.com/quintans/gog/generator"
 )
 
 func init() {
-	generator.Register(&Record{
-		allArgs: &AllArgsConstructor{},
-		getters: &Getters{},
-	})
+	generator.MustRegister(
+		&Record{
+			allArgs: &AllArgsConstructor{},
+			getters: &Getters{},
+		},
+		generator.WithVersion(config.Version),
+		generator.WithDescription("generates an immutable record with constructor, getters, IsZero and String"),
+		generator.WithOptions(RecordOptions{}),
+		generator.WithConstruction(),
+		generator.WithImmutable(),
+		generator.WithFieldTags(RequiredTag, IgnoreTag, DefaultTag),
+		generator.WithEmbeds("allArgsConstructor", "getters"),
+		generator.WithCheck(checkConstructor),
+	)
 }
 
 type Record struct {
-	generator.Scribler
+	generator.Code
 	allArgs *AllArgsConstructor
 	getters *Getters
 }
 
-type RecordOptions struct{}
+type RecordOptions struct {
+	Pointer bool `desc:"getters use pointer receivers"`
+}
 
 func (s *Record) Name() string {
 	return "record"
@@ -36,22 +49,24 @@ func (s *Record) Imports(mapper generator.Mapper) map[string]string {
 }
 
 func (s *Record) GenerateBody(mapper generator.Mapper) error {
-	return s.WriteBody(mapper, RecordOptions{})
+	options := RecordOptions{}
+	if err := unmarshalOptions(mapper, s.Name(), &options); err != nil {
+		return err
+	}
+	return s.WriteBody(mapper, options)
 }
 
-func (s *Record) WriteBody(mapper generator.Mapper, _ RecordOptions) error {
+func (s *Record) WriteBody(mapper generator.Mapper, options RecordOptions) error {
 	s.allArgs.WriteBody(mapper, AllArgsConstructorOptions{})
-	err := s.getters.WriteBody(mapper, GetterOptions{})
+	err := s.getters.WriteBody(mapper, GetterOptions{Pointer: options.Pointer})
 	if err != nil {
 		return fmt.Errorf("writing Record body: %w", err)
 	}
 
-	s.Body.Write(s.allArgs.Flush())
-	s.Body.Write(s.getters.Flush())
-
-	_ = PrintIsZero(&s.Scribler, mapper)
+	s.Append(&s.allArgs.Code)
+	s.Append(&s.getters.Code)
 
-	_ = PrintString(&s.Scribler, mapper)
+	emitIsZeroAndString(&s.Code, mapper)
 
 	return nil
 }
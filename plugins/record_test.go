@@ -89,19 +89,23 @@ func (f Foo) String() string {
 // Version: %s
 package p
 
-import "errors"
+import "github.com/quintans/gog/validation"
 
 // Generated by gog:record
 
 func NewFoo(
 	name string,
 ) (Foo, error) {
+	errs := validation.New("Foo")
 	if name == "" {
-		return Foo{}, errors.New("Foo.name cannot be empty")
+		errs.Require("name")
 	}
 	f := Foo{
 		name: name,
 	}
+	if err := errs.Err(); err != nil {
+		return Foo{}, err
+	}
 
 	return f, nil
 }
@@ -146,9 +150,10 @@ type Foo struct {
 package p
 
 import (
-	"errors"
 	"fmt"
 	"time"
+
+	"github.com/quintans/gog/validation"
 )
 
 // Generated by gog:record
@@ -157,13 +162,17 @@ func NewFoo(
 	name string,
 	clock time.Time,
 ) (Foo, error) {
+	errs := validation.New("Foo")
 	if name == "" {
-		return Foo{}, errors.New("Foo.name cannot be empty")
+		errs.Require("name")
 	}
 	f := Foo{
 		name:  name,
 		clock: clock,
 	}
+	if err := errs.Err(); err != nil {
+		return Foo{}, err
+	}
 
 	return f, nil
 }
@@ -197,6 +206,48 @@ func (f Foo) IsZero() bool {
 func (f Foo) String() string {
 	return fmt.Sprintf("Foo{name: %%+v, clock: %%+v}", f.name, f.clock)
 }
+`, config.Version),
+		},
+		{
+			"Record_with_getters_defaults",
+			`
+// gog:defaults getters pointer=true
+package p
+
+// gog:record
+type Foo struct {
+	name string
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+import "fmt"
+
+// Generated by gog:record
+
+func NewFoo(
+	name string,
+) Foo {
+	f := Foo{
+		name: name,
+	}
+
+	return f
+}
+
+func (f *Foo) Name() string {
+	return f.name
+}
+
+func (f Foo) IsZero() bool {
+	return f == Foo{}
+}
+
+func (f Foo) String() string {
+	return fmt.Sprintf("Foo{name: %%+v}", f.name)
+}
 `, config.Version),
 		},
 	}
@@ -1,15 +1,25 @@
 package plugins
 
 import (
+	"github.com/quintans/gog/config"
 	"github.com/quintans/gog/generator"
 )
 
 func init() {
-	generator.Register(&RequiredArgsConstructor{})
+	generator.MustRegister(
+		&RequiredArgsConstructor{},
+		generator.WithVersion(config.Version),
+		generator.WithDescription("generates a constructor that includes the required fields"),
+		generator.WithOptions(RequiredArgsConstructorOptions{}),
+		generator.WithOutOfPackage(),
+		generator.WithFieldTags(RequiredTag),
+	)
 }
 
+type RequiredArgsConstructorOptions struct{}
+
 type RequiredArgsConstructor struct {
-	generator.Scribler
+	generator.Code
 }
 
 func (b *RequiredArgsConstructor) Name() string {
@@ -25,24 +35,32 @@ func (b *RequiredArgsConstructor) Imports(mapper generator.Mapper) map[string]st
 }
 
 func (b *RequiredArgsConstructor) GenerateBody(mapper generator.Mapper) error {
-	args := &generator.Scribler{}
-	for _, field := range mapper.GetFields() {
-		if field.HasTag(RequiredTag) {
-			args.BPrintf("%s %s,", generator.UncapFirst(field.NameOrKindName()), field.Kind.String())
-		}
+	options := RequiredArgsConstructorOptions{}
+	if err := unmarshalOptions(mapper, b.Name(), &options); err != nil {
+		return err
 	}
+	return b.WriteBody(mapper, options)
+}
 
-	structName := mapper.GetName()
-	b.BPrintf("\nfunc New%sRequired(%s) %s {\n", structName, args, structName)
-	b.BPrintf(" return %s{\n", structName)
+func (b *RequiredArgsConstructor) WriteBody(mapper generator.Mapper, _ RequiredArgsConstructorOptions) error {
+	scope := generator.NewScope()
+	params := []generator.Param{}
+	elems := []generator.KeyValue{}
 	for _, field := range mapper.GetFields() {
 		if field.HasTag(RequiredTag) {
-			fieldName := field.NameOrKindName()
-			b.BPrintf("	%s: %s,\n", fieldName, generator.UncapFirst(fieldName))
+			name := scope.Name(generator.UncapFirst(field.NameOrKindName()))
+			params = append(params, generator.Param{Name: name, Type: field.Kind.String()})
+			elems = append(elems, generator.KeyValue{Key: field.NameOrKindName(), Value: generator.Id(name)})
 		}
 	}
-	b.BPrintf("  }\n")
-	b.BPrintf("}\n")
+
+	structName := mapper.GetName()
+	b.Emit(&generator.Func{
+		Name:    "New" + structName + "Required",
+		Params:  params,
+		Results: []generator.Param{{Type: structName}},
+		Body:    []generator.Stmt{generator.Return(generator.Lit(structName, elems...))},
+	})
 
 	return nil
 }
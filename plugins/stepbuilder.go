@@ -0,0 +1,123 @@
+package plugins
+
+import (
+	"fmt"
+	"go/token"
+	"strings"
+
+	"github.com/quintans/gog/config"
+	"github.com/quintans/gog/generator"
+)
+
+func init() {
+	generator.MustRegister(
+		&StepBuilder{},
+		generator.WithVersion(config.Version),
+		generator.WithDescription("generates a builder for the struct that only builds after setting the required fields, in declaration order"),
+		generator.WithOptions(StepBuilderOptions{}),
+		generator.WithConstruction(),
+		generator.WithFieldTags(RequiredTag, DefaultTag),
+		generator.WithCheck(checkBuilder),
+	)
+}
+
+type StepBuilderOptions struct {
+	Pointer bool `desc:"getters of the struct use pointer receivers"`
+}
+
+// StepBuilder generates a builder where each required field is set in its own step, an interface returning the next step.
+// After the required fields comes the optional step, where the other fields are set and the struct is built,
+// so that forgetting a required field does not compile.
+//
+//	foo, err := NewFooStepBuilder().Name("a").When(now).Timeout(time.Second).Build()
+type StepBuilder struct {
+	generator.Code
+}
+
+func (b *StepBuilder) Name() string {
+	return "stepBuilder"
+}
+
+func (*StepBuilder) Accepts() []generator.MapperType {
+	return []generator.MapperType{generator.StructMapper}
+}
+
+func (b *StepBuilder) Imports(mapper generator.Mapper) map[string]string {
+	return map[string]string{}
+}
+
+func (b *StepBuilder) GenerateBody(mapper generator.Mapper) error {
+	options := StepBuilderOptions{}
+	if err := unmarshalOptions(mapper, b.Name(), &options); err != nil {
+		return err
+	}
+	return b.WriteBody(mapper, options)
+}
+
+func (b *StepBuilder) WriteBody(mapper generator.Mapper, options StepBuilderOptions) error {
+	structName := mapper.GetName()
+	builderName := generator.UncapFirst(structName) + "StepBuilder"
+	optionalStep := structName + "OptionalStep"
+	build := buildFunc(&b.Code, mapper, "*"+builderName, nil)
+
+	// the steps of the required fields, each returning the next one
+	var required []generator.Field
+	for _, field := range mapper.GetFields() {
+		if field.HasTag(RequiredTag) {
+			required = append(required, field)
+		}
+	}
+	steps := make([]string, len(required)+1)
+	for k, field := range required {
+		steps[k] = structName + strings.Title(field.NameOrKindName()) + "Step"
+	}
+	steps[len(required)] = optionalStep
+
+	for k, field := range required {
+		setter := setterFunc(field, "*"+builderName, steps[k+1])
+		b.Emit(&generator.InterfaceDecl{Name: steps[k], Methods: []generator.Func{*setter}})
+	}
+	var methods []generator.Func
+	for _, field := range mapper.GetFields() {
+		if !field.HasTag(RequiredTag) {
+			methods = append(methods, *setterFunc(field, "*"+builderName, optionalStep))
+		}
+	}
+	b.Emit(&generator.InterfaceDecl{Name: optionalStep, Methods: append(methods, *build)})
+
+	fields := []generator.Param{}
+	for _, field := range mapper.GetFields() {
+		fields = append(fields, generator.Param{Name: field.Name, Type: field.Kind.String()})
+	}
+	b.Emit(&generator.StructDecl{Name: builderName, Fields: fields})
+	defaults := []generator.KeyValue{}
+	for _, field := range mapper.GetFields() {
+		if expr, ok := defaultOf(field); ok {
+			defaults = append(defaults, generator.KeyValue{Key: field.NameForField(), Value: expr})
+		}
+	}
+	b.Emit(&generator.Func{
+		Name:    "New" + structName + "StepBuilder",
+		Results: []generator.Param{{Type: steps[0]}},
+		Body:    []generator.Stmt{generator.Return(generator.Unary(token.AND, generator.Lit(builderName, defaults...)))},
+	})
+
+	for k, field := range required {
+		b.Emit(setterFunc(field, "*"+builderName, steps[k+1]))
+	}
+	for k := range methods {
+		b.Emit(&methods[k])
+	}
+	b.Emit(build)
+	b.Emit(toBuildFunc(mapper, builderName, optionalStep))
+
+	getters := Getters{}
+	if err := getters.WriteBody(mapper, GetterOptions{Pointer: options.Pointer}); err != nil {
+		return fmt.Errorf("generating StepBuilder getters: %w", err)
+	}
+	b.Append(&getters.Code)
+
+	emitIsZeroAndString(&b.Code, mapper)
+
+	return nil
+}
@@ -0,0 +1,332 @@
+package plugins
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/quintans/gog/config"
+)
+
+func TestStepBuilder(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		out  string
+	}{
+		{
+			"StepBuilder",
+			`
+package p
+
+import (
+	"errors"
+	"time"
+)
+
+// gog:stepBuilder
+type Foo struct {
+	Bar
+	// gog:@required
+	name string
+	// gog:@required
+	when    time.Time
+	timeout time.Duration
+}
+
+func (f Foo) validate() error {
+	if f.timeout < 0 {
+		return errors.New("timeout must be > 0")
+	}
+	return nil
+}
+
+type Bar struct{}
+
+func build(when time.Time) (Foo, error) {
+	return NewFooStepBuilder().Name("a").When(when).Timeout(time.Second).Build()
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/quintans/gog/validation"
+)
+
+// Generated by gog:stepBuilder
+
+type FooNameStep interface {
+	Name(name string) FooWhenStep
+}
+
+type FooWhenStep interface {
+	When(when time.Time) FooOptionalStep
+}
+
+type FooOptionalStep interface {
+	WithBar(bar Bar) FooOptionalStep
+	Timeout(timeout time.Duration) FooOptionalStep
+	Build() (Foo, error)
+}
+
+type fooStepBuilder struct {
+	Bar
+	name    string
+	when    time.Time
+	timeout time.Duration
+}
+
+func NewFooStepBuilder() FooNameStep {
+	return &fooStepBuilder{}
+}
+
+func (b *fooStepBuilder) Name(name string) FooWhenStep {
+	b.name = name
+	return b
+}
+
+func (b *fooStepBuilder) When(when time.Time) FooOptionalStep {
+	b.when = when
+	return b
+}
+
+func (b *fooStepBuilder) WithBar(bar Bar) FooOptionalStep {
+	b.Bar = bar
+	return b
+}
+
+func (b *fooStepBuilder) Timeout(timeout time.Duration) FooOptionalStep {
+	b.timeout = timeout
+	return b
+}
+
+func (b *fooStepBuilder) Build() (Foo, error) {
+	errs := validation.New("Foo")
+	if b.name == "" {
+		errs.Require("name")
+	}
+	if (b.when == time.Time{}) {
+		errs.Require("when")
+	}
+	s := Foo{
+		Bar:     b.Bar,
+		name:    b.name,
+		when:    b.when,
+		timeout: b.timeout,
+	}
+
+	if !errs.HasViolations() {
+		errs.Add(s.validate())
+	}
+	if err := errs.Err(); err != nil {
+		return Foo{}, err
+	}
+
+	return s, nil
+}
+
+func (b *Foo) ToBuild() FooOptionalStep {
+	return &fooStepBuilder{
+		Bar:     b.Bar,
+		name:    b.name,
+		when:    b.when,
+		timeout: b.timeout,
+	}
+}
+
+func (f Foo) GetBar() Bar {
+	return f.Bar
+}
+
+func (f Foo) Name() string {
+	return f.name
+}
+
+func (f Foo) When() time.Time {
+	return f.when
+}
+
+func (f Foo) Timeout() time.Duration {
+	return f.timeout
+}
+
+func (f Foo) IsZero() bool {
+	return f == Foo{}
+}
+
+func (f Foo) String() string {
+	return fmt.Sprintf("Foo{Bar: %%+v, name: %%+v, when: %%+v, timeout: %%+v}", f.Bar, f.name, f.when, f.timeout)
+}
+`, config.Version),
+		},
+		{
+			"NoRequiredFields",
+			`
+package p
+
+// gog:stepBuilder
+type Foo struct {
+	name string
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+import "fmt"
+
+// Generated by gog:stepBuilder
+
+type FooOptionalStep interface {
+	Name(name string) FooOptionalStep
+	Build() Foo
+}
+
+type fooStepBuilder struct {
+	name string
+}
+
+func NewFooStepBuilder() FooOptionalStep {
+	return &fooStepBuilder{}
+}
+
+func (b *fooStepBuilder) Name(name string) FooOptionalStep {
+	b.name = name
+	return b
+}
+
+func (b *fooStepBuilder) Build() Foo {
+	s := Foo{
+		name: b.name,
+	}
+
+	return s
+}
+
+func (b *Foo) ToBuild() FooOptionalStep {
+	return &fooStepBuilder{
+		name: b.name,
+	}
+}
+
+func (f Foo) Name() string {
+	return f.name
+}
+
+func (f Foo) IsZero() bool {
+	return f == Foo{}
+}
+
+func (f Foo) String() string {
+	return fmt.Sprintf("Foo{name: %%+v}", f.name)
+}
+`, config.Version),
+		},
+		{
+			"Defaults",
+			`
+package p
+
+// gog:stepBuilder
+type Foo struct {
+	// gog:@required
+	name string
+	// gog:@default 3
+	retries int
+}
+`,
+			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
+// Version: %s
+package p
+
+import (
+	"fmt"
+
+	"github.com/quintans/gog/validation"
+)
+
+// Generated by gog:stepBuilder
+
+type FooNameStep interface {
+	Name(name string) FooOptionalStep
+}
+
+type FooOptionalStep interface {
+	Retries(retries int) FooOptionalStep
+	Build() (Foo, error)
+}
+
+type fooStepBuilder struct {
+	name    string
+	retries int
+}
+
+func NewFooStepBuilder() FooNameStep {
+	return &fooStepBuilder{
+		retries: 3,
+	}
+}
+
+func (b *fooStepBuilder) Name(name string) FooOptionalStep {
+	b.name = name
+	return b
+}
+
+func (b *fooStepBuilder) Retries(retries int) FooOptionalStep {
+	b.retries = retries
+	return b
+}
+
+func (b *fooStepBuilder) Build() (Foo, error) {
+	errs := validation.New("Foo")
+	if b.name == "" {
+		errs.Require("name")
+	}
+	s := Foo{
+		name:    b.name,
+		retries: b.retries,
+	}
+
+	if err := errs.Err(); err != nil {
+		return Foo{}, err
+	}
+
+	return s, nil
+}
+
+func (b *Foo) ToBuild() FooOptionalStep {
+	return &fooStepBuilder{
+		name:    b.name,
+		retries: b.retries,
+	}
+}
+
+func (f Foo) Name() string {
+	return f.name
+}
+
+func (f Foo) Retries() int {
+	return f.retries
+}
+
+func (f Foo) IsZero() bool {
+	return f == Foo{}
+}
+
+func (f Foo) String() string {
+	return fmt.Sprintf("Foo{name: %%+v, retries: %%+v}", f.name, f.retries)
+}
+`, config.Version),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			run(t, tt.in, tt.out)
+		})
+	}
+}
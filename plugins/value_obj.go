@@ -4,15 +4,30 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/quintans/gog/config"
 	"github.com/quintans/gog/generator"
 )
 
 func init() {
-	generator.Register(&ValueObj{})
+	generator.MustRegister(
+		&ValueObj{},
+		generator.WithVersion(config.Version),
+		generator.WithDescription("generates an immutable value object with constructor, getters and withers"),
+		generator.WithOptions(ValueObjOptions{}),
+		generator.WithConstruction(),
+		generator.WithImmutable(),
+		generator.WithFieldTags(RequiredTag, IgnoreTag, WitherTag, DefaultTag),
+		generator.WithEmbeds("getters"),
+		generator.WithCheck(checkConstructor),
+	)
+}
+
+type ValueObjOptions struct {
+	Pointer bool `desc:"getters use pointer receivers"`
 }
 
 type ValueObj struct {
-	generator.Scribler
+	generator.Code
 }
 
 func (b *ValueObj) Name() string {
@@ -28,17 +43,21 @@ func (b *ValueObj) Imports(mapper generator.Mapper) map[string]string {
 }
 
 func (b *ValueObj) GenerateBody(mapper generator.Mapper) error {
+	options := ValueObjOptions{}
+	if err := unmarshalOptions(mapper, b.Name(), &options); err != nil {
+		return err
+	}
+
 	allArgs := &AllArgsConstructor{}
 	allArgs.WriteBody(mapper, AllArgsConstructorOptions{})
-	b.Body.Write(allArgs.Flush())
+	b.Append(&allArgs.Code)
 
 	getters := Getters{}
-	err := getters.WriteBody(mapper, GetterOptions{})
+	err := getters.WriteBody(mapper, GetterOptions{Pointer: options.Pointer})
 	if err != nil {
 		return fmt.Errorf("writing ValueObj body: %w", err)
 	}
-	b.BPrintf("\n")
-	b.Body.Write(getters.Body.Bytes())
+	b.Append(&getters.Code)
 
 	for _, field := range mapper.GetFields() {
 		if field.HasTag(WitherTag) {
@@ -46,28 +65,36 @@ func (b *ValueObj) GenerateBody(mapper generator.Mapper) error {
 		}
 	}
 
-	_ = PrintIsZero(&b.Scribler, mapper)
-	_ = PrintString(&b.Scribler, mapper)
+	emitIsZeroAndString(&b.Code, mapper)
 
 	return nil
 }
 
 func (b *ValueObj) genWither(mapper generator.Mapper, field generator.Field) {
+	structName := mapper.GetName()
 	fieldName := field.NameOrKindName()
-	receiver := generator.UncapFirstSingle(mapper.GetName())
 	wither := "With" + strings.Title(fieldName)
-	if _, ok := mapper.FindMethod(wither); !ok {
-		b.BPrintf("\nfunc (%s %s) %s(%s %s) %s {\n", receiver, mapper.GetName(), wither, fieldName, field.Kind.String(), mapper.GetName())
-		b.BPrintf("  return %s {\n", mapper.GetName())
-		for _, f := range mapper.GetFields() {
-			fn := f.NameOrKindName()
-			if fn == fieldName {
-				b.BPrintf("%s: %s,\n", fn, fieldName)
-			} else {
-				b.BPrintf("%s: %s.%s,\n", fn, receiver, fn)
-			}
+	if _, ok := mapper.FindMethod(wither); ok {
+		return
+	}
+
+	scope := generator.NewScope()
+	arg := scope.Name(fieldName)
+	receiver := scope.Name(generator.UncapFirstSingle(structName))
+	elems := []generator.KeyValue{}
+	for _, f := range mapper.GetFields() {
+		fn := f.NameOrKindName()
+		if fn == fieldName {
+			elems = append(elems, generator.KeyValue{Key: fn, Value: generator.Id(arg)})
+		} else {
+			elems = append(elems, generator.KeyValue{Key: fn, Value: generator.Sel(generator.Id(receiver), fn)})
 		}
-		b.BPrintf("}\n")
-		b.BPrintf("}\n")
 	}
+	b.Emit(&generator.Func{
+		Recv:    &generator.Param{Name: receiver, Type: structName},
+		Name:    wither,
+		Params:  []generator.Param{{Name: arg, Type: field.Kind.String()}},
+		Results: []generator.Param{{Type: structName}},
+		Body:    []generator.Stmt{generator.Return(generator.Lit(structName, elems...))},
+	})
 }
@@ -26,6 +26,11 @@ type Foo struct {
 	// gog:@wither
 	age   int
 }
+
+type (
+	Bar   struct{}
+	Thing struct{}
+)
 `,
 			fmt.Sprintf(`// Code generated by gog; DO NOT EDIT.
 // Version: %s
@@ -0,0 +1,96 @@
+// Package validation has the error returned by the constructors and builders generated by gog.
+// Instead of failing on the first empty field, the generated code collects every field that breaks a rule,
+// together with the errors of the validate method, the setters and the nested builders.
+//
+// It is the only package of gog imported by the generated code, and it only uses the standard library.
+// The module of the generated code must require it: go get github.com/quintans/gog/validation
+//
+//	foo, err := NewFoo("", 0)
+//	var verr *validation.Error
+//	if errors.As(err, &verr) {
+//		for _, v := range verr.Violations {
+//			fmt.Println(v.Field, v.Rule) // name required
+//		}
+//	}
+package validation
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Required is the rule of the fields tagged with @required, that cannot be empty
+const Required = "required"
+
+// Violation is a field of a type that breaks a rule
+type Violation struct {
+	Type  string
+	Field string
+	Rule  string
+}
+
+func (v Violation) Error() string {
+	if v.Rule == Required {
+		return fmt.Sprintf("%s.%s cannot be empty", v.Type, v.Field)
+	}
+	return fmt.Sprintf("%s.%s breaks the rule %s", v.Type, v.Field, v.Rule)
+}
+
+// Error is the error of a value of a type that breaks rules.
+// It unwraps into each violation and each of the other errors,
+// so that errors.As and errors.Is, also through errors.Join, find them.
+type Error struct {
+	Type       string
+	Violations []Violation
+	// Errs are the other errors, like the one returned by the validate method
+	Errs []error
+}
+
+// New creates the error of a value of the type, to collect the violations
+func New(typ string) *Error {
+	return &Error{Type: typ}
+}
+
+// Require records that the field is empty
+func (e *Error) Require(field string) {
+	e.Violations = append(e.Violations, Violation{Type: e.Type, Field: field, Rule: Required})
+}
+
+// Add records the error, if not nil
+func (e *Error) Add(err error) {
+	if err != nil {
+		e.Errs = append(e.Errs, err)
+	}
+}
+
+// HasViolations returns true if a field breaks a rule
+func (e *Error) HasViolations() bool {
+	return len(e.Violations) > 0
+}
+
+// Err returns the error, or nil if nothing was recorded
+func (e *Error) Err() error {
+	if len(e.Violations) == 0 && len(e.Errs) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, 0, len(e.Violations)+len(e.Errs))
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Error())
+	}
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e *Error) Unwrap() []error {
+	errs := make([]error, 0, len(e.Violations)+len(e.Errs))
+	for _, v := range e.Violations {
+		errs = append(errs, v)
+	}
+	return append(errs, e.Errs...)
+}
@@ -0,0 +1,40 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestError(t *testing.T) {
+	e := New("Foo")
+	if err := e.Err(); err != nil {
+		t.Fatalf("got %v, want no error", err)
+	}
+
+	errInvalid := errors.New("timeout must be > 0")
+	e.Require("name")
+	e.Require("when")
+	e.Add(nil)
+	e.Add(errInvalid)
+	if !e.HasViolations() {
+		t.Error("got no violations")
+	}
+
+	err := errors.Join(e.Err(), errors.New("other"))
+	want := "Foo.name cannot be empty\nFoo.when cannot be empty\ntimeout must be > 0\nother"
+	if err.Error() != want {
+		t.Errorf("got %q, want %q", err.Error(), want)
+	}
+
+	var verr *Error
+	if !errors.As(err, &verr) || len(verr.Violations) != 2 || verr.Violations[1].Field != "when" {
+		t.Errorf("got %+v, want the violations of name and when", verr)
+	}
+	var v Violation
+	if !errors.As(err, &v) || v != (Violation{Type: "Foo", Field: "name", Rule: Required}) {
+		t.Errorf("got %+v, want the violation of name", v)
+	}
+	if !errors.Is(err, errInvalid) {
+		t.Error("got the error of validate missing")
+	}
+}